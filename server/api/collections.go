@@ -170,11 +170,28 @@ func (a *API) PatchCollectionConfig(w rest.ResponseWriter, r *rest.Request) {
 		return
 	}
 
+	// Copy of the filters: the decoding reuses the same array
+	filters := collection.Config.Import.Filters
+	filters = append(filters[:0:0], filters...)
+
 	if err := r.DecodeJsonPayload(collection.Config); err != nil {
 		rest.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
+	// Previous filters kept if invalid
+	if err := collection.UpdateFilters(); err != nil {
+		collection.Config.Import.Filters = filters
+		rest.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Enable, disable or resize buffer
+	if err := collection.UpdateBuffer(); err != nil {
+		rest.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	// // Store collection if enable
 	// if a.Classify.database != nil {
 
@@ -261,7 +278,19 @@ func (a *API) PatchCollectionSingleBuffer(w rest.ResponseWriter, r *rest.Request
 		return
 	}
 
-	w.WriteHeader(http.StatusNoContent)
+	var patch core.BufferItemPatch
+	if err := r.DecodeJsonPayload(&patch); err != nil {
+		rest.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	item, err := collection.ModifyBufferItem(r.PathParam("id"), &patch)
+	if err != nil {
+		rest.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.WriteJson(item)
 }
 
 // POST /collections/:name/buffers/:id/validate
@@ -279,7 +308,11 @@ func (a *API) ValidateCollectionSingleBuffer(w rest.ResponseWriter, r *rest.Requ
 		return
 	}
 
-	// Send next item if needed
+	// Move the item to the collection items & send next item if needed
+	if _, err = collection.Validate(r.PathParam("id"), nil); err != nil {
+		rest.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 
 	w.WriteHeader(http.StatusNoContent)
 }
@@ -0,0 +1,17 @@
+package api
+
+import (
+	"github.com/ant0ine/go-json-rest/rest"
+)
+
+// GET /collections/:name/duplicates
+func (a *API) GetCollectionDuplicates(w rest.ResponseWriter, r *rest.Request) {
+
+	// Check the collection exist
+	collection := a.getCollectionByName(w, r)
+	if collection == nil {
+		return
+	}
+
+	w.WriteJson(collection.GetDuplicates())
+}
@@ -0,0 +1,55 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/ant0ine/go-json-rest/rest"
+)
+
+// GET /collections/:name/history
+func (a *API) GetCollectionHistory(w rest.ResponseWriter, r *rest.Request) {
+
+	// Check the collection exist
+	collection := a.getCollectionByName(w, r)
+	if collection == nil {
+		return
+	}
+
+	w.WriteJson(collection.GetHistory())
+}
+
+// POST /collections/:name/history/undo
+func (a *API) UndoCollectionHistory(w rest.ResponseWriter, r *rest.Request) {
+
+	// Check the collection exist
+	collection := a.getCollectionByName(w, r)
+	if collection == nil {
+		return
+	}
+
+	entry, err := collection.Undo()
+	if err != nil {
+		rest.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.WriteJson(entry)
+}
+
+// POST /collections/:name/history/redo
+func (a *API) RedoCollectionHistory(w rest.ResponseWriter, r *rest.Request) {
+
+	// Check the collection exist
+	collection := a.getCollectionByName(w, r)
+	if collection == nil {
+		return
+	}
+
+	entry, err := collection.Redo()
+	if err != nil {
+		rest.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.WriteJson(entry)
+}
@@ -3,14 +3,21 @@ package api
 import (
 	"encoding/json"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"net/http"
 
 	"github.com/ant0ine/go-json-rest/rest"
 	"github.com/ohohleo/classify/core"
 	"github.com/ohohleo/classify/data"
 	"github.com/ohohleo/classify/imports"
+	"github.com/ohohleo/classify/imports/webhook"
+	"github.com/ohohleo/classify/secrets"
 )
 
+// Maximum size of the data pushed to the imports
+const PUSH_SIZE_MAX = 10 << 20
+
 // getImportByName get from Url parameters import
 func (a *API) getImportByName(w rest.ResponseWriter, r *rest.Request) *core.Import {
 
@@ -75,6 +82,7 @@ type AddImportsBody struct {
 	Ref         string          `json:"ref"`
 	Collections []string        `json:"collections"`
 	Params      json.RawMessage `json:"params"`
+	Schedule    string          `json:"schedule"`
 }
 
 // PostCollectionImport add a new import to the collection specified
@@ -103,6 +111,12 @@ func (a *API) AddImport(w rest.ResponseWriter, r *rest.Request) {
 		return
 	}
 
+	// Cron schedule of the import runs
+	if err := core.CheckSchedule(body.Schedule); err != nil {
+		rest.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	i, outParams, err := a.Classify.CreateImport(body.Name, ref, body.Params, collections)
 	if err != nil {
 
@@ -116,6 +130,13 @@ func (a *API) AddImport(w rest.ResponseWriter, r *rest.Request) {
 		return
 	}
 
+	if body.Schedule != "" {
+		if err := a.Classify.SetImportSchedule(i.Name, body.Schedule); err != nil {
+			rest.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
 	w.WriteJson(i)
 }
 
@@ -135,13 +156,24 @@ func (a *API) GetImports(w rest.ResponseWriter, r *rest.Request) {
 	}
 
 	type ImportBody struct {
-		Params imports.Import `json:"params"`
-		Ref    string         `json:"ref"`
+		Params interface{} `json:"params"`
+		Ref    string      `json:"ref"`
+		core.ImportRuns
 	}
 
 	res := make(map[string]ImportBody)
 	for name, i := range importList {
-		res[name] = ImportBody{i, i.GetRef().String()}
+		body := ImportBody{
+			Params: secrets.Redact(i),
+			Ref:    i.GetRef().String(),
+		}
+
+		// Schedule, last & next run times
+		if imp, err := a.Classify.GetImportByName(name); err == nil {
+			body.ImportRuns = imp.GetRuns()
+		}
+
+		res[name] = body
 	}
 
 	w.WriteJson(res)
@@ -219,16 +251,16 @@ func (a *API) GetImport(w rest.ResponseWriter, r *rest.Request) {
 	}
 
 	type ImportBody struct {
-		Ref          string         `json:"ref"`
-		Input        imports.Import `json:"input"`
-		OutputFormat []data.Data    `json:"output_format,omitempty"`
-		Config       *core.Configs  `json:"config"`
+		Ref          string        `json:"ref"`
+		Input        interface{}   `json:"input"`
+		OutputFormat []data.Data   `json:"output_format,omitempty"`
+		Config       *core.Configs `json:"config"`
 	}
 
 	engine := i.GetEngine()
 	body := ImportBody{
 		Ref:   engine.GetRef().String(),
-		Input: engine,
+		Input: secrets.Redact(engine),
 	}
 
 	if _, ok := r.URL.Query()["references"]; ok {
@@ -306,3 +338,67 @@ func (a *API) PutImportParams(w rest.ResponseWriter, r *rest.Request) {
 	w.WriteJson(res)
 	return
 }
+
+// Push a JSON document or a NDJSON batch to the webhook import: the body
+// is signed with the import secret in the 'X-Hub-Signature-256' header
+// POST /imports/:name/push
+func (a *API) PushImport(w rest.ResponseWriter, r *rest.Request) {
+
+	body, err := ioutil.ReadAll(io.LimitReader(r.Body, PUSH_SIZE_MAX+1))
+	if err != nil {
+		rest.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if len(body) > PUSH_SIZE_MAX {
+		rest.Error(w, "body too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	count, err := a.Classify.PushImport(r.PathParam("name"), body,
+		r.Header.Get(webhook.SIGNATURE_HEADER))
+	if err != nil {
+		status := http.StatusBadRequest
+		if err == webhook.ErrInvalidSignature {
+			status = http.StatusUnauthorized
+		}
+
+		rest.Error(w, err.Error(), status)
+		return
+	}
+
+	w.WriteJson(map[string]int{
+		"pushed": count,
+	})
+}
+
+type ScheduleImportBody struct {
+	Schedule string `json:"schedule"`
+}
+
+// ScheduleImport runs the import with the cron schedule specified (ie.
+// '*/30 * * * *', '@daily' or '@every 1h'), no more scheduled if empty
+// PUT /imports/:name/schedule
+func (a *API) ScheduleImport(w rest.ResponseWriter, r *rest.Request) {
+
+	var body ScheduleImportBody
+	err := r.DecodeJsonPayload(&body)
+	if err != nil {
+		rest.Error(w, fmt.Sprintf("invalid json body: %s", err.Error()), http.StatusBadRequest)
+		return
+	}
+
+	name := r.PathParam("name")
+	if err := a.Classify.SetImportSchedule(name, body.Schedule); err != nil {
+		rest.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	i, err := a.Classify.GetImportByName(name)
+	if err != nil {
+		rest.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.WriteJson(i.GetRuns())
+}
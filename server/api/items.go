@@ -59,7 +59,25 @@ func (a *API) PatchCollectionSingleItem(w rest.ResponseWriter, r *rest.Request)
 		return
 	}
 
-	w.WriteHeader(http.StatusNoContent)
+	id, err := core.GetIdFromString(r.PathParam("id"))
+	if err != nil {
+		rest.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var patch core.ItemPatch
+	if err = r.DecodeJsonPayload(&patch); err != nil {
+		rest.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	item, err := collection.ModifyItem(id, &patch)
+	if err != nil {
+		rest.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.WriteJson(item)
 }
 
 // DELETE /collections/:name/items/:id
@@ -84,3 +102,27 @@ func (a *API) DeleteCollectionSingleItem(w rest.ResponseWriter, r *rest.Request)
 
 	w.WriteHeader(http.StatusNoContent)
 }
+
+// POST /collections/:name/items/:id/unvalidate
+func (a *API) UnvalidateCollectionSingleItem(w rest.ResponseWriter, r *rest.Request) {
+
+	// Check the collection exist
+	collection := a.getCollectionByName(w, r)
+	if collection == nil {
+		return
+	}
+
+	id, err := core.GetIdFromString(r.PathParam("id"))
+	if err != nil {
+		rest.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	item, err := collection.Unvalidate(id)
+	if err != nil {
+		rest.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.WriteJson(item)
+}
@@ -0,0 +1,58 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/ant0ine/go-json-rest/rest"
+	"github.com/ohohleo/classify/secrets"
+)
+
+// List the names of the secrets stored, never their values
+// GET /secrets
+func (a *API) GetSecrets(w rest.ResponseWriter, r *rest.Request) {
+
+	names, err := a.Classify.GetSecretNames()
+	if err != nil {
+		rest.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.WriteJson(names)
+}
+
+type PutSecretBody struct {
+	Value string `json:"value"`
+}
+
+// Store the secret, returns the reference to use in the configurations
+// PUT /secrets/:name
+func (a *API) PutSecret(w rest.ResponseWriter, r *rest.Request) {
+
+	var body PutSecretBody
+	if err := r.DecodeJsonPayload(&body); err != nil {
+		rest.Error(w, fmt.Sprintf("invalid json body: %s", err.Error()), http.StatusBadRequest)
+		return
+	}
+
+	name := r.PathParam("name")
+	if err := a.Classify.SetSecret(name, body.Value); err != nil {
+		rest.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.WriteJson(map[string]string{
+		"reference": secrets.Reference(name),
+	})
+}
+
+// DELETE /secrets/:name
+func (a *API) DeleteSecret(w rest.ResponseWriter, r *rest.Request) {
+
+	if err := a.Classify.DeleteSecret(r.PathParam("name")); err != nil {
+		rest.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
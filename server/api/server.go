@@ -95,6 +95,15 @@ func (a *API) GetAPI(server *Server) (*rest.Api, error) {
 		// Handle references
 		rest.Get("/references", a.GetReferences),
 
+		// Handle websites cache
+		rest.Get("/websites/cache", a.GetWebsitesCache),
+		rest.Delete("/websites/cache", a.DeleteWebsitesCache),
+
+		// Handle secrets
+		rest.Get("/secrets", a.GetSecrets),
+		rest.Put("/secrets/:name", a.PutSecret),
+		rest.Delete("/secrets/:name", a.DeleteSecret),
+
 		// Handle imports
 		rest.Post("/imports", a.AddImport),
 		rest.Get("/imports", a.GetImports),
@@ -104,6 +113,8 @@ func (a *API) GetAPI(server *Server) (*rest.Api, error) {
 		rest.Get("/imports/:name", a.GetImport),
 		rest.Patch("/imports/:name/config", a.PatchImportConfig),
 		rest.Put("/imports/:name/params/:param", a.PutImportParams),
+		rest.Post("/imports/:name/push", a.PushImport),
+		rest.Put("/imports/:name/schedule", a.ScheduleImport),
 
 		// Handle exports
 		rest.Post("/exports", a.AddExport),
@@ -140,6 +151,15 @@ func (a *API) GetAPI(server *Server) (*rest.Api, error) {
 		rest.Get("/collections/:name/items/:id", a.GetCollectionSingleItem),
 		rest.Patch("/collections/:name/items/:id", a.PatchCollectionSingleItem),
 		rest.Delete("/collections/:name/items/:id", a.DeleteCollectionSingleItem),
+		rest.Post("/collections/:name/items/:id/unvalidate", a.UnvalidateCollectionSingleItem),
+
+		// Handle collection history
+		rest.Get("/collections/:name/history", a.GetCollectionHistory),
+		rest.Post("/collections/:name/history/undo", a.UndoCollectionHistory),
+		rest.Post("/collections/:name/history/redo", a.RedoCollectionHistory),
+
+		// Handle collection items with the same content
+		rest.Get("/collections/:name/duplicates", a.GetCollectionDuplicates),
 
 		// Establish connection to the web-services
 		streamRoute,
@@ -0,0 +1,36 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/ant0ine/go-json-rest/rest"
+)
+
+// GET /websites/cache
+func (a *API) GetWebsitesCache(w rest.ResponseWriter, r *rest.Request) {
+
+	stats, err := a.Classify.GetCacheStats()
+	if err != nil {
+		rest.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.WriteJson(stats)
+}
+
+// DELETE /websites/cache?website=...&expired=true
+func (a *API) DeleteWebsitesCache(w rest.ResponseWriter, r *rest.Request) {
+
+	query := r.URL.Query()
+
+	removed, err := a.Classify.PurgeCache(query.Get("website"),
+		query.Get("expired") == "true")
+	if err != nil {
+		rest.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.WriteJson(map[string]int{
+		"removed": removed,
+	})
+}
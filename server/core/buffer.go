@@ -2,6 +2,8 @@ package core
 
 import (
 	"fmt"
+	"log"
+	"sort"
 )
 
 type Buffer struct {
@@ -22,14 +24,34 @@ func NewBuffer(collection *Collection, defaultSize int) *Buffer {
 
 func (b *Buffer) CleanedNames(bannedList []string, separators []string) {
 
+	items := make([]*BufferItem, 0)
+
 	for _, item := range b.items {
 
 		if item.SetCleanedName(bannedList, separators) {
 
 			b.collection.SendCollectionEvent("buffer", "update", &item.Item)
 
+			// Previous web results are outdated
+			item.Websites = nil
+
 			// Launch research on item
 			b.collection.Search("buffer", item)
+			items = append(items, item)
+		}
+	}
+
+	b.autoValidate(items)
+}
+
+// Validate automatically the items searched once the buffer is updated
+func (b *Buffer) autoValidate(items []*BufferItem) {
+
+	for _, item := range items {
+
+		if err := b.collection.AutoValidate(item); err != nil {
+			log.Printf("[%s] auto-validate %d: %s\n",
+				b.collection.Name, item.Id, err.Error())
 		}
 	}
 }
@@ -62,6 +84,11 @@ func (b *Buffer) SetSize(size int) {
 		item, ok := b.items[key]
 		if ok {
 			item.Status = BUFFER_WAITING
+
+			if err := b.collection.StoreBufferItem2DB(item); err != nil {
+				log.Printf("[%s] buffer item '%s': %s\n",
+					b.collection.Name, key, err.Error())
+			}
 		} else {
 			fmt.Println("key '" + key + "' not in buffer")
 		}
@@ -70,27 +97,49 @@ func (b *Buffer) SetSize(size int) {
 	b.waitings = b.waitings[:size]
 }
 
-func (b *Buffer) Add(name string, item *BufferItem) bool {
+// Add the item to the buffer: the items are referenced by their id, the
+// same name can be shared by different contents
+func (b *Buffer) Add(item *BufferItem) bool {
 
-	if _, ok := b.items[name]; ok {
-		fmt.Println("already existing item '" + name + "' in buffer")
+	key := item.Id.String()
+
+	if _, ok := b.items[key]; ok {
+		fmt.Println("already existing item '" + key + "' in buffer")
 		return false
 	}
 
 	// Add to the hash list
-	b.items[name] = item
+	b.items[key] = item
 
-	b.SendNext(name)
+	b.SendNext(key)
 
 	return true
 }
 
+// Returns the key of the item referenced by its id or by its name
+func (b *Buffer) getKey(key string) (string, bool) {
+
+	if _, ok := b.items[key]; ok {
+		return key, true
+	}
+
+	for itemKey, item := range b.items {
+		if item.Item.Engine != nil && item.Item.Engine.GetName() == key {
+			return itemKey, true
+		}
+	}
+
+	return "", false
+}
+
 func (b *Buffer) Remove(name string) bool {
 
-	if _, ok := b.items[name]; ok == false {
+	key, ok := b.getKey(name)
+	if ok == false {
 		fmt.Println("not existing item '" + name + "' in buffer")
 		return false
 	}
+	name = key
 
 	// Remove from the waiting list
 	for idx, itemName := range b.waitings {
@@ -100,6 +149,12 @@ func (b *Buffer) Remove(name string) bool {
 		}
 	}
 
+	// Remove from the database
+	if err := b.collection.DeleteBufferItem2DB(b.items[name]); err != nil {
+		log.Printf("[%s] buffer item '%s': %s\n",
+			b.collection.Name, name, err.Error())
+	}
+
 	// Remove the hash list
 	delete(b.items, name)
 
@@ -113,13 +168,78 @@ func (b *Buffer) RemoveAll() {
 	for name, _ := range b.items {
 		delete(b.items, name)
 	}
+
+	b.waitings = make([]string, 0)
+
+	// Remove from the database
+	if err := b.collection.DeleteBuffer2DB(); err != nil {
+		log.Printf("[%s] buffer: %s\n", b.collection.Name, err.Error())
+	}
+}
+
+// Restore stored items without launching any research: the items
+// already sent are still displayed, the others keep waiting until the
+// next items are sent
+func (b *Buffer) Restore(items []*BufferItem) {
+
+	// Keep the oldest items first
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].CreatedAt.Before(items[j].CreatedAt)
+	})
+
+	for _, item := range items {
+
+		name := item.Id.String()
+
+		if _, ok := b.items[name]; ok {
+			fmt.Println("already existing item '" + name + "' in buffer")
+			continue
+		}
+
+		b.items[name] = item
+
+		switch item.Status {
+		case BUFFER_SENDING, BUFFER_SENT:
+			b.waitings = append(b.waitings, name)
+		}
+	}
+
+	// Reduce the size if needed
+	if len(b.waitings) > b.maxSize {
+		size := b.maxSize
+		b.maxSize = len(b.waitings)
+		b.SetSize(size)
+	}
+}
+
+// Get returns the item referenced by its id or by its name
+func (b *Buffer) Get(name string) *BufferItem {
+
+	key, ok := b.getKey(name)
+	if ok == false {
+		return nil
+	}
+
+	return b.items[key]
+}
+
+// GetById returns the buffer item and its name with the id specified
+func (b *Buffer) GetById(id Id) (string, *BufferItem) {
+
+	for name, item := range b.items {
+		if item.Id == id {
+			return name, item
+		}
+	}
+
+	return "", nil
 }
 
 func (b *Buffer) Validate(name string) *BufferItem {
 
-	item, ok := b.items[name]
-	if ok {
-		b.Remove(name)
+	item := b.Get(name)
+	if item != nil {
+		b.Remove(item.Id.String())
 	}
 
 	return item
@@ -172,18 +292,26 @@ func (b *Buffer) SendNext(next string) {
 		}
 	}
 
-	// Search for next items
+	// Search for next items: the oldest first
+	waitings := make([]string, 0)
 	for key, item := range b.items {
+		if item.Status <= BUFFER_WAITING {
+			waitings = append(waitings, key)
+		}
+	}
+
+	sort.Slice(waitings, func(i, j int) bool {
+		return b.items[waitings[i]].CreatedAt.Before(b.items[waitings[j]].CreatedAt)
+	})
+
+	for _, key := range waitings {
 
 		// No need to send more items
 		if itemsNb <= 0 {
 			break
 		}
 
-		if item.Status > BUFFER_WAITING {
-			continue
-		}
-
+		item := b.items[key]
 		item.Status = BUFFER_SENDING
 		items = append(items, item)
 		keys = append(keys, key)
@@ -195,4 +323,6 @@ func (b *Buffer) SendNext(next string) {
 
 	// Store keys
 	b.waitings = append(b.waitings, keys...)
+
+	b.autoValidate(items)
 }
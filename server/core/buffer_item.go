@@ -1,8 +1,11 @@
 package core
 
 import (
+	"encoding/json"
 	"fmt"
 	"github.com/ohohleo/classify/data"
+	"log"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -27,6 +30,23 @@ type BufferItem struct {
 	Websites    map[string][]data.Data `json:"websites"`
 	MatchId     string                 `json:"matchId"`
 	IsMatching  float32                `json:"probability"`
+	Candidates  []*Candidate           `json:"candidates"`
+
+	// Already validated automatically once
+	IsAutoValidated bool `json:"isAutoValidated"`
+
+	// Same content than an item already imported
+	IsDuplicate bool `json:"isDuplicate"`
+	DuplicateOf Id   `json:"duplicateOf,string,omitempty"`
+}
+
+// BufferItemPatch lists the buffer item attributes to modify: the import
+// is removed with its data id
+type BufferItemPatch struct {
+	CleanedName  *string `json:"cleanedName"`
+	WebQuery     *string `json:"webQuery"`
+	MatchId      *string `json:"matchId"`
+	RemoveImport *string `json:"removeImport"`
 }
 
 func NewBufferItem(id Id) *BufferItem {
@@ -40,6 +60,131 @@ func NewBufferItem(id Id) *BufferItem {
 	return item
 }
 
+// Buffer item with all datas stored with their refs
+type storedBufferItem struct {
+	*BufferItem
+	Engine   *data.Stored              `json:"data"`
+	Imports  []*data.Stored            `json:"imports"`
+	Websites map[string][]*data.Stored `json:"websites"`
+	Details  json.RawMessage           `json:"details,omitempty"`
+}
+
+// ToJSON convert buffer item to be stored
+func (i *BufferItem) ToJSON() ([]byte, error) {
+
+	var err error
+
+	stored := &storedBufferItem{
+		BufferItem: i,
+		Imports:    make([]*data.Stored, len(i.Imports)),
+	}
+
+	if i.Item.Engine != nil {
+		if stored.Engine, err = data.NewStored(i.Item.Engine); err != nil {
+			return nil, err
+		}
+	}
+
+	for idx, d := range i.Imports {
+		if stored.Imports[idx], err = data.NewStored(d); err != nil {
+			return nil, err
+		}
+	}
+
+	if i.Item.Details != nil {
+		if stored.Details, err = json.Marshal(i.Item.Details); err != nil {
+			return nil, err
+		}
+	}
+
+	if i.Websites != nil {
+
+		stored.Websites = make(map[string][]*data.Stored)
+
+		for name, datas := range i.Websites {
+
+			stored.Websites[name] = make([]*data.Stored, len(datas))
+
+			for idx, d := range datas {
+				if stored.Websites[name][idx], err = data.NewStored(d); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+
+	return json.Marshal(stored)
+}
+
+// NewBufferItemFromJSON rebuild stored buffer item with all its datas
+func NewBufferItemFromJSON(src []byte) (item *BufferItem, err error) {
+
+	stored := &storedBufferItem{
+		BufferItem: new(BufferItem),
+	}
+
+	if err = json.Unmarshal(src, stored); err != nil {
+		return
+	}
+
+	item = stored.BufferItem
+	item.Imports = make([]data.Data, len(stored.Imports))
+
+	if stored.Engine != nil {
+		if item.Item.Engine, err = stored.Engine.Get(); err != nil {
+			return
+		}
+	}
+
+	for idx, d := range stored.Imports {
+		if item.Imports[idx], err = d.Get(); err != nil {
+			return
+		}
+	}
+
+	// Retreive website data with its ref
+	if len(stored.Details) > 0 && string(stored.Details) != "null" {
+
+		item.Item.Details, err = data.NewFromJSON(item.DetailsRef, stored.Details)
+		if err != nil {
+			return
+		}
+	}
+
+	if stored.Websites != nil {
+
+		item.Websites = make(map[string][]data.Data)
+
+		for name, datas := range stored.Websites {
+
+			item.Websites[name] = make([]data.Data, len(datas))
+
+			for idx, d := range datas {
+				if item.Websites[name][idx], err = d.Get(); err != nil {
+					return
+				}
+			}
+		}
+	}
+
+	// Sync again imported data contents
+	item.LinkImports()
+	return
+}
+
+// LinkImports syncs again the contents of all the imported data
+func (i *BufferItem) LinkImports() {
+
+	i.Item.Contents = nil
+	i.Item.contents = nil
+
+	for _, d := range i.Imports {
+		if err := i.Item.LinkToData(d); err != nil {
+			log.Printf("[buffer item %d] %s\n", i.Id, err.Error())
+		}
+	}
+}
+
 func (i *BufferItem) SetCleanedName(bannedList []string, separators []string) bool {
 
 	previousName := i.CleanedName
@@ -91,6 +236,25 @@ func (i *BufferItem) AddImportData(d data.Data) {
 }
 
 func (i *BufferItem) RemoveImportData(d data.Data) {
+
+	for idx, imported := range i.Imports {
+		if imported == d {
+			i.Imports = append(i.Imports[:idx], i.Imports[idx+1:]...)
+			return
+		}
+	}
+}
+
+// GetImportData returns the imported data with the id specified
+func (i *BufferItem) GetImportData(id string) data.Data {
+
+	for _, d := range i.Imports {
+		if strconv.FormatUint(data.GetId(d), 10) == id {
+			return d
+		}
+	}
+
+	return nil
 }
 
 func (i *BufferItem) AddWebsiteData(name string, d data.Data) {
@@ -105,6 +269,70 @@ func (i *BufferItem) AddWebsiteData(name string, d data.Data) {
 func (i *BufferItem) RemoveWebsiteData(d data.Data) {
 }
 
+// Match ranks the websites results and select the best candidate
+func (i *BufferItem) Match() {
+
+	i.Candidates = Match(i.CleanedName, i.Websites)
+
+	if len(i.Candidates) == 0 {
+		i.MatchId = ""
+		i.IsMatching = 0
+		return
+	}
+
+	i.MatchId = i.Candidates[0].Id
+	i.IsMatching = i.Candidates[0].Score
+}
+
+// SelectMatch selects the candidate specified instead of the best one
+func (i *BufferItem) SelectMatch(id string) error {
+
+	for _, candidate := range i.Candidates {
+
+		if candidate.Id == id {
+			i.MatchId = candidate.Id
+			i.IsMatching = candidate.Score
+			return nil
+		}
+	}
+
+	return fmt.Errorf("match '%s' not found in candidates", id)
+}
+
+// GetMatch returns the website data selected by the match id
+func (i *BufferItem) GetMatch() data.Data {
+
+	for _, candidate := range i.Candidates {
+
+		if candidate.Id != i.MatchId {
+			continue
+		}
+
+		datas := i.Websites[candidate.Website]
+		if candidate.Index < len(datas) {
+			return datas[candidate.Index]
+		}
+	}
+
+	return nil
+}
+
+// CanAutoValidate returns true if the best candidate is good enough
+// and far enough from the other ones
+func (i *BufferItem) CanAutoValidate(threshold float32, minLead float32) bool {
+
+	if i.IsAutoValidated || len(i.Candidates) == 0 {
+		return false
+	}
+
+	// Best candidate should be selected
+	if i.MatchId != i.Candidates[0].Id {
+		return false
+	}
+
+	return i.Candidates[0].Score >= threshold && Lead(i.Candidates) >= minLead
+}
+
 func (i *BufferItem) String() string {
 	return fmt.Sprintf("id: %d", i.Id)
 }
@@ -2,13 +2,18 @@ package core
 
 import (
 	"encoding/json"
+	"fmt"
 	"github.com/ohohleo/classify/database"
 	"github.com/ohohleo/classify/requests"
+	"github.com/ohohleo/classify/secrets"
 	"github.com/ohohleo/classify/websites"
 	"log"
 	"math/rand"
 )
 
+// Default number of requests sent simultaneously to the same website
+const REQUESTS_MAX = 2
+
 type Classify struct {
 	config      *Config
 	database    *database.Database
@@ -18,6 +23,7 @@ type Classify struct {
 	exports     map[string]*Export
 	Collections map[string]*Collection
 	websites    map[string]websites.Website
+	secrets     *secrets.Secrets
 }
 
 type Config struct {
@@ -32,7 +38,14 @@ type Config struct {
 	// Database configuration
 	DataBase database.Config `json:"database"`
 
+	// Values referenced as 'secret:NAME' are read from the secrets
 	Websites map[string]map[string]string `json:"websites"`
+
+	// Encrypted credentials configuration
+	Secrets secrets.Config `json:"secrets"`
+
+	// Websites responses cache configuration
+	Cache requests.CacheConfig `json:"cache"`
 }
 
 // Application startup
@@ -56,6 +69,12 @@ func NewClassify(config *Config) (c *Classify, events chan *Event, err error) {
 		return
 	}
 
+	// Credentials used by the imports & the websites
+	if c.secrets, err = secrets.Open(config.Secrets); err != nil {
+		return
+	}
+	secrets.SetDefault(c.secrets)
+
 	// Retreive classify stored data
 	if err = c.StartDB(config); err != nil {
 		return
@@ -64,7 +83,23 @@ func NewClassify(config *Config) (c *Classify, events chan *Event, err error) {
 	log.Println("Starting Classify")
 
 	// HTTP requests
-	c.requests = requests.New(2, true)
+	c.requests = requests.New(REQUESTS_MAX, true)
+
+	if err = c.requests.SetCache(config.Cache); err != nil {
+		return
+	}
+
+	// Requests limits by website
+	for name, websiteConfig := range config.Websites {
+
+		limits, limitsErr := requests.NewLimits(websiteConfig, REQUESTS_MAX)
+		if limitsErr != nil {
+			err = fmt.Errorf("website '%s' %s", name, limitsErr.Error())
+			return
+		}
+
+		c.requests.SetLimits(name, limits)
+	}
 
 	// Store config file
 	c.config = config
@@ -73,6 +108,13 @@ func NewClassify(config *Config) (c *Classify, events chan *Event, err error) {
 	events = make(chan *Event)
 	c.events = events
 
+	// Send the buffer items restored now that the websites can be
+	// requested
+	go c.sendBuffers()
+
+	// Start the imports scheduled
+	c.startSchedules()
+
 	// Specify that the application start
 	go func() {
 		c.SendEvent("start", "", "", "")
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"strings"
 
 	"github.com/ohohleo/classify/collections"
 	"github.com/ohohleo/classify/data"
@@ -19,8 +20,20 @@ type Collection struct {
 	Engine collections.Collection
 	Config *CollectionConfig
 
-	buffer *Buffer
-	items  *Items
+	buffer   *Buffer
+	items    *Items
+	database *database.Database
+
+	// Operations done on items that can be undone
+	history *History
+
+	// Import filters compiled from the configuration
+	filters     *Filters
+	filtersSrc  string
+	filterStats FilterStats
+
+	// Index of the items contents
+	duplicates *Duplicates
 
 	events chan CollectionEvent
 
@@ -34,6 +47,7 @@ type CollectionEvent struct {
 	Status string
 	Id     string
 	Item   *Item
+	Data   interface{}
 }
 
 type CollectionParams struct {
@@ -157,12 +171,156 @@ func (c *Collection) StoreConfig2DB(db *database.Database) error {
 
 func (c *Collection) Delete2DB(db *database.Database) error {
 
+	// Remove all collection items
+	if err := db.DeleteItems("items", c.Id); err != nil {
+		return err
+	}
+
+	if err := db.DeleteItems("buffers", c.Id); err != nil {
+		return err
+	}
+
+	if err := db.DeleteItems("histories", c.Id); err != nil {
+		return err
+	}
+
 	return db.Delete("collections", &database.GenStruct{
 		Name: c.Name,
 		Ref:  uint64(c.Engine.GetRef()),
 	}, "name = :name AND ref = :ref")
 }
 
+// Store item in the database if enabled
+func (c *Collection) StoreItem2DB(item *Item) error {
+
+	// Check if db is enabled
+	if c.database == nil {
+		return nil
+	}
+
+	dataJson, err := json.Marshal(item)
+	if err != nil {
+		return err
+	}
+
+	return c.database.StoreItem("items", &database.ItemStruct{
+		CollectionsId: c.Id,
+		Uid:           item.Id.String(),
+		Ref:           uint64(data.REF_STR2IDX[item.Ref]),
+		Data:          dataJson,
+	})
+}
+
+// Remove item from the database if enabled
+func (c *Collection) DeleteItem2DB(id Id) error {
+
+	// Check if db is enabled
+	if c.database == nil {
+		return nil
+	}
+
+	return c.database.DeleteItem("items", c.Id, id.String())
+}
+
+// Retreive all stored items of the collection
+func (c *Collection) RetreiveDBItems() error {
+
+	// Check if db is enabled
+	if c.database == nil {
+		return nil
+	}
+
+	return c.database.RetreiveItems("items", c.Id,
+		func(uid string, ref uint64, src []byte) error {
+
+			item, err := NewItemFromJSON(src)
+			if err != nil {
+				return fmt.Errorf("collection '%s' invalid item '%s': %s",
+					c.Name, uid, err.Error())
+			}
+
+			return c.items.Add(item.Id, item)
+		})
+}
+
+// Store buffer item in the database if enabled
+func (c *Collection) StoreBufferItem2DB(item *BufferItem) error {
+
+	// Check if db is enabled
+	if c.database == nil {
+		return nil
+	}
+
+	dataJson, err := item.ToJSON()
+	if err != nil {
+		return err
+	}
+
+	return c.database.StoreItem("buffers", &database.ItemStruct{
+		CollectionsId: c.Id,
+		Uid:           item.Id.String(),
+		Ref:           uint64(data.REF_STR2IDX[item.Ref]),
+		Data:          dataJson,
+	})
+}
+
+// Remove buffer item from the database if enabled
+func (c *Collection) DeleteBufferItem2DB(item *BufferItem) error {
+
+	// Check if db is enabled
+	if c.database == nil || item == nil {
+		return nil
+	}
+
+	return c.database.DeleteItem("buffers", c.Id, item.Id.String())
+}
+
+// Remove all buffer items from the database if enabled
+func (c *Collection) DeleteBuffer2DB() error {
+
+	// Check if db is enabled
+	if c.database == nil {
+		return nil
+	}
+
+	return c.database.DeleteItems("buffers", c.Id)
+}
+
+// Retreive all stored buffer items of the collection
+func (c *Collection) RetreiveDBBuffer() error {
+
+	// Check if db is enabled
+	if c.database == nil {
+		return nil
+	}
+
+	var items []*BufferItem
+
+	err := c.database.RetreiveItems("buffers", c.Id,
+		func(uid string, ref uint64, src []byte) error {
+
+			item, err := NewBufferItemFromJSON(src)
+			if err != nil {
+				return fmt.Errorf("collection '%s' invalid buffer item '%s': %s",
+					c.Name, uid, err.Error())
+			}
+
+			if item.Item.Engine == nil {
+				return fmt.Errorf("collection '%s' buffer item '%s' without data",
+					c.Name, uid)
+			}
+
+			items = append(items, item)
+			return nil
+		})
+	if err != nil {
+		return err
+	}
+
+	c.buffer.Restore(items)
+	return nil
+}
+
 // AddWebsite add new website
 func (c *Collection) AddWebsite(website websites.Website) {
 
@@ -198,10 +356,17 @@ func (c *Collection) GetWebsiteParams() []string {
 	return keys
 }
 
-func (c *Collection) ActivateBuffer() {
+func (c *Collection) ActivateBuffer() error {
+
+	if c.buffer != nil {
+		return fmt.Errorf("collection '%s' buffer already enabled", c.Name)
+	}
 
 	// Init the items buffer
-	c.ResetBuffer()
+	c.buffer = NewBuffer(c, c.Config.Buffer.Size)
+
+	// Restore the buffer items stored
+	return c.RetreiveDBBuffer()
 }
 
 func (c *Collection) DisableBuffer() error {
@@ -214,20 +379,64 @@ func (c *Collection) DisableBuffer() error {
 	return nil
 }
 
+// UpdateBuffer enable, disable or resize the buffer following the
+// collection configuration
+func (c *Collection) UpdateBuffer() error {
+
+	if c.Config.Buffer.Enable == false {
+
+		if c.buffer != nil {
+			return c.DisableBuffer()
+		}
+
+		return nil
+	}
+
+	if c.buffer == nil {
+		return c.ActivateBuffer()
+	}
+
+	c.buffer.SetSize(c.Config.Buffer.Size)
+	return nil
+}
+
 func (c *Collection) Search(src string, item *BufferItem) {
-	// Launch research through web
-	if len(c.websites) > 0 {
+	// Launch research through web (if not already done)
+	if len(c.websites) > 0 && item.Websites == nil {
 		c.SearchWeb(item)
+	}
+
+	// Research for best matching
+	if item.Websites != nil {
+		item.Match()
+	}
+
+	if err := c.StoreBufferItem2DB(item); err != nil {
+		log.Printf("[%s] buffer item %d: %s\n", c.Name, item.Id, err.Error())
+	}
 
-		// item.MatchId = item.Websites["TMDB"][0].GetId()
+	// Send the item with the ranked candidates
+	c.SendCollectionEventData(src, "update", &item.Item, item)
+}
+
+// GetDetails returns the full record of the website data with the match id
+// specified, nothing is returned if the website data has no id
+func (c *Collection) GetDetails(matchId string) (d data.Data, err error) {
+
+	idx := strings.Index(matchId, ":")
+	if idx < 0 || strings.HasPrefix(matchId[idx+1:], "#") {
+		return
 	}
 
-	// // TODO Research for best matching
-	// item.Ref = item.Websites["IMDB"][0].GetRef()
-	// item.IsMatching = 10.2
-	// item.BestMatch = item.Websites["IMDB"][0]
+	name, id := matchId[:idx], matchId[idx+1:]
+
+	website, ok := c.websites[name]
+	if ok == false {
+		err = fmt.Errorf("website '%s' not found", name)
+		return
+	}
 
-	c.SendCollectionEvent(src, "update", &item.Item)
+	return website.Details(id)
 }
 
 // SearchWeb launch resarch through specified websites
@@ -235,6 +444,9 @@ func (c *Collection) SearchWeb(item *BufferItem) {
 	// Get name to search
 	keywords := item.WebQuery
 
+	// Reset previous results
+	item.Websites = make(map[string][]data.Data)
+
 	// For all specified websites
 	for _, website := range c.websites {
 
@@ -253,57 +465,121 @@ func (c *Collection) SearchWeb(item *BufferItem) {
 	}
 }
 
-// OnInput handle new data to classify
+// OnInput handle new data to classify: no item is returned if the input
+// is filtered
 func (c *Collection) OnInput(id Id, input data.Data) (item *BufferItem, err error) {
+
+	// Check the import filters
+	if ok, err := c.filterInput(input); ok == false || err != nil {
+		return nil, err
+	}
+
 	// Create a new item
 	item = NewBufferItem(id)
+	item.Item.SetData(input)
 
-	if c.buffer != nil {
+	// Handle data commands called when data is accepted by
+	// collection
+	if err = c.onDataInput(input); err != nil {
+		return
+	}
 
-		// Add the import to the item
-		item.AddImportData(input)
+	// Handle data contents
+	item.Item.LinkToData(input)
 
-		// Get Cleaned name
-		item.SetCleanedName(c.Config.Import.Banned, c.Config.Import.Separators)
+	if c.buffer == nil {
 
-		// Store item to the buffer collection
-		c.buffer.Add(item.Item.Engine.GetName(), item)
+		// Otherwise directly store item to the items collection
+		if err = c.items.Add(id, &item.Item); err != nil {
+			return
+		}
 
-	} else {
+		c.logInput(item, input)
 
-		item.Item.SetData(input)
+		if err = c.StoreItem2DB(&item.Item); err != nil {
+			return
+		}
 
-		// Otherwise directly store item to the items collection
-		err = c.items.Add(id, &item.Item)
+		c.SendCollectionEvent("items", "add", &item.Item)
+		return
 	}
 
-	if err != nil {
-		log.Printf("[%s < %d] new input %s: %s\n", c.Name,
-			item.Item.Id, input.GetRef(), input.GetName())
+	// Add the import to the item
+	item.AddImportData(input)
+
+	// Get Cleaned name
+	item.SetCleanedName(c.Config.Import.Banned, c.Config.Import.Separators)
 
+	// Check the content already imported
+	if c.Config.Import.Duplicates {
+		c.checkDuplicate(item)
 	}
 
-	// Handle data commands called when data is accepted by
-	// collection
-	if err = c.onDataInput(input); err != nil {
+	// Store item to the buffer collection
+	if c.buffer.Add(item) == false {
+		err = fmt.Errorf("already existing item '%s' in buffer",
+			item.Item.Engine.GetName())
 		return
 	}
 
-	// Handle data contents
-	item.Item.LinkToData(input)
+	c.logInput(item, input)
 
-	c.SendCollectionEvent("items", "add", &item.Item)
+	// Item validated automatically once added: already stored as item
+	if c.buffer.Get(item.Id.String()) != item {
+		return
+	}
+
+	if err = c.StoreBufferItem2DB(item); err != nil {
+		return
+	}
+
+	c.SendCollectionEvent("buffer", "add", &item.Item)
 	return
 }
 
+func (c *Collection) logInput(item *BufferItem, input data.Data) {
+	log.Printf("[%s < %d] new input %s: %s\n", c.Name,
+		item.Item.Id, input.GetRef(), input.GetName())
+}
+
+// OnDelete handle data removed from its import: the item waiting in the
+// buffer is dropped, the validated item is removed
+func (c *Collection) OnDelete(id Id, input data.Data) error {
+
+	item, bufferItem := c.findItem(id)
+
+	switch {
+	case bufferItem != nil:
+		c.buffer.Remove(bufferItem.Id.String())
+		c.SendCollectionEvent("buffer", "remove", item)
+
+	case item != nil:
+		if err := c.RemoveItem(id); err != nil {
+			return err
+		}
+
+		c.SendCollectionEvent("items", "remove", item)
+
+	default:
+		return fmt.Errorf("item '%s' not found", id)
+	}
+
+	log.Printf("[%s < %d] removed input %s: %s\n", c.Name,
+		id, input.GetRef(), input.GetName())
+
+	return nil
+}
+
 func (c *Collection) onDataInput(d data.Data) error {
 	// Get data ref name
 	refName := d.GetRef().String()
 
 	// Call method ApplyConfig if it exists
 	if inputData, ok := d.(data.DataConfig); ok {
-		if err := inputData.ApplyConfig(c.Config.Datas[refName]); err != nil {
-			return err
+		if config, ok := c.Config.Datas[refName]; ok {
+			if err := inputData.ApplyConfig(config); err != nil {
+				return err
+			}
 		}
 	}
 
@@ -320,6 +596,11 @@ func (c *Collection) onDataInput(d data.Data) error {
 }
 
 func (c *Collection) SendCollectionEvent(src string, status string, item *Item) {
+	c.SendCollectionEventData(src, status, item, nil)
+}
+
+// SendCollectionEventData send item event with specific data
+func (c *Collection) SendCollectionEventData(src string, status string, item *Item, data interface{}) {
 	if c.events == nil {
 		return
 	}
@@ -329,6 +610,7 @@ func (c *Collection) SendCollectionEvent(src string, status string, item *Item)
 		Status: status,
 		Id:     item.Engine.GetName(),
 		Item:   item,
+		Data:   data,
 	}
 }
 
@@ -341,7 +623,11 @@ func (c *Collection) GetBuffer() []*BufferItem {
 }
 
 func (c *Collection) ResetBuffer() {
-	c.buffer = NewBuffer(c, 2)
+	if c.buffer != nil {
+		c.buffer.RemoveAll()
+	}
+
+	c.buffer = NewBuffer(c, c.Config.Buffer.Size)
 }
 
 func (c *Collection) GetBufferItems() []*Item {
@@ -362,25 +648,198 @@ func (c *Collection) Validate(id string, d data.Data) (item *BufferItem, err err
 		return
 	}
 
-	item = c.buffer.Validate(id)
+	item = c.buffer.Get(id)
 	if item == nil {
 		err = fmt.Errorf("name '%s' not referenced in buffer", id)
 		return
 	}
 
-	// // Store in definitive items
-	// err = c.items.Add(id, &item.Item)
-	// if err != nil {
-	// 	return
-	// }
+	// Keep the buffer item as it was to be able to undo
+	before, err := c.getItemState(item.Id)
+	if err != nil {
+		return
+	}
+
+	c.buffer.Validate(item.Id.String())
+
+	// Merge with the data specified or the website data selected
+	if d == nil {
+		d = item.GetMatch()
+
+		// Get the full record of the website data selected
+		if details, detailsErr := c.GetDetails(item.MatchId); detailsErr != nil {
+			log.Printf("[%s] details %s: %s\n",
+				c.Name, item.MatchId, detailsErr.Error())
+		} else if details != nil {
+			d = details
+		}
+	}
+
+	if d != nil {
+		item.Item.Merge(d)
+		item.Item.SetDetails(d)
+	}
+
+	if _, extractErr := item.Item.Extract(); extractErr != nil {
+		log.Printf("[%s] extract %s: %s\n", c.Name, id, extractErr.Error())
+	}
+
+	// Store in definitive items
+	if err = c.items.Add(item.Item.Id, &item.Item); err != nil {
+		return
+	}
+
+	if err = c.StoreItem2DB(&item.Item); err != nil {
+		return
+	}
 
 	c.SendCollectionEvent("items", "add", &item.Item)
 
+	if err = c.addHistory(HISTORY_VALIDATE, item.Id, before); err != nil {
+		return
+	}
+
 	// TODO: export list
 
 	return
 }
 
+type AutoValidation struct {
+	Item      *BufferItem `json:"item"`
+	Candidate *Candidate  `json:"candidate"`
+	DryRun    bool        `json:"dryRun"`
+}
+
+// AutoValidate validates the buffer item if the best candidate matches
+// the collection configuration
+func (c *Collection) AutoValidate(item *BufferItem) (err error) {
+
+	config := c.Config.Buffer.AutoValidate
+
+	if config.Enable == false ||
+		item.CanAutoValidate(config.Threshold, config.MinLead) == false {
+		return
+	}
+
+	event := &AutoValidation{
+		Item:      item,
+		Candidate: item.Candidates[0],
+		DryRun:    config.DryRun,
+	}
+
+	if config.DryRun == false {
+
+		item.IsAutoValidated = true
+
+		if _, err = c.Validate(item.Id.String(), nil); err != nil {
+			item.IsAutoValidated = false
+			return
+		}
+	}
+
+	log.Printf("[%s] auto-validate %d with %s (%.2f)\n",
+		c.Name, item.Id, event.Candidate.Id, event.Candidate.Score)
+
+	c.SendCollectionEventData("buffer", "auto-validate", &item.Item, event)
+	return
+}
+
+// Unvalidate moves back the validated item to the buffer as it was
+// before its last validation
+func (c *Collection) Unvalidate(id Id) (item *BufferItem, err error) {
+
+	if c.buffer == nil {
+		err = fmt.Errorf("buffer not initialized")
+		return
+	}
+
+	if _, err = c.items.Get(id); err != nil {
+		err = fmt.Errorf("item '%d' not validated", id)
+		return
+	}
+
+	// Search for the last validation of the item
+	var validation *HistoryEntry
+	for _, entry := range c.GetHistory() {
+		if entry.Undone == false && entry.ItemId == id &&
+			entry.Operation == HISTORY_VALIDATE {
+			validation = entry
+		}
+	}
+
+	if validation == nil {
+		err = fmt.Errorf("item '%d' validation not found", id)
+		return
+	}
+
+	before, err := c.getItemState(id)
+	if err != nil {
+		return
+	}
+
+	if err = c.setItemState(id, validation.Before); err != nil {
+		return
+	}
+
+	if err = c.addHistory(HISTORY_UNVALIDATE, id, before); err != nil {
+		return
+	}
+
+	_, item = c.buffer.GetById(id)
+	return
+}
+
+// ApplyTweak sets the item attributes computed by the tweak from the
+// input data
+func (c *Collection) ApplyTweak(id Id, input data.Data, tweak *Tweak) (err error) {
+
+	results, err := tweak.Tweak(map[string]interface{}{
+		input.GetRef().String(): input,
+	})
+	if err != nil {
+		return
+	}
+
+	fields, ok := results["item"]
+	if ok == false || len(fields) == 0 {
+		return
+	}
+
+	item, bufferItem := c.findItem(id)
+	if item == nil {
+		err = fmt.Errorf("item '%d' not existing", id)
+		return
+	}
+
+	before, err := c.getItemState(id)
+	if err != nil {
+		return
+	}
+
+	// Set item fields with their json name
+	src, err := json.Marshal(fields)
+	if err != nil {
+		return
+	}
+
+	if err = json.Unmarshal(src, item); err != nil {
+		return
+	}
+
+	if bufferItem != nil {
+		err = c.StoreBufferItem2DB(bufferItem)
+	} else {
+		err = c.StoreItem2DB(item)
+	}
+	if err != nil {
+		return
+	}
+
+	c.SendCollectionEvent("items", "update", item)
+
+	return c.addHistory(HISTORY_TWEAK, id, before)
+}
+
 func (c *Collection) GetItemByString(idStr string) (*Item, error) {
 	id, err := GetIdFromString(idStr)
 	if err != nil {
@@ -399,12 +858,141 @@ func (c *Collection) GetItems() []*Item {
 	return c.items.GetCurrentList()
 }
 
-func (c *Collection) ModifyItem() {
+// ModifyItem modifies the item attributes specified
+func (c *Collection) ModifyItem(id Id, patch *ItemPatch) (item *Item, err error) {
+
+	if item, err = c.items.Get(id); err != nil {
+		return
+	}
+
+	before, err := c.getItemState(id)
+	if err != nil {
+		return
+	}
+
+	if err = item.Patch(patch); err != nil {
+		return
+	}
+
+	if err = c.StoreItem2DB(item); err != nil {
+		return
+	}
+
+	c.SendCollectionEvent("items", "update", item)
+
+	err = c.addHistory(HISTORY_PATCH, id, before)
+	return
+}
+
+// ModifyBufferItem modifies the buffer item attributes specified: a new
+// research is launched if the cleaned name or the web query is modified
+func (c *Collection) ModifyBufferItem(name string, patch *BufferItemPatch) (item *BufferItem, err error) {
+
+	if c.buffer == nil {
+		err = fmt.Errorf("buffer not initialized")
+		return
+	}
+
+	item = c.buffer.Get(name)
+	if item == nil {
+		err = fmt.Errorf("name '%s' not referenced in buffer", name)
+		return
+	}
+
+	// Check the import to remove
+	var removed data.Data
+	if patch.RemoveImport != nil {
+
+		removed = item.GetImportData(*patch.RemoveImport)
+		if removed == nil {
+			err = fmt.Errorf("import '%s' not found in buffer item '%s'",
+				*patch.RemoveImport, name)
+			return
+		}
+
+		if len(item.Imports) == 1 {
+			err = fmt.Errorf("last import of buffer item '%s' can't be removed", name)
+			return
+		}
+	}
+
+	search := patch.CleanedName != nil || patch.WebQuery != nil
+
+	// Check the match selected with the current candidates
+	if patch.MatchId != nil && search == false {
+		if err = item.SelectMatch(*patch.MatchId); err != nil {
+			return
+		}
+	}
+
+	before, err := c.getItemState(item.Id)
+	if err != nil {
+		return
+	}
+
+	if removed != nil {
+		item.RemoveImportData(removed)
+		item.LinkImports()
+	}
+
+	if patch.CleanedName != nil {
+		item.CleanedName = *patch.CleanedName
+		item.WebQuery = strings.Replace(item.CleanedName, " ", "+", -1)
+	}
+
+	if patch.WebQuery != nil {
+		item.WebQuery = *patch.WebQuery
+	}
+
+	if search {
+
+		// Previous web results are outdated: the item is stored & sent
+		// again with the new candidates
+		item.Websites = nil
+		c.Search("buffer", item)
+
+		// Select the match within the new candidates
+		if patch.MatchId != nil {
+			if err = item.SelectMatch(*patch.MatchId); err != nil {
+				return
+			}
+		}
+	}
+
+	if search == false || patch.MatchId != nil {
 
+		if err = c.StoreBufferItem2DB(item); err != nil {
+			return
+		}
+
+		c.SendCollectionEventData("buffer", "update", &item.Item, item)
+	}
+
+	err = c.addHistory(HISTORY_PATCH, item.Id, before)
+	return
 }
 
+// RemoveItem deletes the item, the deletion can be undone
 func (c *Collection) RemoveItem(id Id) error {
-	return c.items.Remove(id)
+
+	before, err := c.getItemState(id)
+	if err != nil {
+		return err
+	}
+
+	if err = c.removeItem(id); err != nil {
+		return err
+	}
+
+	return c.addHistory(HISTORY_DELETE, id, before)
+}
+
+func (c *Collection) removeItem(id Id) error {
+	if err := c.items.Remove(id); err != nil {
+		return err
+	}
+
+	return c.DeleteItem2DB(id)
 }
 
 func (c *Collection) SetExports(exports []exports.Export) {
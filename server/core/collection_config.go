@@ -18,12 +18,21 @@ type CollectionConfig struct {
 	Buffer struct {
 		Enable bool `json:"enable"`
 		Size   int  `json:"size"`
+
+		// Validate automatically the best candidates
+		AutoValidate struct {
+			Enable    bool    `json:"enable"`
+			Threshold float32 `json:"threshold" comments:"minimum matching score"`
+			MinLead   float32 `json:"minLead" comments:"minimum score lead over the second candidate"`
+			DryRun    bool    `json:"dryRun" comments:"only send auto-validate events"`
+		} `json:"autoValidate"`
 	} `json:"buffer"`
 
 	Import struct {
 		Filters    reference.StringList `json:"filters" kind:"stringlist"`
 		Separators reference.StringList `json:"separators" kind:"stringlist"`
 		Banned     reference.StringList `json:"banned" kind:"stringlist"`
+		Duplicates bool                 `json:"duplicates" comments:"flag the inputs with a content already imported"`
 	} `json:"import"`
 
 	Datas data.Configs `json:"datas"`
@@ -40,6 +49,8 @@ func NewCollectionConfig() *CollectionConfig {
 
 	// Default buffer config
 	config.Buffer.Size = 2
+	config.Buffer.AutoValidate.Threshold = 0.9
+	config.Buffer.AutoValidate.MinLead = 0.1
 
 	return config
 }
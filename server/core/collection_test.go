@@ -0,0 +1,457 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/ohohleo/classify/collections"
+	"github.com/ohohleo/classify/data"
+	"github.com/ohohleo/classify/websites"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeWebsite struct {
+	ref     websites.Ref
+	results []data.Data
+}
+
+func (f *fakeWebsite) GetRef() websites.Ref {
+	return f.ref
+}
+
+func (f *fakeWebsite) SetConfig(map[string]string) bool {
+	return true
+}
+
+func (f *fakeWebsite) Search(string) chan data.Data {
+	c := make(chan data.Data)
+
+	go func() {
+		for _, d := range f.results {
+			c <- d
+		}
+		close(c)
+	}()
+
+	return c
+}
+
+func (f *fakeWebsite) Details(id string) (data.Data, error) {
+
+	for _, d := range f.results {
+
+		if movie, ok := d.(*data.Movie); ok && movie.Id == id {
+
+			details := *movie
+			details.Directors = []string{"director " + id}
+			return &details, nil
+		}
+	}
+
+	return nil, fmt.Errorf("movie '%s' not found", id)
+}
+
+func newTestCollection(t *testing.T, config string) *Collection {
+
+	c, events, err := NewClassify(&Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Ignore all events
+	go func() {
+		for range events {
+		}
+	}()
+
+	collection, err := c.CreateCollection("test", collections.MOVIES,
+		[]byte(config), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	collection.AddWebsite(&fakeWebsite{
+		ref: websites.TMDB,
+		results: []data.Data{
+			&data.Movie{
+				Id:       "1",
+				Name:     "Looper",
+				Released: time.Date(2012, 9, 28, 0, 0, 0, 0, time.UTC),
+			},
+			&data.Movie{
+				Id:       "2",
+				Name:     "The Loop",
+				Released: time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC),
+			},
+		},
+	})
+
+	return collection
+}
+
+// Returns the number of collection events received by source & status
+func countEvents(events chan CollectionEvent) map[string]int {
+
+	res := make(map[string]int)
+	for {
+		select {
+		case event := <-events:
+			res[event.Source+" "+event.Status]++
+		default:
+			return res
+		}
+	}
+}
+
+func TestAutoValidate(t *testing.T) {
+
+	assert := assert.New(t)
+
+	collection := newTestCollection(t, `{
+  "buffer": {"enable": true, "autoValidate": {"enable": true}},
+  "import": {"separators": ["."]}
+}`)
+
+	events := make(chan CollectionEvent, 100)
+	collection.events = events
+
+	input := &data.File{Name: "Looper.2012"}
+	id := Id(data.GetId(input))
+
+	_, err := collection.OnInput(id, input)
+	assert.Nil(err)
+
+	// Item automatically validated & merged with the website data
+	assert.Len(collection.GetBuffer(), 0)
+
+	// Item added only once
+	counts := countEvents(events)
+	assert.Equal(1, counts["items add"])
+	assert.Equal(0, counts["buffer add"])
+
+	item, err := collection.GetItem(id)
+	if assert.Nil(err) {
+		assert.Equal("Looper", item.Name)
+		assert.Equal(2012, item.Date.Year())
+
+		// Full website record kept with the item
+		assert.Equal("movie", item.DetailsRef)
+		if movie, ok := item.Details.(*data.Movie); assert.True(ok) {
+			assert.Equal([]string{"director 1"}, movie.Directors)
+		}
+
+		src, err := json.Marshal(item)
+		assert.Nil(err)
+
+		stored, err := NewItemFromJSON(src)
+		if assert.Nil(err) {
+			assert.Equal(item.Details, stored.Details)
+		}
+	}
+
+	// Move it back to the buffer
+	bufferItem, err := collection.Unvalidate(id)
+	if assert.Nil(err) {
+		assert.Equal("", bufferItem.Name)
+		assert.True(bufferItem.IsAutoValidated)
+	}
+
+	_, err = collection.GetItem(id)
+	assert.NotNil(err)
+
+	// Not validated automatically again
+	buffer := collection.GetBuffer()
+	if assert.Len(buffer, 1) {
+		assert.Equal(id, buffer[0].Id)
+		assert.Equal("TMDB:1", buffer[0].MatchId)
+	}
+
+	// Item kept in the buffer
+	countEvents(events)
+	other := &data.File{Name: "Unknown"}
+	_, err = collection.OnInput(Id(data.GetId(other)), other)
+	assert.Nil(err)
+	assert.Len(collection.GetBuffer(), 2)
+
+	counts = countEvents(events)
+	assert.Equal(0, counts["items add"])
+	assert.Equal(1, counts["buffer add"])
+
+	_, err = collection.Unvalidate(id)
+	assert.NotNil(err)
+}
+
+func TestAutoValidateDryRun(t *testing.T) {
+
+	assert := assert.New(t)
+
+	collection := newTestCollection(t, `{
+  "buffer": {"enable": true, "autoValidate": {"enable": true, "dryRun": true}},
+  "import": {"separators": ["."]}
+}`)
+
+	input := &data.File{Name: "Looper.2012"}
+	_, err := collection.OnInput(Id(data.GetId(input)), input)
+	assert.Nil(err)
+
+	assert.Len(collection.GetBuffer(), 1)
+	assert.Len(collection.GetItems(), 0)
+
+	// Second candidate too close
+	collection = newTestCollection(t, `{
+  "buffer": {"enable": true, "autoValidate": {"enable": true, "minLead": 1}},
+  "import": {"separators": ["."]}
+}`)
+
+	_, err = collection.OnInput(Id(data.GetId(input)), input)
+	assert.Nil(err)
+
+	assert.Len(collection.GetBuffer(), 1)
+	assert.Len(collection.GetItems(), 0)
+}
+
+func TestModifyBufferItem(t *testing.T) {
+
+	assert := assert.New(t)
+
+	collection := newTestCollection(t, `{
+  "buffer": {"enable": true},
+  "import": {"separators": ["."]}
+}`)
+
+	input := &data.File{Name: "Looper.2012"}
+	name := input.GetName()
+
+	_, err := collection.OnInput(Id(data.GetId(input)), input)
+	assert.Nil(err)
+
+	// Select another candidate
+	other := "TMDB:2"
+	item, err := collection.ModifyBufferItem(name, &BufferItemPatch{
+		MatchId: &other,
+	})
+	if assert.Nil(err) {
+		assert.Equal("TMDB:2", item.MatchId)
+		assert.Equal("The Loop", item.GetMatch().GetName())
+	}
+
+	unknown := "TMDB:3"
+	_, err = collection.ModifyBufferItem(name, &BufferItemPatch{
+		MatchId: &unknown,
+	})
+	assert.NotNil(err)
+
+	// New research with the cleaned name
+	cleanedName := "The Loop 2000"
+	item, err = collection.ModifyBufferItem(name, &BufferItemPatch{
+		CleanedName: &cleanedName,
+	})
+	if assert.Nil(err) {
+		assert.Equal("The+Loop+2000", item.WebQuery)
+		assert.Equal("TMDB:2", item.MatchId)
+	}
+
+	// Last import can't be removed
+	importId := strconv.FormatUint(data.GetId(input), 10)
+	_, err = collection.ModifyBufferItem(name, &BufferItemPatch{
+		RemoveImport: &importId,
+	})
+	assert.NotNil(err)
+
+	_, err = collection.ModifyBufferItem("unknown", &BufferItemPatch{})
+	assert.NotNil(err)
+
+	history := collection.GetHistory()
+	if assert.Len(history, 2) {
+		assert.Equal(HISTORY_PATCH, history[0].Operation)
+		assert.Equal(HISTORY_PATCH, history[1].Operation)
+	}
+}
+
+func TestModifyItem(t *testing.T) {
+
+	assert := assert.New(t)
+
+	collection := newTestCollection(t, `{}`)
+
+	input := &data.File{Name: "Looper.2012", Path: "/tmp"}
+	id := Id(data.GetId(input))
+
+	_, err := collection.OnInput(id, input)
+	assert.Nil(err)
+
+	name, country := "Looper", "FR"
+	date := time.Date(2012, 9, 28, 0, 0, 0, 0, time.UTC)
+
+	item, err := collection.ModifyItem(id, &ItemPatch{
+		Name:    &name,
+		Date:    &date,
+		Country: &country,
+		Data: map[string]json.RawMessage{
+			"path": json.RawMessage(`"/movies"`),
+		},
+	})
+	if assert.Nil(err) {
+		assert.Equal("Looper", item.Name)
+		assert.Equal(date, item.Date)
+		assert.Equal("FR", item.Country.Codes.Alpha2)
+		assert.Equal("/movies", item.Engine.(*data.File).Path)
+	}
+
+	// Invalid fields: nothing modified
+	other := "Other"
+	for _, patch := range []*ItemPatch{
+		&ItemPatch{
+			Name: &other,
+			Data: map[string]json.RawMessage{
+				"unknown": json.RawMessage(`"value"`),
+			},
+		},
+		&ItemPatch{
+			Name: &other,
+			Data: map[string]json.RawMessage{
+				"path": json.RawMessage(`1`),
+			},
+		},
+		&ItemPatch{
+			Name:    &other,
+			Country: &other,
+		},
+	} {
+		_, err = collection.ModifyItem(id, patch)
+		assert.NotNil(err)
+	}
+
+	item, err = collection.GetItem(id)
+	if assert.Nil(err) {
+		assert.Equal("Looper", item.Name)
+		assert.Equal("/movies", item.Engine.(*data.File).Path)
+	}
+
+	// Modification can be undone
+	_, err = collection.Undo()
+	assert.Nil(err)
+
+	item, err = collection.GetItem(id)
+	if assert.Nil(err) {
+		assert.Equal("", item.Name)
+		assert.Equal("/tmp", item.Engine.(*data.File).Path)
+	}
+}
+
+func TestOnDelete(t *testing.T) {
+
+	assert := assert.New(t)
+
+	collection := newTestCollection(t, `{
+  "buffer": {"enable": true},
+  "import": {"separators": ["."]}
+}`)
+
+	input := &data.File{Name: "Looper.2012"}
+	id := Id(data.GetId(input))
+	deletion := &data.Deletion{Data: &data.File{Name: "Looper.2012"}}
+
+	// Item waiting in the buffer
+	_, err := collection.OnInput(id, input)
+	assert.Nil(err)
+	assert.Len(collection.GetBuffer(), 1)
+
+	assert.Nil(collection.OnDelete(Id(data.GetId(deletion)), deletion))
+	assert.Len(collection.GetBuffer(), 0)
+
+	// Validated item
+	_, err = collection.OnInput(id, input)
+	assert.Nil(err)
+	_, err = collection.Validate(input.GetName(), nil)
+	assert.Nil(err)
+	assert.Len(collection.GetItems(), 1)
+
+	assert.Nil(collection.OnDelete(id, deletion))
+	assert.Len(collection.GetItems(), 0)
+
+	history := collection.GetHistory()
+	if assert.Len(history, 2) {
+		assert.Equal(HISTORY_DELETE, history[1].Operation)
+	}
+
+	assert.NotNil(collection.OnDelete(id, deletion))
+}
+
+func TestArchiveMembers(t *testing.T) {
+
+	assert := assert.New(t)
+
+	collection := newTestCollection(t, `{}`)
+
+	// Same member name in two archives
+	var members []*data.ArchiveMember
+	for _, name := range []string{"a.zip", "b.zip"} {
+		member := data.NewArchiveMember("CD1/track01.mp3", 0, time.Time{})
+		member.Archive = &data.File{Name: name, Path: "/music/" + name}
+		members = append(members, member)
+	}
+
+	for _, member := range members {
+		_, err := collection.OnInput(Id(data.GetId(member)), member)
+		assert.Nil(err)
+	}
+	assert.Len(collection.GetItems(), 2)
+
+	// Only the member of the archive removed is deleted
+	deletion := &data.Deletion{Data: members[0]}
+	assert.Nil(collection.OnDelete(Id(data.GetId(deletion)), deletion))
+	if items := collection.GetItems(); assert.Len(items, 1) {
+		assert.Equal(Id(data.GetId(members[1])), items[0].Id)
+	}
+}
+
+func TestSameTitleFeedEntries(t *testing.T) {
+
+	assert := assert.New(t)
+
+	collection := newTestCollection(t, `{}`)
+
+	for _, guid := range []string{"podcast-1", "podcast-2"} {
+		entry := &data.FeedEntry{Guid: guid, Title: "Trailer"}
+		_, err := collection.OnInput(Id(data.GetId(entry)), entry)
+		assert.Nil(err, guid)
+	}
+	assert.Len(collection.GetItems(), 2)
+
+	// Same entry received again
+	entry := &data.FeedEntry{Guid: "podcast-1", Title: "Trailer"}
+	_, err := collection.OnInput(Id(data.GetId(entry)), entry)
+	assert.NotNil(err)
+}
+
+func TestBufferItemJSON(t *testing.T) {
+
+	assert := assert.New(t)
+
+	input := &data.File{Name: "Looper.2012"}
+
+	item := NewBufferItem(Id(data.GetId(input)))
+	item.Item.SetData(input)
+	item.AddImportData(input)
+	item.Item.SetDetails(&data.Movie{
+		Id:        "1",
+		Name:      "Looper",
+		Directors: []string{"Rian Johnson"},
+	})
+
+	src, err := item.ToJSON()
+	if !assert.Nil(err) {
+		return
+	}
+
+	stored, err := NewBufferItemFromJSON(src)
+	if assert.Nil(err) {
+		assert.Equal("movie", stored.DetailsRef)
+		assert.Equal(item.Item.Details, stored.Item.Details)
+		assert.Equal(item.Imports, stored.Imports)
+	}
+}
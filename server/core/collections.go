@@ -99,6 +99,8 @@ func (c *Classify) CreateCollection(name string, ref collections.Ref, config jso
 		}
 	}
 
+	// Enable buffer if needed
+	err = collection.UpdateBuffer()
 	return
 }
 
@@ -126,11 +128,12 @@ func (c *Classify) AddCollection(name string, ref collections.Ref, config json.R
 	eventsChannel := make(chan CollectionEvent)
 
 	collection = &Collection{
-		Name:   name,
-		items:  NewItems(),
-		Config: NewCollectionConfig(),
-		Engine: collectionEngine,
-		events: eventsChannel,
+		Name:     name,
+		items:    NewItems(),
+		database: c.database,
+		Config:   NewCollectionConfig(),
+		Engine:   collectionEngine,
+		events:   eventsChannel,
 	}
 
 	// Store configuration received
@@ -139,6 +142,10 @@ func (c *Classify) AddCollection(name string, ref collections.Ref, config json.R
 		if err != nil {
 			return
 		}
+
+		if err = collection.UpdateFilters(); err != nil {
+			return
+		}
 	}
 
 	if c.Collections == nil {
@@ -153,8 +160,13 @@ func (c *Classify) AddCollection(name string, ref collections.Ref, config json.R
 		for {
 			event, ok := <-eventsChannel
 			if ok {
+				var data interface{} = event.Item
+				if event.Data != nil {
+					data = event.Data
+				}
+
 				c.SendEvent("collection/"+name+"/"+event.Source,
-					event.Status, event.Id, event.Item)
+					event.Status, event.Id, data)
 			}
 		}
 	}()
@@ -162,6 +174,16 @@ func (c *Classify) AddCollection(name string, ref collections.Ref, config json.R
 	return
 }
 
+// Send the buffer items waiting for a free slot
+func (c *Classify) sendBuffers() {
+
+	for _, collection := range c.Collections {
+		if collection.buffer != nil {
+			collection.buffer.SendNext("")
+		}
+	}
+}
+
 // Remove an existing collection
 func (c *Classify) DeleteCollection(name string) (err error) {
 
@@ -33,6 +33,18 @@ func (c *Classify) StartDB(config *Config) (err error) {
 		return
 	}
 
+	if err = c.database.AddItemsTable("items"); err != nil {
+		return
+	}
+
+	if err = c.database.AddItemsTable("buffers"); err != nil {
+		return
+	}
+
+	if err = c.database.AddItemsTable("histories"); err != nil {
+		return
+	}
+
 	// Create tables
 	if err = c.database.Create(); err != nil {
 		return
@@ -91,7 +103,28 @@ func (c *Classify) StartDB(config *Config) (err error) {
 
 			// Store database id
 			i.Id = id
-			return
+
+			// Plain credentials stored before the secrets handling
+			if c.secrets != nil {
+				isProtected, err := c.secrets.Protect(i.engine, "imports/"+i.Name)
+				if err != nil {
+					return err
+				}
+
+				if isProtected {
+					if err := i.StoreParams2DB(c.database); err != nil {
+						return err
+					}
+				}
+			}
+
+			// Restore the state of the previous imports
+			if err := i.RetreiveDBState(c.database); err != nil {
+				return err
+			}
+
+			// Restore the schedule of the import runs
+			return i.RetreiveDBSchedule(c.database)
 		})
 	if err != nil {
 		return
@@ -125,6 +158,28 @@ func (c *Classify) StartDB(config *Config) (err error) {
 		if err = collection.Config.Update(config); err != nil {
 			return
 		}
+
+		// Inputs refused until the filters are modified
+		if filtersErr := collection.UpdateFilters(); filtersErr != nil {
+			log.Printf("[%s] %s\n", collection.Name, filtersErr.Error())
+		}
+	}
+
+	// Retreive all stored collection items
+	for _, collection := range c.Collections {
+
+		if err = collection.RetreiveDBItems(); err != nil {
+			return
+		}
+
+		if err = collection.RetreiveDBHistory(); err != nil {
+			return
+		}
+
+		// Enable buffer & restore buffer items
+		if err = collection.UpdateBuffer(); err != nil {
+			return
+		}
 	}
 
 	return
@@ -0,0 +1,506 @@
+package core
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ohohleo/classify/collections"
+	"github.com/ohohleo/classify/data"
+	"github.com/ohohleo/classify/database"
+	"github.com/ohohleo/classify/imports"
+	"github.com/ohohleo/classify/secrets"
+	"github.com/ohohleo/classify/websites"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestClassify(t *testing.T, source string) *Classify {
+
+	config := &Config{
+		DataBase: database.Config{
+			Enable: true,
+			Driver: "sqlite3",
+			Source: source,
+		},
+	}
+
+	c, events, err := NewClassify(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Ignore all events
+	go func() {
+		for range events {
+		}
+	}()
+
+	return c
+}
+
+func TestItemsDB(t *testing.T) {
+
+	assert := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "classify")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	source := filepath.Join(dir, "classify.db")
+
+	c := newTestClassify(t, source)
+
+	collection, err := c.CreateCollection("test", collections.SIMPLE, nil, nil)
+	if !assert.Nil(err) {
+		return
+	}
+
+	// Items directly stored without buffer
+	generic := &data.Generic{
+		Name: "generic",
+		Time: time.Date(2019, 12, 22, 0, 0, 0, 0, time.UTC),
+	}
+
+	_, err = collection.OnInput(Id(data.GetId(generic)), generic)
+	assert.Nil(err)
+
+	other := &data.Generic{Name: "other"}
+	otherId := Id(data.GetId(other))
+
+	_, err = collection.OnInput(otherId, other)
+	assert.Nil(err)
+
+	assert.Nil(collection.RemoveItem(otherId))
+
+	// Restart with the same database
+	c = newTestClassify(t, source)
+
+	collection, err = c.GetCollection("test")
+	if !assert.Nil(err) {
+		return
+	}
+
+	items := collection.GetItems()
+	if assert.Len(items, 1) {
+		assert.Equal(Id(data.GetId(generic)), items[0].Id)
+		assert.Equal("generic", items[0].Ref)
+		assert.Equal(generic, items[0].Engine)
+	}
+}
+
+func TestBufferDB(t *testing.T) {
+
+	assert := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "classify")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	source := filepath.Join(dir, "classify.db")
+
+	c := newTestClassify(t, source)
+
+	collection, err := c.CreateCollection("test", collections.SIMPLE,
+		[]byte(`{"buffer":{"enable":true,"size":1},"import":{"separators":["."]}}`), nil)
+	if !assert.Nil(err) {
+		return
+	}
+
+	first := &data.Generic{Name: "The.First"}
+	second := &data.Generic{Name: "The.Second"}
+
+	for _, input := range []*data.Generic{first, second} {
+		_, err = collection.OnInput(Id(data.GetId(input)), input)
+		assert.Nil(err)
+		time.Sleep(time.Millisecond)
+	}
+
+	sent := collection.GetBuffer()
+	if !assert.Len(sent, 1) {
+		return
+	}
+
+	// Restart with the same database
+	c = newTestClassify(t, source)
+
+	collection, err = c.GetCollection("test")
+	if !assert.Nil(err) {
+		return
+	}
+
+	assert.Len(collection.buffer.items, 2)
+
+	buffer := collection.GetBuffer()
+	if assert.Len(buffer, 1) {
+		assert.Equal(sent[0].Id, buffer[0].Id)
+		assert.Equal(BUFFER_SENDING, buffer[0].Status)
+		assert.Equal(sent[0].CleanedName, buffer[0].CleanedName)
+		assert.Equal([]string{"."}, buffer[0].Separators)
+		assert.Len(buffer[0].Imports, 1)
+	}
+
+	// Validate the item sent
+	_, err = collection.Validate(buffer[0].Item.Engine.GetName(), nil)
+	assert.Nil(err)
+
+	// Restart again: validated item is no more in the buffer
+	c = newTestClassify(t, source)
+
+	collection, err = c.GetCollection("test")
+	if !assert.Nil(err) {
+		return
+	}
+
+	assert.Len(collection.GetItems(), 1)
+	assert.Len(collection.buffer.items, 1)
+	assert.Len(collection.GetBuffer(), 1)
+}
+
+func TestBufferRestoreDB(t *testing.T) {
+
+	assert := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "classify")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	source := filepath.Join(dir, "classify.db")
+
+	c := newTestClassify(t, source)
+
+	collection, err := c.CreateCollection("test", collections.SIMPLE,
+		[]byte(`{"buffer": {"enable": true, "size": 2}}`), nil)
+	if !assert.Nil(err) {
+		return
+	}
+
+	// Items stored still waiting: the oldest first
+	date := time.Now()
+	names := []string{"Looper", "The Loop", "Brick"}
+	for idx, name := range names {
+		item := NewBufferItem(Id(idx + 1))
+		item.Item.SetData(&data.File{Name: name})
+		item.CreatedAt = date.Add(time.Duration(idx) * time.Minute)
+		item.Status = BUFFER_WAITING
+		assert.Nil(collection.StoreBufferItem2DB(item))
+	}
+
+	// Free slots of the buffer restored filled once started
+	c, events, err := NewClassify(&Config{
+		DataBase: database.Config{
+			Enable: true,
+			Driver: "sqlite3",
+			Source: source,
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var sent []string
+	for timeout := time.After(5 * time.Second); len(sent) < 2; {
+		select {
+		case event := <-events:
+			if event.Event == "collection/test/buffer" && event.Status == "update" {
+				sent = append(sent, event.Name)
+			}
+		case <-timeout:
+			t.Fatal("buffer items not sent")
+		}
+	}
+
+	assert.Equal(names[:2], sent)
+
+	go func() {
+		for range events {
+		}
+	}()
+}
+
+func TestImportStateDB(t *testing.T) {
+
+	assert := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "classify")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	source := filepath.Join(dir, "classify.db")
+	path := filepath.Join(dir, "files")
+	assert.Nil(os.Mkdir(path, 0755))
+	assert.Nil(ioutil.WriteFile(filepath.Join(path, "file.txt"), nil, 0644))
+
+	c := newTestClassify(t, source)
+
+	collection, err := c.CreateCollection("test", collections.SIMPLE, nil, nil)
+	if !assert.Nil(err) {
+		return
+	}
+
+	i, _, err := c.CreateImport("files", imports.DIRECTORY,
+		[]byte(`{"path": "`+path+`"}`),
+		map[string]*Collection{"test": collection})
+	if !assert.Nil(err) {
+		return
+	}
+
+	assert.Nil(c.StartImports(nil, nil))
+
+	// Wait for the end of the import
+	var state []byte
+	for retry := 0; retry < 100 && state == nil; retry++ {
+		time.Sleep(10 * time.Millisecond)
+		state, err = imports.RetreiveDBState(c.database, i.Id)
+		assert.Nil(err)
+	}
+	assert.Contains(string(state), filepath.Join(path, "file.txt"))
+	assert.Len(collection.GetItems(), 1)
+
+	// Restart with the same database: state restored
+	c = newTestClassify(t, source)
+
+	i, err = c.GetImportByName("files")
+	if assert.Nil(err) {
+		restored, err := i.GetEngine().(imports.HasState).GetState()
+		assert.Nil(err)
+		assert.Equal(string(state), string(restored))
+	}
+}
+
+func TestAutoValidateDB(t *testing.T) {
+
+	assert := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "classify")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	source := filepath.Join(dir, "classify.db")
+
+	c := newTestClassify(t, source)
+
+	collection, err := c.CreateCollection("test", collections.MOVIES,
+		[]byte(`{"buffer":{"enable":true,"autoValidate":{"enable":true}},"import":{"separators":["."]}}`), nil)
+	if !assert.Nil(err) {
+		return
+	}
+
+	collection.AddWebsite(&fakeWebsite{
+		ref: websites.TMDB,
+		results: []data.Data{
+			&data.Movie{Id: "1", Name: "Looper"},
+		},
+	})
+
+	input := &data.File{Name: "Looper.2012"}
+	id := Id(data.GetId(input))
+
+	_, err = collection.OnInput(id, input)
+	assert.Nil(err)
+	assert.Len(collection.GetBuffer(), 0)
+
+	// Restart with the same database: item validated only
+	c = newTestClassify(t, source)
+
+	collection, err = c.GetCollection("test")
+	if !assert.Nil(err) {
+		return
+	}
+
+	assert.Len(collection.buffer.items, 0)
+
+	item, err := collection.GetItem(id)
+	if assert.Nil(err) {
+		assert.Equal("Looper", item.Name)
+		if movie, ok := item.Details.(*data.Movie); assert.True(ok) {
+			assert.Equal([]string{"director 1"}, movie.Directors)
+		}
+	}
+}
+
+func TestImportStateWatchDB(t *testing.T) {
+
+	assert := assert.New(t)
+
+	stateStoreDelay = 50 * time.Millisecond
+
+	dir, err := ioutil.TempDir("", "classify")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	source := filepath.Join(dir, "classify.db")
+	path := filepath.Join(dir, "files")
+	assert.Nil(os.Mkdir(path, 0755))
+	assert.Nil(ioutil.WriteFile(filepath.Join(path, "file.txt"), nil, 0644))
+
+	c := newTestClassify(t, source)
+
+	collection, err := c.CreateCollection("test", collections.SIMPLE, nil, nil)
+	if !assert.Nil(err) {
+		return
+	}
+
+	i, _, err := c.CreateImport("files", imports.DIRECTORY,
+		[]byte(`{"path": "`+path+`", "watch": true}`),
+		map[string]*Collection{"test": collection})
+	if !assert.Nil(err) {
+		return
+	}
+
+	assert.Nil(c.StartImports(nil, nil))
+	defer c.StopImports(nil, nil)
+
+	// State stored while still watching
+	var state []byte
+	for retry := 0; retry < 100 && state == nil; retry++ {
+		time.Sleep(10 * time.Millisecond)
+		state, err = imports.RetreiveDBState(c.database, i.Id)
+		assert.Nil(err)
+	}
+	assert.Contains(string(state), filepath.Join(path, "file.txt"))
+	assert.True(i.IsRunning())
+}
+
+func TestImportRescanDB(t *testing.T) {
+
+	for _, isHashed := range []bool{false, true} {
+
+		assert := assert.New(t)
+
+		dir, err := ioutil.TempDir("", "classify")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(dir)
+
+		path := filepath.Join(dir, "files")
+		assert.Nil(os.Mkdir(path, 0755))
+		assert.Nil(ioutil.WriteFile(filepath.Join(path, "file.txt"), []byte("a"), 0644))
+
+		c := newTestClassify(t, filepath.Join(dir, "classify.db"))
+
+		collection, err := c.CreateCollection("test", collections.SIMPLE, nil, nil)
+		if !assert.Nil(err) {
+			return
+		}
+
+		params, _ := json.Marshal(map[string]interface{}{
+			"path": path,
+			"hash": isHashed,
+		})
+
+		i, _, err := c.CreateImport("files", imports.DIRECTORY, params,
+			map[string]*Collection{"test": collection})
+		if !assert.Nil(err) {
+			return
+		}
+
+		run := func() []*Item {
+			assert.Nil(c.StartImports(nil, nil))
+			assert.True(waitFor(5*time.Second, func() bool {
+				return i.IsRunning() == false
+			}))
+			return collection.GetItems()
+		}
+
+		items := run()
+		if !assert.Len(items, 1) {
+			return
+		}
+		id := items[0].Id
+
+		// Modified file: the item imported is replaced
+		date := time.Now().Add(time.Hour)
+		assert.Nil(ioutil.WriteFile(filepath.Join(path, "file.txt"), []byte("ab"), 0644))
+		assert.Nil(os.Chtimes(filepath.Join(path, "file.txt"), date, date))
+
+		items = run()
+		if assert.Len(items, 1, "hash %t", isHashed) {
+			assert.Equal(isHashed, items[0].Id != id)
+			if file, ok := items[0].Engine.(*data.File); assert.True(ok) {
+				assert.Equal(int64(2), file.FileInfo.Size())
+			}
+		}
+	}
+}
+
+func TestImportSecretsDB(t *testing.T) {
+
+	assert := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "classify")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	source := filepath.Join(dir, "classify.db")
+
+	// Password stored in plain text without secrets
+	c := newTestClassify(t, source)
+
+	collection, err := c.CreateCollection("test", collections.SIMPLE, nil, nil)
+	if !assert.Nil(err) {
+		return
+	}
+
+	i, _, err := c.CreateImport("mails", imports.IMAP,
+		[]byte(`{"host":"localhost","login":"username","password":"password","mailbox":"INBOX"}`),
+		map[string]*Collection{"test": collection})
+	if !assert.Nil(err) {
+		return
+	}
+
+	var params []string
+	assert.Nil(c.database.Select(&params, "SELECT params FROM imports WHERE id = ?", i.Id))
+	if assert.Len(params, 1) {
+		assert.Contains(params[0], `"password":"password"`)
+	}
+
+	// Restart with secrets: password moved to the secrets
+	c, events, err := NewClassify(&Config{
+		DataBase: database.Config{
+			Enable: true,
+			Driver: "sqlite3",
+			Source: source,
+		},
+		Secrets: secrets.Config{
+			Path:    filepath.Join(dir, "secrets.json"),
+			KeyFile: filepath.Join(dir, "key"),
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	go func() {
+		for range events {
+		}
+	}()
+
+	params = nil
+	assert.Nil(c.database.Select(&params, "SELECT params FROM imports WHERE id = ?", i.Id))
+	if assert.Len(params, 1) {
+		assert.NotContains(params[0], `"password":"password"`)
+		assert.Contains(params[0], `"password":"secret:imports/mails/password"`)
+	}
+
+	names, err := c.GetSecretNames()
+	assert.Nil(err)
+	assert.Equal([]string{"imports/mails/password"}, names)
+}
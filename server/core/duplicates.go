@@ -0,0 +1,296 @@
+package core
+
+import (
+	"log"
+	"sort"
+
+	"github.com/ohohleo/classify/data"
+)
+
+// Index of the items contents: the items are grouped by partial hash, the
+// full hashes are only computed to confirm the duplicates
+type Duplicates struct {
+	partials map[Id]string
+	hashes   map[Id]string
+	groups   map[string]map[Id]struct{}
+}
+
+// Item found with the same content
+type DuplicateItem struct {
+	Id       Id     `json:"id,string"`
+	Name     string `json:"name"`
+	Location string `json:"location"`
+}
+
+// Items sharing the same content
+type Duplicate struct {
+	Hash  string           `json:"hash"`
+	Items []*DuplicateItem `json:"items"`
+}
+
+func NewDuplicates() *Duplicates {
+	return &Duplicates{
+		partials: make(map[Id]string),
+		hashes:   make(map[Id]string),
+		groups:   make(map[string]map[Id]struct{}),
+	}
+}
+
+// Add the item content to the index
+func (d *Duplicates) Add(id Id, h data.HasHash) error {
+
+	partial, err := h.GetPartialHash()
+	if err != nil {
+		return err
+	}
+
+	if previous, ok := d.partials[id]; ok {
+		if previous == partial {
+			return nil
+		}
+		d.Remove(id)
+	}
+
+	group, ok := d.groups[partial]
+	if ok == false {
+		group = make(map[Id]struct{})
+		d.groups[partial] = group
+	}
+
+	group[id] = struct{}{}
+	d.partials[id] = partial
+	return nil
+}
+
+// Remove the item content from the index
+func (d *Duplicates) Remove(id Id) {
+
+	partial, ok := d.partials[id]
+	if ok == false {
+		return
+	}
+
+	delete(d.groups[partial], id)
+	if len(d.groups[partial]) == 0 {
+		delete(d.groups, partial)
+	}
+
+	delete(d.partials, id)
+	delete(d.hashes, id)
+}
+
+// Returns the full hash of the item content
+func (d *Duplicates) getHash(id Id, h data.HasHash) (hash string, err error) {
+
+	hash, ok := d.hashes[id]
+	if ok {
+		return
+	}
+
+	if hash, err = h.GetHash(); err != nil {
+		return
+	}
+
+	d.hashes[id] = hash
+	return
+}
+
+// Returns the item content hashed if handled
+func getHashData(item *Item) (h data.HasHash, ok bool) {
+
+	if item == nil || item.Engine == nil {
+		return
+	}
+
+	h, ok = item.Engine.(data.HasHash)
+	return
+}
+
+// Index the contents of all the items & remove the items not existing
+// anymore
+func (c *Collection) indexDuplicates() {
+
+	if c.duplicates == nil {
+		c.duplicates = NewDuplicates()
+	}
+
+	existing := make(map[Id]struct{})
+
+	add := func(item *Item) {
+
+		h, ok := getHashData(item)
+		if ok == false {
+			return
+		}
+
+		existing[item.Id] = struct{}{}
+
+		if _, ok := c.duplicates.partials[item.Id]; ok {
+			return
+		}
+
+		if err := c.duplicates.Add(item.Id, h); err != nil {
+			log.Printf("[%s] duplicates %d: %s\n", c.Name, item.Id, err.Error())
+		}
+	}
+
+	for _, item := range c.items.GetCurrentList() {
+		add(item)
+	}
+
+	if c.buffer != nil {
+		for _, bufferItem := range c.buffer.items {
+			add(&bufferItem.Item)
+		}
+	}
+
+	for id := range c.duplicates.partials {
+		if _, ok := existing[id]; ok == false {
+			c.duplicates.Remove(id)
+		}
+	}
+}
+
+// Returns the items having the same content than the item specified
+func (c *Collection) findDuplicates(id Id, h data.HasHash) (ids []Id, err error) {
+
+	partial, err := h.GetPartialHash()
+	if err != nil {
+		return
+	}
+
+	var hash string
+	for otherId := range c.duplicates.groups[partial] {
+
+		if otherId == id {
+			continue
+		}
+
+		other, _ := c.findItem(otherId)
+		otherHash, ok := getHashData(other)
+		if ok == false {
+			continue
+		}
+
+		// Confirm with the full hashes
+		if hash == "" {
+			if hash, err = c.duplicates.getHash(id, h); err != nil {
+				return
+			}
+		}
+
+		if otherContent, err := c.duplicates.getHash(otherId, otherHash); err != nil {
+			log.Printf("[%s] duplicates %d: %s\n", c.Name, otherId, err.Error())
+			continue
+		} else if otherContent == hash {
+			ids = append(ids, otherId)
+		}
+	}
+
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return
+}
+
+// Flag the new buffer item if its content is already in the collection
+func (c *Collection) checkDuplicate(item *BufferItem) {
+
+	h, ok := getHashData(&item.Item)
+	if ok == false {
+		return
+	}
+
+	c.indexDuplicates()
+
+	if err := c.duplicates.Add(item.Id, h); err != nil {
+		log.Printf("[%s] duplicates %d: %s\n", c.Name, item.Id, err.Error())
+		return
+	}
+
+	ids, err := c.findDuplicates(item.Id, h)
+	if err != nil {
+		log.Printf("[%s] duplicates %d: %s\n", c.Name, item.Id, err.Error())
+		return
+	}
+
+	if len(ids) > 0 {
+		item.IsDuplicate = true
+		item.DuplicateOf = ids[0]
+	}
+}
+
+// GetDuplicates returns the groups of items having the same content
+func (c *Collection) GetDuplicates() (duplicates []*Duplicate) {
+
+	c.indexDuplicates()
+
+	duplicates = make([]*Duplicate, 0)
+	done := make(map[Id]struct{})
+
+	for _, group := range c.duplicates.groups {
+
+		if len(group) < 2 {
+			continue
+		}
+
+		for id := range group {
+
+			if _, ok := done[id]; ok {
+				continue
+			}
+
+			item, _ := c.findItem(id)
+			h, ok := getHashData(item)
+			if ok == false {
+				continue
+			}
+
+			ids, err := c.findDuplicates(id, h)
+			if err != nil {
+				log.Printf("[%s] duplicates %d: %s\n", c.Name, id, err.Error())
+				continue
+			}
+
+			if len(ids) == 0 {
+				continue
+			}
+
+			duplicate := &Duplicate{
+				Hash: c.duplicates.hashes[id],
+			}
+
+			for _, duplicateId := range append([]Id{id}, ids...) {
+				done[duplicateId] = struct{}{}
+				duplicate.Items = append(duplicate.Items, c.newDuplicateItem(duplicateId))
+			}
+
+			sort.Slice(duplicate.Items, func(i, j int) bool {
+				return duplicate.Items[i].Id < duplicate.Items[j].Id
+			})
+
+			duplicates = append(duplicates, duplicate)
+		}
+	}
+
+	sort.Slice(duplicates, func(i, j int) bool {
+		return duplicates[i].Hash < duplicates[j].Hash
+	})
+
+	return
+}
+
+func (c *Collection) newDuplicateItem(id Id) *DuplicateItem {
+
+	item, bufferItem := c.findItem(id)
+
+	duplicate := &DuplicateItem{
+		Id:       id,
+		Name:     item.Engine.GetName(),
+		Location: LOCATION_ITEMS,
+	}
+
+	if bufferItem != nil {
+		duplicate.Location = LOCATION_BUFFER
+	}
+
+	return duplicate
+}
@@ -0,0 +1,154 @@
+package core
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ohohleo/classify/data"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDuplicates(t *testing.T) {
+
+	assert := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "duplicates")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// Same partial hash but different contents
+	large := make([]byte, 4*data.HASH_PARTIAL_SIZE)
+	modified := make([]byte, len(large))
+	modified[data.HASH_PARTIAL_SIZE+10] = 1
+
+	contents := map[string][]byte{
+		"Looper.2012.mkv":     []byte("looper"),
+		"Looper.copy.mkv":     []byte("looper"),
+		"The.Loop.2000.mkv":   []byte("the loop"),
+		"Looper.large.mkv":    large,
+		"Looper.modified.mkv": modified,
+	}
+
+	collection := newTestCollection(t, `{
+  "buffer": {"enable": true},
+  "import": {"separators": ["."], "duplicates": true}
+}`)
+
+	items := make(map[string]*BufferItem)
+	for _, name := range []string{"Looper.2012.mkv", "The.Loop.2000.mkv",
+		"Looper.large.mkv", "Looper.modified.mkv", "Looper.copy.mkv"} {
+
+		path := filepath.Join(dir, name)
+		assert.Nil(ioutil.WriteFile(path, contents[name], 0644))
+
+		input := &data.File{Name: name, Path: path}
+		item, err := collection.OnInput(Id(data.GetId(input)), input)
+		if assert.Nil(err) && assert.NotNil(item) {
+			items[name] = item
+		}
+	}
+
+	assert.False(items["Looper.2012.mkv"].IsDuplicate)
+	assert.False(items["The.Loop.2000.mkv"].IsDuplicate)
+	assert.False(items["Looper.modified.mkv"].IsDuplicate)
+
+	assert.True(items["Looper.copy.mkv"].IsDuplicate)
+	assert.Equal(items["Looper.2012.mkv"].Id, items["Looper.copy.mkv"].DuplicateOf)
+
+	// Validated item
+	_, err = collection.Validate("Looper.2012.mkv", nil)
+	assert.Nil(err)
+
+	duplicates := collection.GetDuplicates()
+	if assert.Len(duplicates, 1) {
+		assert.Equal(duplicates[0].Hash, items["Looper.copy.mkv"].Item.Engine.(*data.File).Hash)
+
+		if assert.Len(duplicates[0].Items, 2) {
+			locations := map[string]string{}
+			for _, item := range duplicates[0].Items {
+				locations[item.Name] = item.Location
+			}
+
+			assert.Equal(map[string]string{
+				"Looper.2012.mkv": LOCATION_ITEMS,
+				"Looper.copy.mkv": LOCATION_BUFFER,
+			}, locations)
+		}
+	}
+
+	// Removed item
+	copy := items["Looper.copy.mkv"]
+	assert.Nil(collection.OnDelete(copy.Id, copy.Item.Engine))
+	assert.Len(collection.GetDuplicates(), 0)
+}
+
+func TestSameNameDuplicates(t *testing.T) {
+
+	assert := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "duplicates")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	collection := newTestCollection(t, `{
+  "buffer": {"enable": true},
+  "import": {"separators": ["."], "duplicates": true}
+}`)
+
+	// Same name in different directories
+	var inputs []*data.File
+	for _, content := range []string{"looper", "the loop", "looper"} {
+
+		path, err := ioutil.TempDir(dir, "files")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		path = filepath.Join(path, "Looper.mkv")
+		assert.Nil(ioutil.WriteFile(path, []byte(content), 0644))
+
+		hash, err := data.FullHash(path)
+		assert.Nil(err)
+
+		inputs = append(inputs, &data.File{
+			Name:      "Looper.mkv",
+			Path:      path,
+			ContentId: hash,
+		})
+	}
+
+	// Different contents: both kept in the buffer
+	first, err := collection.OnInput(Id(data.GetId(inputs[0])), inputs[0])
+	if assert.Nil(err) && assert.NotNil(first) {
+		assert.False(first.IsDuplicate)
+	}
+
+	second, err := collection.OnInput(Id(data.GetId(inputs[1])), inputs[1])
+	if assert.Nil(err) && assert.NotNil(second) {
+		assert.NotEqual(first.Id, second.Id)
+		assert.False(second.IsDuplicate)
+	}
+
+	assert.Len(collection.buffer.items, 2)
+	assert.Equal(second, collection.buffer.Get(second.Id.String()))
+
+	// Same name & same content
+	_, err = collection.OnInput(Id(data.GetId(inputs[2])), inputs[2])
+	assert.NotNil(err)
+	assert.Len(collection.buffer.items, 2)
+
+	// Deletion sharing the content id
+	deletion := &data.Deletion{Data: &data.File{
+		Name:      "Looper.mkv",
+		ContentId: inputs[1].ContentId,
+	}}
+	assert.Nil(collection.OnDelete(Id(data.GetId(deletion)), deletion))
+	assert.Nil(collection.buffer.Get(second.Id.String()))
+	assert.NotNil(collection.buffer.Get(first.Id.String()))
+}
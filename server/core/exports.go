@@ -8,6 +8,7 @@ import (
 	"github.com/ohohleo/classify/exports"
 	"github.com/ohohleo/classify/exports/file"
 	"github.com/ohohleo/classify/reference"
+	"log"
 )
 
 // Type of exports
@@ -390,6 +391,17 @@ func (c *Classify) ForceExports(exportList map[string]*Export, collections map[s
 			// For all items
 			for _, item := range collection.GetItems() {
 
+				// Contents extracted kept with the item
+				if extracted, err := item.Extract(); err != nil {
+					log.Printf("[%s] extract %d: %s\n",
+						collection.Name, item.Id, err.Error())
+				} else if extracted {
+					if err := collection.StoreItem2DB(item); err != nil {
+						log.Printf("[%s] store %d: %s\n",
+							collection.Name, item.Id, err.Error())
+					}
+				}
+
 				// Try to export them
 				c.exports[name].engine.OnInput(item.Engine)
 			}
@@ -0,0 +1,381 @@
+package core
+
+import (
+	"fmt"
+	"log"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ohohleo/classify/data"
+)
+
+// Import filters, one rule by string:
+//   *.mkv or glob:*.mkv  name matching the glob pattern (case insensitive)
+//   re:^[Ss]ample        name matching the regular expression
+//   ext:mkv,avi          name with one of the extensions
+//   size>=700MB          size limit (B, KB, MB, GB, TB) with >, >=, <, <=, =
+//   date<2010-01-01      date limit (ie. modification date of the files)
+//
+// The name rules prefixed with '!' exclude the inputs matching, the other
+// ones include them: if any, at least one of them must match. The size &
+// date limits are all checked when the input size or date is known.
+
+const FILTER_DATE_FORMAT = "2006-01-02"
+
+var reFilterLimit = regexp.MustCompile(`^(size|date)\s*(>=|<=|>|<|=)\s*(.+)$`)
+
+var filterSizeUnits = map[string]float64{
+	"":   1,
+	"B":  1,
+	"KB": 1 << 10,
+	"MB": 1 << 20,
+	"GB": 1 << 30,
+	"TB": 1 << 40,
+}
+
+var reFilterSize = regexp.MustCompile(`^([0-9.]+)\s*([A-Za-z]*)$`)
+
+type filterRule struct {
+	src string
+
+	// Name rules
+	match func(name string) bool
+
+	// Limits: the inputs without size or date are accepted
+	check func(d data.Data) bool
+}
+
+type Filters struct {
+	includes []*filterRule
+	excludes []*filterRule
+	limits   []*filterRule
+}
+
+// Counters of the inputs received by the collection
+type FilterStats struct {
+	Accepted uint64            `json:"accepted"`
+	Filtered uint64            `json:"filtered"`
+	Rules    map[string]uint64 `json:"rules"`
+}
+
+// Event sent when an input is filtered
+type FilterEvent struct {
+	Ref   string      `json:"ref"`
+	Name  string      `json:"name"`
+	Rule  string      `json:"rule"`
+	Stats FilterStats `json:"stats"`
+}
+
+// NewFilters returns an error if one of the rules is invalid
+func NewFilters(rules []string) (f *Filters, err error) {
+
+	f = new(Filters)
+
+	for _, src := range rules {
+
+		src = strings.TrimSpace(src)
+		if src == "" {
+			continue
+		}
+
+		var rule *filterRule
+		if rule, err = newFilterRule(src); err != nil {
+			err = fmt.Errorf("invalid import filter '%s': %s", src, err.Error())
+			return
+		}
+
+		switch {
+		case rule.check != nil:
+			f.limits = append(f.limits, rule)
+		case src[0] == '!':
+			f.excludes = append(f.excludes, rule)
+		default:
+			f.includes = append(f.includes, rule)
+		}
+	}
+
+	return
+}
+
+func newFilterRule(src string) (rule *filterRule, err error) {
+
+	rule = &filterRule{src: src}
+
+	// Size & date limits
+	if res := reFilterLimit.FindStringSubmatch(src); len(res) > 3 {
+
+		var compare func(int) bool
+		if compare, err = getFilterCompare(res[2]); err != nil {
+			return
+		}
+
+		switch res[1] {
+		case "size":
+			var limit int64
+			if limit, err = parseFilterSize(res[3]); err != nil {
+				return
+			}
+
+			rule.check = func(d data.Data) bool {
+				if hasSize, ok := d.(data.HasSize); ok {
+					if size, ok := hasSize.GetSize(); ok {
+						return compare(compareInt64(size, limit))
+					}
+				}
+				return true
+			}
+
+		case "date":
+			var limit time.Time
+			if limit, err = time.Parse(FILTER_DATE_FORMAT, res[3]); err != nil {
+				return
+			}
+
+			rule.check = func(d data.Data) bool {
+				if hasDate, ok := d.(data.HasDate); ok {
+					if date, ok := hasDate.GetDate(); ok {
+						return compare(compareTime(date, limit))
+					}
+				}
+				return true
+			}
+		}
+
+		return
+	}
+
+	// Name rules
+	pattern := strings.TrimPrefix(src, "!")
+
+	switch {
+	case strings.HasPrefix(pattern, "re:"):
+		var re *regexp.Regexp
+		if re, err = regexp.Compile(pattern[3:]); err != nil {
+			return
+		}
+
+		rule.match = re.MatchString
+
+	case strings.HasPrefix(pattern, "ext:"):
+		extensions := make(map[string]struct{})
+		for _, ext := range strings.Split(pattern[4:], ",") {
+			ext = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(ext), "."))
+			if ext != "" {
+				extensions[ext] = struct{}{}
+			}
+		}
+
+		if len(extensions) == 0 {
+			err = fmt.Errorf("no extensions specified")
+			return
+		}
+
+		rule.match = func(name string) bool {
+			ext := strings.ToLower(strings.TrimPrefix(filepath.Ext(name), "."))
+			_, ok := extensions[ext]
+			return ok
+		}
+
+	default:
+		glob := strings.ToLower(strings.TrimPrefix(pattern, "glob:"))
+		if _, err = filepath.Match(glob, ""); err != nil {
+			return
+		}
+
+		rule.match = func(name string) bool {
+			ok, _ := filepath.Match(glob, strings.ToLower(name))
+			return ok
+		}
+	}
+
+	return
+}
+
+func getFilterCompare(operator string) (func(int) bool, error) {
+
+	switch operator {
+	case ">":
+		return func(res int) bool { return res > 0 }, nil
+	case ">=":
+		return func(res int) bool { return res >= 0 }, nil
+	case "<":
+		return func(res int) bool { return res < 0 }, nil
+	case "<=":
+		return func(res int) bool { return res <= 0 }, nil
+	case "=":
+		return func(res int) bool { return res == 0 }, nil
+	}
+
+	return nil, fmt.Errorf("invalid operator '%s'", operator)
+}
+
+// Returns the size in bytes (ie. 700MB, 1.5GB)
+func parseFilterSize(src string) (int64, error) {
+
+	res := reFilterSize.FindStringSubmatch(strings.TrimSpace(src))
+	if len(res) < 3 {
+		return 0, fmt.Errorf("invalid size '%s'", src)
+	}
+
+	unit, ok := filterSizeUnits[strings.ToUpper(res[2])]
+	if ok == false {
+		return 0, fmt.Errorf("invalid size unit '%s'", res[2])
+	}
+
+	value, err := strconv.ParseFloat(res[1], 64)
+	if err != nil {
+		return 0, err
+	}
+
+	return int64(value * unit), nil
+}
+
+func compareInt64(a int64, b int64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func compareTime(a time.Time, b time.Time) int {
+	switch {
+	case a.Before(b):
+		return -1
+	case a.After(b):
+		return 1
+	}
+	return 0
+}
+
+// Filter returns the rule rejecting the input, ok is true if the input
+// is accepted
+func (f *Filters) Filter(d data.Data) (rule string, ok bool) {
+
+	name := d.GetName()
+
+	for _, exclude := range f.excludes {
+		if exclude.match(name) {
+			return exclude.src, false
+		}
+	}
+
+	if len(f.includes) > 0 {
+
+		included := false
+		for _, include := range f.includes {
+			if include.match(name) {
+				included = true
+				break
+			}
+		}
+
+		if included == false {
+			return "", false
+		}
+	}
+
+	for _, limit := range f.limits {
+		if limit.check(d) == false {
+			return limit.src, false
+		}
+	}
+
+	return "", true
+}
+
+// Returns the filters of the collection configuration
+func (c *Collection) getFilters() (*Filters, error) {
+
+	src := strings.Join(c.Config.Import.Filters, "\n")
+
+	// Compile the rules only if modified
+	if c.filters == nil || c.filtersSrc != src {
+
+		filters, err := NewFilters(c.Config.Import.Filters)
+		if err != nil {
+			return nil, err
+		}
+
+		c.filters = filters
+		c.filtersSrc = src
+	}
+
+	return c.filters, nil
+}
+
+// UpdateFilters compiles the import filters of the collection
+// configuration: returns an error if one of the rules is invalid
+func (c *Collection) UpdateFilters() error {
+	_, err := c.getFilters()
+	return err
+}
+
+// Check the input with the collection filters: returns false if
+// filtered
+func (c *Collection) filterInput(input data.Data) (ok bool, err error) {
+
+	filters, err := c.getFilters()
+	if err != nil {
+		return
+	}
+
+	rule, ok := filters.Filter(input)
+
+	if c.filterStats.Rules == nil {
+		c.filterStats.Rules = make(map[string]uint64)
+	}
+
+	if ok {
+		c.filterStats.Accepted++
+		return
+	}
+
+	// No include rule matching
+	if rule == "" {
+		rule = "include"
+	}
+
+	c.filterStats.Filtered++
+	c.filterStats.Rules[rule]++
+
+	log.Printf("[%s] input %s filtered by '%s': %s\n", c.Name,
+		input.GetRef(), rule, input.GetName())
+
+	if c.events != nil {
+		c.events <- CollectionEvent{
+			Source: "import",
+			Status: "filtered",
+			Id:     input.GetName(),
+			Data: &FilterEvent{
+				Ref:   input.GetRef().String(),
+				Name:  input.GetName(),
+				Rule:  rule,
+				Stats: c.GetFilterStats(),
+			},
+		}
+	}
+
+	return
+}
+
+// GetFilterStats returns the counters of the inputs accepted & filtered
+func (c *Collection) GetFilterStats() FilterStats {
+
+	stats := FilterStats{
+		Accepted: c.filterStats.Accepted,
+		Filtered: c.filterStats.Filtered,
+		Rules:    make(map[string]uint64),
+	}
+
+	for rule, count := range c.filterStats.Rules {
+		stats.Rules[rule] = count
+	}
+
+	return stats
+}
@@ -0,0 +1,137 @@
+package core
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ohohleo/classify/collections"
+	"github.com/ohohleo/classify/data"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFilters(t *testing.T) {
+
+	assert := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "filters")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	newFile := func(name string, size int) *data.File {
+
+		err := ioutil.WriteFile(filepath.Join(dir, name), make([]byte, size), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		file, err := data.NewFileFromPath(dir, name)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		file.FileInfo, _ = os.Stat(file.Path)
+		return file
+	}
+
+	filters, err := NewFilters([]string{
+		"ext:mkv, .AVI",
+		"*.mp4",
+		"!re:^[Ss]ample",
+		"size>=1KB",
+		"date<2100-01-01",
+	})
+	if !assert.Nil(err) {
+		return
+	}
+
+	for name, expected := range map[*data.File]string{
+		newFile("Looper.mkv", 2048):        "",
+		newFile("Looper.avi", 2048):        "",
+		newFile("Looper.MP4", 2048):        "",
+		newFile("Looper.nfo", 2048):        "include",
+		newFile("sample-Looper.mkv", 2048): "!re:^[Ss]ample",
+		newFile("Small.mkv", 10):           "size>=1KB",
+	} {
+		rule, ok := filters.Filter(name)
+		if expected == "" {
+			assert.True(ok, name.Name)
+		} else {
+			assert.False(ok, name.Name)
+			if expected != "include" {
+				assert.Equal(expected, rule, name.Name)
+			}
+		}
+	}
+
+	// Size & date unknown: only name rules applied
+	_, ok := filters.Filter(&data.File{Name: "Looper.mkv"})
+	assert.True(ok)
+
+	// Date limits
+	filters, err = NewFilters([]string{"date>=2019-01-01"})
+	if assert.Nil(err) {
+		_, ok = filters.Filter(&data.Email{Date: time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)})
+		assert.False(ok)
+		_, ok = filters.Filter(&data.Email{Date: time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)})
+		assert.True(ok)
+	}
+
+	for _, invalid := range []string{"re:(", "ext:", "[", "size>1XB", "date<tomorrow"} {
+		_, err = NewFilters([]string{invalid})
+		assert.NotNil(err, invalid)
+	}
+}
+
+func TestOnInputFiltered(t *testing.T) {
+
+	assert := assert.New(t)
+
+	collection := newTestCollection(t, `{
+  "buffer": {"enable": true},
+  "import": {"separators": ["."], "filters": ["!*.nfo"]}
+}`)
+
+	for _, name := range []string{"Looper.2012.mkv", "Looper.2012.nfo"} {
+
+		input := &data.File{Name: name}
+		item, err := collection.OnInput(Id(data.GetId(input)), input)
+		assert.Nil(err)
+
+		if name == "Looper.2012.nfo" {
+			assert.Nil(item)
+		} else {
+			assert.NotNil(item)
+		}
+	}
+
+	assert.Len(collection.GetBuffer(), 1)
+
+	stats := collection.GetFilterStats()
+	assert.Equal(uint64(1), stats.Accepted)
+	assert.Equal(uint64(1), stats.Filtered)
+	assert.Equal(map[string]uint64{"!*.nfo": 1}, stats.Rules)
+
+	// Invalid filters
+	collection.Config.Import.Filters = []string{"re:("}
+	assert.NotNil(collection.UpdateFilters())
+
+	input := &data.File{Name: "The.Loop.2000.mkv"}
+	_, err := collection.OnInput(Id(data.GetId(input)), input)
+	assert.NotNil(err)
+
+	collection.Config.Import.Filters = []string{"!*.nfo"}
+	assert.Nil(collection.UpdateFilters())
+
+	// Collection refused with invalid filters
+	c, _, err := NewClassify(&Config{})
+	if assert.Nil(err) {
+		_, err = c.CreateCollection("invalid", collections.MOVIES,
+			[]byte(`{"import": {"filters": ["re:("]}}`), nil)
+		assert.NotNil(err)
+	}
+}
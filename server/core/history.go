@@ -0,0 +1,353 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/ohohleo/classify/database"
+)
+
+// Operations recorded in the collection history
+const (
+	HISTORY_VALIDATE   = "validate"
+	HISTORY_UNVALIDATE = "unvalidate"
+	HISTORY_DELETE     = "delete"
+	HISTORY_PATCH      = "patch"
+	HISTORY_TWEAK      = "tweak"
+)
+
+// Maximum number of operations kept by collection
+const HISTORY_MAX_SIZE = 100
+
+// Location of an item inside the collection
+const (
+	LOCATION_NONE   = ""
+	LOCATION_BUFFER = "buffer"
+	LOCATION_ITEMS  = "items"
+)
+
+// ItemState is the snapshot of an item at a given location
+type ItemState struct {
+	Location string          `json:"location"`
+	Data     json.RawMessage `json:"data,omitempty"`
+}
+
+// HistoryEntry is an operation done on a collection item: undo restores
+// the state before, redo the state after
+type HistoryEntry struct {
+	Id        uint64     `json:"id"`
+	Operation string     `json:"operation"`
+	ItemId    Id         `json:"itemId,string"`
+	Date      time.Time  `json:"date"`
+	Undone    bool       `json:"undone"`
+	Before    *ItemState `json:"before"`
+	After     *ItemState `json:"after"`
+}
+
+type History struct {
+	entries []*HistoryEntry
+	lastId  uint64
+}
+
+func NewHistory() *History {
+	return &History{
+		entries: make([]*HistoryEntry, 0),
+	}
+}
+
+// Returns the number of operations applied: the following ones are undone
+func (h *History) position() int {
+
+	for idx, entry := range h.entries {
+		if entry.Undone {
+			return idx
+		}
+	}
+
+	return len(h.entries)
+}
+
+// GetHistory returns all the operations, the oldest first
+func (c *Collection) GetHistory() []*HistoryEntry {
+	if c.history == nil {
+		return []*HistoryEntry{}
+	}
+
+	return c.history.entries
+}
+
+// Returns the item (and the buffer item if in buffer) with the id specified
+func (c *Collection) findItem(id Id) (*Item, *BufferItem) {
+
+	if c.buffer != nil {
+		if _, bufferItem := c.buffer.GetById(id); bufferItem != nil {
+			return &bufferItem.Item, bufferItem
+		}
+	}
+
+	if item, err := c.items.Get(id); err == nil {
+		return item, nil
+	}
+
+	return nil, nil
+}
+
+// Returns the current state of the item
+func (c *Collection) getItemState(id Id) (state *ItemState, err error) {
+
+	state = &ItemState{
+		Location: LOCATION_NONE,
+	}
+
+	item, bufferItem := c.findItem(id)
+
+	switch {
+	case bufferItem != nil:
+		state.Location = LOCATION_BUFFER
+		state.Data, err = bufferItem.ToJSON()
+
+	case item != nil:
+		state.Location = LOCATION_ITEMS
+		state.Data, err = json.Marshal(item)
+	}
+
+	return
+}
+
+// Move the item to the state specified
+func (c *Collection) setItemState(id Id, state *ItemState) (err error) {
+
+	// Remove the current item
+	item, bufferItem := c.findItem(id)
+
+	switch {
+	case bufferItem != nil:
+		c.buffer.Remove(bufferItem.Id.String())
+
+	case item != nil:
+		if err = c.removeItem(id); err != nil {
+			return
+		}
+
+		c.SendCollectionEvent("items", "remove", item)
+	}
+
+	// Restore the item as it was
+	switch state.Location {
+	case LOCATION_BUFFER:
+
+		if c.buffer == nil {
+			err = fmt.Errorf("buffer not initialized")
+			return
+		}
+
+		if bufferItem, err = NewBufferItemFromJSON(state.Data); err != nil {
+			return
+		}
+
+		// Sent again & never validated automatically once restored
+		bufferItem.Status = CREATED
+		bufferItem.IsAutoValidated = true
+
+		if c.buffer.Add(bufferItem) == false {
+			err = fmt.Errorf("already existing item '%s' in buffer",
+				bufferItem.Item.Engine.GetName())
+			return
+		}
+
+		err = c.StoreBufferItem2DB(bufferItem)
+
+	case LOCATION_ITEMS:
+
+		if item, err = NewItemFromJSON(state.Data); err != nil {
+			return
+		}
+
+		if err = c.items.Add(id, item); err != nil {
+			return
+		}
+
+		if err = c.StoreItem2DB(item); err != nil {
+			return
+		}
+
+		c.SendCollectionEvent("items", "add", item)
+	}
+
+	return
+}
+
+// Record the operation done on the item from the state specified
+func (c *Collection) addHistory(operation string, id Id, before *ItemState) (err error) {
+
+	after, err := c.getItemState(id)
+	if err != nil {
+		return
+	}
+
+	if c.history == nil {
+		c.history = NewHistory()
+	}
+
+	h := c.history
+
+	// Operations undone can't be redone anymore
+	position := h.position()
+	for _, entry := range h.entries[position:] {
+		if err = c.DeleteHistory2DB(entry); err != nil {
+			return
+		}
+	}
+	h.entries = h.entries[:position]
+
+	// Forget the oldest operations
+	for len(h.entries) >= HISTORY_MAX_SIZE {
+		if err = c.DeleteHistory2DB(h.entries[0]); err != nil {
+			return
+		}
+		h.entries = h.entries[1:]
+	}
+
+	h.lastId++
+
+	entry := &HistoryEntry{
+		Id:        h.lastId,
+		Operation: operation,
+		ItemId:    id,
+		Date:      time.Now(),
+		Before:    before,
+		After:     after,
+	}
+
+	h.entries = append(h.entries, entry)
+
+	return c.StoreHistory2DB(entry)
+}
+
+// Undo cancels the last operation applied
+func (c *Collection) Undo() (entry *HistoryEntry, err error) {
+
+	position := 0
+	if c.history != nil {
+		position = c.history.position()
+	}
+
+	if position == 0 {
+		err = fmt.Errorf("no operation to undo")
+		return
+	}
+
+	entry = c.history.entries[position-1]
+
+	if err = c.setItemState(entry.ItemId, entry.Before); err != nil {
+		return
+	}
+
+	entry.Undone = true
+
+	log.Printf("[%s] undo %s %d\n", c.Name, entry.Operation, entry.ItemId)
+
+	err = c.StoreHistory2DB(entry)
+	return
+}
+
+// Redo applies again the last operation undone
+func (c *Collection) Redo() (entry *HistoryEntry, err error) {
+
+	position := 0
+	if c.history != nil {
+		position = c.history.position()
+	}
+
+	if c.history == nil || position == len(c.history.entries) {
+		err = fmt.Errorf("no operation to redo")
+		return
+	}
+
+	entry = c.history.entries[position]
+
+	if err = c.setItemState(entry.ItemId, entry.After); err != nil {
+		return
+	}
+
+	entry.Undone = false
+
+	log.Printf("[%s] redo %s %d\n", c.Name, entry.Operation, entry.ItemId)
+
+	err = c.StoreHistory2DB(entry)
+	return
+}
+
+// Store history entry in the database if enabled
+func (c *Collection) StoreHistory2DB(entry *HistoryEntry) error {
+
+	// Check if db is enabled
+	if c.database == nil {
+		return nil
+	}
+
+	dataJson, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	return c.database.StoreItem("histories", &database.ItemStruct{
+		CollectionsId: c.Id,
+		Uid:           strconv.FormatUint(entry.Id, 10),
+		Data:          dataJson,
+	})
+}
+
+// Remove history entry from the database if enabled
+func (c *Collection) DeleteHistory2DB(entry *HistoryEntry) error {
+
+	// Check if db is enabled
+	if c.database == nil {
+		return nil
+	}
+
+	return c.database.DeleteItem("histories", c.Id,
+		strconv.FormatUint(entry.Id, 10))
+}
+
+// Retreive all stored history entries of the collection
+func (c *Collection) RetreiveDBHistory() error {
+
+	// Check if db is enabled
+	if c.database == nil {
+		return nil
+	}
+
+	history := NewHistory()
+
+	err := c.database.RetreiveItems("histories", c.Id,
+		func(uid string, ref uint64, src []byte) error {
+
+			entry := new(HistoryEntry)
+			if err := json.Unmarshal(src, entry); err != nil {
+				return fmt.Errorf("collection '%s' invalid history entry '%s': %s",
+					c.Name, uid, err.Error())
+			}
+
+			if entry.Id > history.lastId {
+				history.lastId = entry.Id
+			}
+
+			history.entries = append(history.entries, entry)
+			return nil
+		})
+	if err != nil {
+		return err
+	}
+
+	sort.Slice(history.entries, func(i, j int) bool {
+		return history.entries[i].Id < history.entries[j].Id
+	})
+
+	c.history = history
+	return nil
+}
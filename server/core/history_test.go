@@ -0,0 +1,164 @@
+package core
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ohohleo/classify/collections"
+	"github.com/ohohleo/classify/data"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHistory(t *testing.T) {
+
+	assert := assert.New(t)
+
+	collection := newTestCollection(t, `{
+  "buffer": {"enable": true},
+  "import": {"separators": ["."]}
+}`)
+
+	_, err := collection.Undo()
+	assert.NotNil(err)
+
+	input := &data.File{Name: "Looper.2012"}
+	id := Id(data.GetId(input))
+
+	_, err = collection.OnInput(id, input)
+	assert.Nil(err)
+
+	_, err = collection.Validate(input.GetName(), nil)
+	assert.Nil(err)
+
+	assert.Nil(collection.RemoveItem(id))
+	assert.Len(collection.GetItems(), 0)
+
+	history := collection.GetHistory()
+	if assert.Len(history, 2) {
+		assert.Equal(HISTORY_VALIDATE, history[0].Operation)
+		assert.Equal(LOCATION_BUFFER, history[0].Before.Location)
+		assert.Equal(LOCATION_ITEMS, history[0].After.Location)
+		assert.Equal(HISTORY_DELETE, history[1].Operation)
+		assert.Equal(LOCATION_NONE, history[1].After.Location)
+	}
+
+	// Undo the deletion
+	entry, err := collection.Undo()
+	if assert.Nil(err) {
+		assert.Equal(HISTORY_DELETE, entry.Operation)
+		assert.True(entry.Undone)
+	}
+
+	item, err := collection.GetItem(id)
+	if assert.Nil(err) {
+		assert.Equal("Looper", item.Name)
+		assert.Equal(input, item.Engine)
+	}
+
+	// Undo the validation
+	_, err = collection.Undo()
+	assert.Nil(err)
+
+	assert.Len(collection.GetItems(), 0)
+	if buffer := collection.GetBuffer(); assert.Len(buffer, 1) {
+		assert.Equal(id, buffer[0].Id)
+		assert.Equal("", buffer[0].Name)
+	}
+
+	_, err = collection.Undo()
+	assert.NotNil(err)
+
+	// Redo the validation
+	entry, err = collection.Redo()
+	if assert.Nil(err) {
+		assert.Equal(HISTORY_VALIDATE, entry.Operation)
+	}
+
+	assert.Len(collection.GetBuffer(), 0)
+	assert.Len(collection.GetItems(), 1)
+
+	// New operation: deletion can't be redone anymore
+	tweak, err := NewTweak([]byte(`{
+  "source": {"file": {"name": {"regexp": "([A-Za-z]+)"}}},
+  "target": {"item": {"name": {"value": ":file-name-0 tweaked"}}}
+}`))
+	assert.Nil(err)
+
+	assert.Nil(collection.ApplyTweak(id, input, tweak))
+
+	item, err = collection.GetItem(id)
+	if assert.Nil(err) {
+		assert.Equal("Looper tweaked", item.Name)
+	}
+
+	history = collection.GetHistory()
+	if assert.Len(history, 2) {
+		assert.Equal(HISTORY_TWEAK, history[1].Operation)
+	}
+
+	_, err = collection.Redo()
+	assert.NotNil(err)
+}
+
+func TestHistoryDB(t *testing.T) {
+
+	assert := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "classify")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	source := filepath.Join(dir, "classify.db")
+
+	c := newTestClassify(t, source)
+
+	collection, err := c.CreateCollection("test", collections.SIMPLE, nil, nil)
+	if !assert.Nil(err) {
+		return
+	}
+
+	generic := &data.Generic{Name: "generic"}
+	id := Id(data.GetId(generic))
+
+	_, err = collection.OnInput(id, generic)
+	assert.Nil(err)
+
+	assert.Nil(collection.RemoveItem(id))
+
+	// Restart with the same database
+	c = newTestClassify(t, source)
+
+	collection, err = c.GetCollection("test")
+	if !assert.Nil(err) {
+		return
+	}
+
+	assert.Len(collection.GetHistory(), 1)
+	assert.Len(collection.GetItems(), 0)
+
+	_, err = collection.Undo()
+	assert.Nil(err)
+
+	// Restart again: the item is restored & the deletion undone
+	c = newTestClassify(t, source)
+
+	collection, err = c.GetCollection("test")
+	if !assert.Nil(err) {
+		return
+	}
+
+	assert.Len(collection.GetItems(), 1)
+
+	history := collection.GetHistory()
+	if assert.Len(history, 1) {
+		assert.True(history[0].Undone)
+	}
+
+	_, err = collection.Redo()
+	assert.Nil(err)
+	assert.Len(collection.GetItems(), 0)
+}
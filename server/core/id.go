@@ -6,6 +6,10 @@ import (
 
 type Id uint64
 
+func (id Id) String() string {
+	return strconv.FormatUint(uint64(id), 10)
+}
+
 func GetIdFromString(idStr string) (id Id, err error) {
 
 	idConv, err := strconv.ParseUint(idStr, 10, 64)
@@ -1,24 +1,41 @@
 package core
 
 import (
+	"bytes"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"log"
+	"sync"
+	"time"
 
+	"github.com/ohohleo/classify/cron"
 	"github.com/ohohleo/classify/data"
 	"github.com/ohohleo/classify/database"
 	"github.com/ohohleo/classify/imports"
 	"github.com/ohohleo/classify/imports/directory"
 	"github.com/ohohleo/classify/imports/imap"
+	"github.com/ohohleo/classify/imports/list"
+	"github.com/ohohleo/classify/imports/maildir"
+	"github.com/ohohleo/classify/imports/mbox"
+	"github.com/ohohleo/classify/imports/rss"
+	"github.com/ohohleo/classify/imports/webhook"
 	"github.com/ohohleo/classify/params"
 	"github.com/ohohleo/classify/reference"
 )
 
+// Delay between the storages of the import state while running
+var stateStoreDelay = 10 * time.Second
+
 // Type of imports
 var newImports = map[string]imports.Build{
 	"directory": directory.ToBuild(),
 	"imap":      imap.ToBuild(),
+	"rss":       rss.ToBuild(),
+	"list":      list.ToBuild(),
+	"webhook":   webhook.ToBuild(),
+	"mbox":      mbox.ToBuild(),
+	"maildir":   maildir.ToBuild(),
 }
 
 func Import2Build(typ string) (imports.Build, error) {
@@ -37,6 +54,18 @@ type Import struct {
 
 	configs map[*Collection]*Configs
 	params  map[string]params.Param
+
+	// Cron schedule of the import runs
+	schedule  string
+	cron      *cron.Schedule
+	timer     *time.Timer
+	nextRun   time.Time
+	lastRun   time.Time
+	isRunning bool
+	mutex     sync.Mutex
+
+	// Last state stored
+	storedState []byte
 }
 
 func NewImport(typ string) (*Import, error) {
@@ -183,6 +212,25 @@ func (i *Import) Store2DB(db *database.Database) error {
 	return nil
 }
 
+// StoreParams2DB updates the import parameters stored
+func (i *Import) StoreParams2DB(db *database.Database) error {
+
+	// Check if db is enabled
+	if db == nil {
+		return nil
+	}
+
+	paramsStr, err := json.Marshal(i.engine)
+	if err != nil {
+		return err
+	}
+
+	return db.Update("imports", &database.GenStruct{
+		Id:     i.Id,
+		Params: paramsStr,
+	}, []string{"params"}, "id = :id")
+}
+
 func (i *Import) Unlink2DB(db *database.Database, collection *Collection) error {
 
 	// Check if db is enabled
@@ -197,6 +245,60 @@ func (i *Import) Unlink2DB(db *database.Database, collection *Collection) error
 		"imports_id = :imports_id AND collections_id = :collections_id")
 }
 
+// StoreState2DB store the import state if handled
+func (i *Import) StoreState2DB(db *database.Database) error {
+
+	// Check if db is enabled
+	if db == nil {
+		return nil
+	}
+
+	engine, ok := i.engine.(imports.HasState)
+	if ok == false {
+		return nil
+	}
+
+	state, err := engine.GetState()
+	if err != nil {
+		return err
+	}
+
+	// Not modified since stored
+	i.mutex.Lock()
+	isStored := bytes.Equal(state, i.storedState)
+	i.mutex.Unlock()
+
+	if isStored {
+		return nil
+	}
+
+	if err = imports.StoreDBState(db, i.Id, state); err != nil {
+		return err
+	}
+
+	i.mutex.Lock()
+	i.storedState = state
+	i.mutex.Unlock()
+
+	return nil
+}
+
+// RetreiveDBState restore the import state if stored
+func (i *Import) RetreiveDBState(db *database.Database) error {
+
+	engine, ok := i.engine.(imports.HasState)
+	if ok == false {
+		return nil
+	}
+
+	state, err := imports.RetreiveDBState(db, i.Id)
+	if err != nil || state == nil {
+		return err
+	}
+
+	return engine.SetState(state)
+}
+
 func (i *Import) Delete2DB(db *database.Database) error {
 
 	// Check if db is enabled
@@ -204,6 +306,14 @@ func (i *Import) Delete2DB(db *database.Database) error {
 		return nil
 	}
 
+	if err := imports.DeleteDBState(db, i.Id); err != nil {
+		return err
+	}
+
+	if err := imports.DeleteDBSchedule(db, i.Id); err != nil {
+		return err
+	}
+
 	return db.Delete("imports", &database.GenStruct{
 		Id:  i.Id,
 		Ref: uint64(i.engine.GetRef()),
@@ -250,6 +360,23 @@ func (c *Classify) GetImportByName(name string) (i *Import, err error) {
 	return
 }
 
+// PushImport sends the data pushed to the started import
+func (c *Classify) PushImport(name string, body []byte, signature string) (count int, err error) {
+
+	i, err := c.GetImportByName(name)
+	if err != nil {
+		return
+	}
+
+	engine, ok := i.engine.(imports.HasPush)
+	if ok == false {
+		err = fmt.Errorf("import '%s' doesn't accept pushed data", name)
+		return
+	}
+
+	return engine.Push(body, signature)
+}
+
 // Check imports names and return the list of imports
 func (c *Classify) GetImportsByNames(names []string) (imports map[string]*Import, err error) {
 
@@ -271,6 +398,13 @@ func (c *Classify) CreateImport(name string, ref imports.Ref, inParams json.RawM
 		return
 	}
 
+	// Plain credentials moved to the secrets before being stored
+	if c.secrets != nil {
+		if _, err = c.secrets.Protect(i.engine, "imports/"+i.Name); err != nil {
+			return
+		}
+	}
+
 	// Handle DB storage
 	if err = i.Store2DB(c.database); err != nil {
 		return
@@ -401,6 +535,9 @@ func (c *Classify) DeleteImports(importList map[string]*Import, collections map[
 				return
 			}
 
+			// No more scheduled
+			i.setSchedule("")
+
 			// Remove the import
 			delete(c.imports, importName)
 		}
@@ -439,6 +576,60 @@ func (c *Classify) SendImportEvent(name string, status bool) {
 	c.SendEvent("import/status", statusStr, name, status)
 }
 
+// Send the events of the import running as import status
+func (c *Classify) forwardImportEvents(name string, i *Import) {
+
+	if engine, ok := i.engine.(imports.HasEvents); ok {
+		engine.SetEventHandler(func(status string, data interface{}) {
+			c.SendEvent("import/status", status, name, data)
+		})
+	}
+}
+
+// Send the data imported to all the collections linked
+func (i *Import) onData(input data.Data) {
+
+	// Data removed from the import
+	if deletion, ok := input.(*data.Deletion); ok {
+		for _, config := range i.configs {
+			collection := config.Collection
+			id := data.GetId(deletion)
+			if err := collection.OnDelete(Id(id), deletion); err != nil {
+				log.Printf("[%s x %d] %s\n",
+					collection.Name, id, err.Error())
+			}
+		}
+
+		return
+	}
+
+	// For each collections linked with the importation
+	for _, config := range i.configs {
+		collection := config.Collection
+		id := data.GetId(input)
+		item, err := collection.OnInput(Id(id), input)
+		if err != nil {
+			log.Printf("[%s x %d] %s\n",
+				collection.Name, id, err.Error())
+			continue
+		}
+
+		// Input filtered
+		if item == nil {
+			continue
+		}
+
+		// Apply the import tweak on the new item
+		if config.Tweak != nil {
+			err := collection.ApplyTweak(Id(id), input, config.Tweak)
+			if err != nil {
+				log.Printf("[%s x %d] tweak: %s\n",
+					collection.Name, id, err.Error())
+			}
+		}
+	}
+}
+
 // Launch the process of importation of specified imports
 func (c *Classify) StartImports(imports map[string]*Import, collections map[string]*Collection) error {
 
@@ -450,40 +641,60 @@ func (c *Classify) StartImports(imports map[string]*Import, collections map[stri
 	// Get the import channel
 	for name, i := range imports {
 
+		name, i := name, i
+
 		if i.HasCollections(collections) == false {
 			continue
 		}
 
+		c.forwardImportEvents(name, i)
+
 		channel, err := i.engine.Start()
 		if err != nil {
 			return err
 		}
 
+		// Keep the last run date with the schedule
+		i.setRunning(true)
+		if err := i.StoreSchedule2DB(c.database); err != nil {
+			log.Printf("[%s] schedule: %s\n", name, err.Error())
+		}
+
 		// Send all data imported to the collections
 		go func() {
 
 			// Send notification to start analysis
 			c.SendImportEvent(name, true)
 
-			for {
-				if input, ok := <-channel; ok {
-
-					// For each collections linked with the importation
-					for _, config := range i.configs {
-						collection := config.Collection
-						id := data.GetId(input)
-						if _, err := collection.OnInput(Id(id), input); err != nil {
-							log.Printf("[%s x %d] %s\n",
-								collection.Name, id, err.Error())
-						}
+			// Keep the import state regularly: the imports watching
+			// are never over
+			ticker := time.NewTicker(stateStoreDelay)
+			defer ticker.Stop()
+
+			for isOver := false; isOver == false; {
+				select {
+				case input, ok := <-channel:
+					if ok == false {
+						isOver = true
+						break
 					}
 
-					continue
+					i.onData(input)
+
+				case <-ticker.C:
+					if err := i.StoreState2DB(c.database); err != nil {
+						log.Printf("[%s] state: %s\n", name, err.Error())
+					}
 				}
+			}
 
-				break
+			// Keep the import state for the next start
+			if err := i.StoreState2DB(c.database); err != nil {
+				log.Printf("[%s] state: %s\n", name, err.Error())
 			}
 
+			i.setRunning(false)
+
 			// Send notification to stop analysis
 			c.SendImportEvent(name, false)
 		}()
@@ -501,6 +712,8 @@ func (c *Classify) StopImports(imports map[string]*Import, collections map[strin
 
 	for name, i := range imports {
 
+		name, i := name, i
+
 		if i.HasCollections(collections) == false {
 			continue
 		}
@@ -1,11 +1,14 @@
 package core
 
 import (
+	"encoding/json"
 	"fmt"
+	"log"
 	"os"
 	"time"
 
 	"github.com/ohohleo/classify/data"
+	"github.com/ohohleo/classify/reference"
 	"github.com/pariz/gountries"
 )
 
@@ -19,6 +22,52 @@ type Item struct {
 	Engine   data.Data         `json:"data"`
 	Contents []string          `json:"contents"`
 	contents map[string]string
+
+	// Full record of the website data selected at validation
+	DetailsRef string    `json:"detailsRef,omitempty"`
+	Details    data.Data `json:"details,omitempty"`
+}
+
+// NewItemFromJSON rebuild stored item with its data engine
+func NewItemFromJSON(src []byte) (item *Item, err error) {
+
+	var stored struct {
+		Item
+		Engine  json.RawMessage `json:"data"`
+		Details json.RawMessage `json:"details"`
+	}
+
+	if err = json.Unmarshal(src, &stored); err != nil {
+		return
+	}
+
+	item = &stored.Item
+
+	// Retreive data engine with the item data ref
+	if len(stored.Engine) > 0 && string(stored.Engine) != "null" {
+
+		item.Engine, err = data.NewFromJSON(item.Ref, stored.Engine)
+		if err != nil {
+			return
+		}
+
+		// Sync again data contents: ignore contents not existing anymore
+		item.Contents = nil
+		if linkErr := item.LinkToData(item.Engine); linkErr != nil {
+			log.Printf("[item %d] %s\n", item.Id, linkErr.Error())
+		}
+	}
+
+	// Retreive website data with its ref
+	if len(stored.Details) > 0 && string(stored.Details) != "null" {
+
+		item.Details, err = data.NewFromJSON(item.DetailsRef, stored.Details)
+		if err != nil {
+			return
+		}
+	}
+
+	return
 }
 
 func (i *Item) SetData(input data.Data) {
@@ -29,6 +78,138 @@ func (i *Item) SetData(input data.Data) {
 	i.Engine = input
 }
 
+// SetDetails keeps the full record of the website data selected
+func (i *Item) SetDetails(d data.Data) {
+	i.DetailsRef = d.GetRef().String()
+	i.Details = d
+}
+
+// Merge set item attributes from the website data selected
+func (i *Item) Merge(d data.Data) {
+
+	if d.GetName() != "" {
+		i.Name = d.GetName()
+	}
+
+	if movie, ok := d.(*data.Movie); ok && movie.Released.IsZero() == false {
+		i.Date = movie.Released
+	}
+}
+
+// ItemPatch lists the item attributes to modify: the country is set with
+// its code or its name, the data fields with their json name
+type ItemPatch struct {
+	Name    *string                    `json:"name"`
+	Date    *time.Time                 `json:"date"`
+	DateEnd *time.Time                 `json:"dateEnd"`
+	Country *string                    `json:"country"`
+	Data    map[string]json.RawMessage `json:"data"`
+}
+
+// Patch modifies the item attributes specified: nothing is modified if
+// one of them is invalid
+func (i *Item) Patch(patch *ItemPatch) (err error) {
+
+	var country gountries.Country
+	if patch.Country != nil && *patch.Country != "" {
+
+		query := gountries.New()
+
+		country, err = query.FindCountryByAlpha(*patch.Country)
+		if err != nil {
+			if country, err = query.FindCountryByName(*patch.Country); err != nil {
+				err = fmt.Errorf("invalid country '%s'", *patch.Country)
+				return
+			}
+		}
+	}
+
+	engine := i.Engine
+	if len(patch.Data) > 0 {
+		if engine, err = i.patchEngine(patch.Data); err != nil {
+			return
+		}
+	}
+
+	if patch.Name != nil {
+		i.Name = *patch.Name
+	}
+
+	if patch.Date != nil {
+		i.Date = *patch.Date
+	}
+
+	if patch.DateEnd != nil {
+		i.DateEnd = *patch.DateEnd
+	}
+
+	if patch.Country != nil {
+		i.Country = country
+	}
+
+	i.Engine = engine
+	return
+}
+
+// Returns a copy of the data engine with the fields modified
+func (i *Item) patchEngine(fields map[string]json.RawMessage) (engine data.Data, err error) {
+
+	if i.Engine == nil {
+		err = fmt.Errorf("item '%d' without data", i.Id)
+		return
+	}
+
+	// Check that all fields can be modified: any json field of the data
+	// engine (lists included) except its identifiers
+	names := make(map[string]bool)
+	for _, ref := range reference.GetRefs(i.Engine) {
+		names[ref.Name] = ref.Name != "id" && ref.Name != "ref"
+	}
+
+	for name := range fields {
+		if names[name] == false {
+			err = fmt.Errorf("data field '%s' can't be modified", name)
+			return
+		}
+	}
+
+	src, err := json.Marshal(i.Engine)
+	if err != nil {
+		return
+	}
+
+	if engine, err = data.NewFromJSON(i.Ref, src); err != nil {
+		return
+	}
+
+	if src, err = json.Marshal(fields); err != nil {
+		return
+	}
+
+	if err = json.Unmarshal(src, engine); err != nil {
+		err = fmt.Errorf("invalid data fields: %s", err.Error())
+	}
+
+	return
+}
+
+// Extract the data content not extracted yet (ie. archive member) and add
+// it to the item contents: returns true if newly extracted
+func (i *Item) Extract() (extracted bool, err error) {
+
+	extraction, ok := i.Engine.(data.HasExtraction)
+	if ok == false {
+		return
+	}
+
+	if extracted, err = extraction.Extract(); err != nil || extracted == false {
+		return
+	}
+
+	err = i.LinkToData(i.Engine)
+	return
+}
+
 func (i *Item) LinkToData(d data.Data) error {
 
 	// Sync data content list to item
@@ -1,9 +1,68 @@
 package core
 
 import (
+	"encoding/json"
 	"testing"
-	//"github.com/stretchr/testify/assert"
+
+	"github.com/ohohleo/classify/data"
+	"github.com/stretchr/testify/assert"
 )
 
 func TestItem(t *testing.T) {
 }
+
+func TestItemPatch(t *testing.T) {
+
+	assert := assert.New(t)
+
+	movie := &data.Movie{
+		Id:     "1",
+		Name:   "Looper",
+		Genres: []string{"Action"},
+	}
+
+	item := &Item{
+		Id:     Id(1),
+		Ref:    data.MOVIE.String(),
+		Name:   "Looper",
+		Engine: movie,
+	}
+
+	// List fields modified
+	assert.Nil(item.Patch(&ItemPatch{
+		Data: map[string]json.RawMessage{
+			"cast":      json.RawMessage(`["Bruce Willis"]`),
+			"genres":    json.RawMessage(`["Action","Sci-Fi"]`),
+			"directors": json.RawMessage(`["Rian Johnson"]`),
+			"writers":   json.RawMessage(`["Rian Johnson"]`),
+		},
+	}))
+
+	if patched, ok := item.Engine.(*data.Movie); assert.True(ok) {
+		assert.Equal([]string{"Bruce Willis"}, patched.Cast)
+		assert.Equal([]string{"Action", "Sci-Fi"}, patched.Genres)
+		assert.Equal([]string{"Rian Johnson"}, patched.Directors)
+		assert.Equal("Looper", patched.Name)
+	}
+
+	// Original data kept unchanged
+	assert.Equal([]string{"Action"}, movie.Genres)
+
+	// Identifiers & unknown fields refused
+	for _, name := range []string{"id", "unknown"} {
+		err := item.Patch(&ItemPatch{
+			Data: map[string]json.RawMessage{
+				name: json.RawMessage(`"2"`),
+			},
+		})
+		assert.NotNil(err, name)
+	}
+
+	// Invalid type: nothing modified
+	assert.NotNil(item.Patch(&ItemPatch{
+		Data: map[string]json.RawMessage{
+			"cast": json.RawMessage(`"Bruce Willis"`),
+		},
+	}))
+	assert.Equal([]string{"Bruce Willis"}, item.Engine.(*data.Movie).Cast)
+}
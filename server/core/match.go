@@ -0,0 +1,347 @@
+package core
+
+import (
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/ohohleo/classify/data"
+	"golang.org/x/text/unicode/norm"
+)
+
+// Weight of each criteria in the matching score
+const (
+	MATCH_TITLE_WEIGHT     = 0.6
+	MATCH_YEAR_WEIGHT      = 0.25
+	MATCH_AGREEMENT_WEIGHT = 0.15
+
+	// Minimum title similarity to consider that 2 websites agree
+	MATCH_AGREEMENT_SIMILARITY = 0.9
+)
+
+// Candidate is a website result ranked against the buffer item
+type Candidate struct {
+	Id         string  `json:"id"`
+	Website    string  `json:"website"`
+	Index      int     `json:"index"`
+	Name       string  `json:"name"`
+	Year       int     `json:"year,omitempty"`
+	Similarity float32 `json:"similarity"`
+	YearScore  float32 `json:"yearScore"`
+	Agreement  float32 `json:"agreement"`
+	Score      float32 `json:"score"`
+
+	title string
+}
+
+// GetMatchId returns the id used to select website result
+func GetMatchId(website string, idx int, d data.Data) string {
+
+	if movie, ok := d.(*data.Movie); ok && movie.Id != "" {
+		return website + ":" + movie.Id
+	}
+
+	return website + ":#" + strconv.Itoa(idx)
+}
+
+// Match ranks all the movies found on websites against the name
+// specified, the best candidate comes first
+func Match(name string, websites map[string][]data.Data) []*Candidate {
+
+	candidates := make([]*Candidate, 0)
+
+	title, year := SplitNameYear(name)
+	title = NormalizeTitle(title)
+
+	// Keep websites order stable
+	names := make([]string, 0, len(websites))
+	for website := range websites {
+		names = append(names, website)
+	}
+	sort.Strings(names)
+
+	byWebsite := make(map[string][]*Candidate)
+
+	for _, website := range names {
+		for idx, d := range websites[website] {
+
+			movie, ok := d.(*data.Movie)
+			if ok == false {
+				continue
+			}
+
+			candidate := &Candidate{
+				Id:      GetMatchId(website, idx, d),
+				Website: website,
+				Index:   idx,
+				Name:    movie.Name,
+				title:   NormalizeTitle(movie.Name),
+			}
+
+			if movie.Released.IsZero() == false {
+				candidate.Year = movie.Released.Year()
+			}
+
+			candidate.Similarity = TitleSimilarity(title, candidate.title)
+			candidate.YearScore = yearScore(year, candidate.Year)
+
+			candidates = append(candidates, candidate)
+			byWebsite[website] = append(byWebsite[website], candidate)
+		}
+	}
+
+	for _, candidate := range candidates {
+
+		candidate.Agreement = agreement(candidate, byWebsite)
+
+		// Weighted average of all known criteria
+		score := MATCH_TITLE_WEIGHT * candidate.Similarity
+		weight := float32(MATCH_TITLE_WEIGHT)
+
+		if candidate.YearScore >= 0 {
+			score += MATCH_YEAR_WEIGHT * candidate.YearScore
+			weight += MATCH_YEAR_WEIGHT
+		}
+
+		if candidate.Agreement >= 0 {
+			score += MATCH_AGREEMENT_WEIGHT * candidate.Agreement
+			weight += MATCH_AGREEMENT_WEIGHT
+		}
+
+		candidate.Score = score / weight
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].Score > candidates[j].Score
+	})
+
+	return candidates
+}
+
+// IsSame returns true if the other candidate is the same movie found on
+// another website
+func (c *Candidate) IsSame(other *Candidate) bool {
+
+	if c.Website == other.Website {
+		return false
+	}
+
+	if c.Year != 0 && other.Year != 0 && c.Year != other.Year {
+		return false
+	}
+
+	return TitleSimilarity(NormalizeTitle(c.Name),
+		NormalizeTitle(other.Name)) >= MATCH_AGREEMENT_SIMILARITY
+}
+
+// Lead returns the score difference between the best candidate and the
+// next different movie
+func Lead(candidates []*Candidate) float32 {
+
+	if len(candidates) == 0 {
+		return 0
+	}
+
+	best := candidates[0]
+
+	for _, other := range candidates[1:] {
+
+		if best.IsSame(other) {
+			continue
+		}
+
+		return best.Score - other.Score
+	}
+
+	return best.Score
+}
+
+// Returns 1 for the same year, 0.5 for 1 year difference, -1 if unknown
+func yearScore(expected int, year int) float32 {
+
+	if expected == 0 || year == 0 {
+		return -1
+	}
+
+	switch diff := expected - year; {
+	case diff == 0:
+		return 1
+	case diff == 1 || diff == -1:
+		return 0.5
+	}
+
+	return 0
+}
+
+// Returns the ratio of the other websites returning the same movie, -1
+// if no other website did answer
+func agreement(candidate *Candidate, byWebsite map[string][]*Candidate) float32 {
+
+	others, agree := 0, 0
+
+	for website, results := range byWebsite {
+
+		if website == candidate.Website {
+			continue
+		}
+
+		others++
+
+		for _, other := range results {
+
+			if candidate.Year != 0 && other.Year != 0 &&
+				candidate.Year != other.Year {
+				continue
+			}
+
+			if TitleSimilarity(candidate.title, other.title) >= MATCH_AGREEMENT_SIMILARITY {
+				agree++
+				break
+			}
+		}
+	}
+
+	if others == 0 {
+		return -1
+	}
+
+	return float32(agree) / float32(others)
+}
+
+var reYear = regexp.MustCompile(`\b((?:19|20)\d{2})\b`)
+
+// SplitNameYear extracts the release year from the name: the last year
+// found (not at the beginning) is used, everything after is ignored
+func SplitNameYear(name string) (title string, year int) {
+
+	title = name
+	maxYear := time.Now().Year() + 1
+
+	matches := reYear.FindAllStringSubmatchIndex(name, -1)
+	for idx := len(matches) - 1; idx >= 0; idx-- {
+
+		start, end := matches[idx][2], matches[idx][3]
+
+		// Year at the beginning is part of the title
+		if strings.TrimSpace(name[:start]) == "" {
+			break
+		}
+
+		found, _ := strconv.Atoi(name[start:end])
+		if found > maxYear {
+			continue
+		}
+
+		title = strings.TrimRight(name[:start], " ([-_.")
+		year = found
+		break
+	}
+
+	return
+}
+
+// NormalizeTitle removes accents, case & punctuation
+func NormalizeTitle(title string) string {
+
+	var b strings.Builder
+
+	for _, r := range norm.NFD.String(title) {
+
+		switch {
+		case unicode.Is(unicode.Mn, r):
+			// Remove accents
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			b.WriteRune(unicode.ToLower(r))
+		default:
+			b.WriteRune(' ')
+		}
+	}
+
+	return strings.Join(strings.Fields(b.String()), " ")
+}
+
+// TitleSimilarity returns a similarity between 0 and 1 based on the edit
+// distance & the common words of normalized titles
+func TitleSimilarity(a string, b string) float32 {
+
+	if a == b {
+		return 1
+	}
+
+	if a == "" || b == "" {
+		return 0
+	}
+
+	ra, rb := []rune(a), []rune(b)
+
+	maxLen := len(ra)
+	if len(rb) > maxLen {
+		maxLen = len(rb)
+	}
+
+	edit := 1 - float32(levenshtein(ra, rb))/float32(maxLen)
+
+	// Dice coefficient on words
+	words := make(map[string]int)
+	for _, word := range strings.Fields(a) {
+		words[word]++
+	}
+
+	wordsA, wordsB := len(strings.Fields(a)), 0
+	common := 0
+	for _, word := range strings.Fields(b) {
+		wordsB++
+		if words[word] > 0 {
+			words[word]--
+			common++
+		}
+	}
+
+	dice := 2 * float32(common) / float32(wordsA+wordsB)
+
+	return (edit + dice) / 2
+}
+
+func levenshtein(a []rune, b []rune) int {
+
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+
+	for j := range previous {
+		previous[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+
+		current[0] = i
+
+		for j := 1; j <= len(b); j++ {
+
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+
+			current[j] = minInt(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+
+		previous, current = current, previous
+	}
+
+	return previous[len(b)]
+}
+
+func minInt(values ...int) int {
+
+	result := values[0]
+	for _, value := range values[1:] {
+		if value < result {
+			result = value
+		}
+	}
+
+	return result
+}
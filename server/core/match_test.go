@@ -0,0 +1,100 @@
+package core
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ohohleo/classify/data"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSplitNameYear(t *testing.T) {
+
+	assert := assert.New(t)
+
+	for name, expected := range map[string]struct {
+		title string
+		year  int
+	}{
+		"Looper 2012 1080p":      {"Looper", 2012},
+		"Looper (2012)":          {"Looper", 2012},
+		"2001 A Space Odyssey":   {"2001 A Space Odyssey", 0},
+		"Blade Runner 2049 2017": {"Blade Runner 2049", 2017},
+		"Le Fabuleux Destin":     {"Le Fabuleux Destin", 0},
+	} {
+		title, year := SplitNameYear(name)
+		assert.Equal(expected.title, title, name)
+		assert.Equal(expected.year, year, name)
+	}
+}
+
+func TestNormalizeTitle(t *testing.T) {
+
+	assert := assert.New(t)
+
+	assert.Equal("le fabuleux destin d amelie poulain",
+		NormalizeTitle("Le Fabuleux Destin d'Amélie Poulain"))
+	assert.Equal("medecin de campagne", NormalizeTitle("  Médecin  de_Campagne "))
+	assert.Equal(float32(1), TitleSimilarity("looper", "looper"))
+	assert.Equal(float32(0), TitleSimilarity("looper", ""))
+	assert.True(TitleSimilarity("star wars", "star war") >
+		TitleSimilarity("star wars", "star trek"))
+}
+
+func TestMatch(t *testing.T) {
+
+	assert := assert.New(t)
+
+	released := func(year int) time.Time {
+		return time.Date(year, 1, 1, 0, 0, 0, 0, time.UTC)
+	}
+
+	websites := map[string][]data.Data{
+		"TMDB": []data.Data{
+			&data.Movie{Id: "1", Name: "Looper", Released: released(1999)},
+			&data.Movie{Id: "2", Name: "Looper", Released: released(2012)},
+			&data.Movie{Id: "3", Name: "The Loop", Released: released(2012)},
+			&data.Generic{Name: "ignored"},
+		},
+		"IMDB": []data.Data{
+			&data.Movie{Name: "Looper", Released: released(2012)},
+		},
+	}
+
+	candidates := Match("Looper 2012", websites)
+	if assert.Len(candidates, 4) {
+
+		// Same score: websites order is kept
+		assert.Equal("IMDB:#0", candidates[0].Id)
+		assert.Equal(float32(1), candidates[0].Score)
+
+		assert.Equal("TMDB:2", candidates[1].Id)
+		assert.Equal(float32(1), candidates[1].Score)
+		assert.Equal(float32(1), candidates[1].Agreement)
+
+		assert.Equal("TMDB:1", candidates[2].Id)
+		assert.Equal(float32(0), candidates[2].YearScore)
+		assert.Equal(float32(0), candidates[2].Agreement)
+
+		assert.Equal("TMDB:3", candidates[3].Id)
+	}
+
+	// Without year & only one website: only title is used
+	candidates = Match("Looper", map[string][]data.Data{
+		"TMDB": websites["TMDB"][:1],
+	})
+	if assert.Len(candidates, 1) {
+		assert.Equal(float32(-1), candidates[0].YearScore)
+		assert.Equal(float32(-1), candidates[0].Agreement)
+		assert.Equal(float32(1), candidates[0].Score)
+	}
+
+	// Best candidate selected
+	item := NewBufferItem(1)
+	item.CleanedName = "Looper 2012"
+	item.Websites = websites
+	item.Match()
+
+	assert.Equal("IMDB:#0", item.MatchId)
+	assert.Equal(float32(1), item.IsMatching)
+}
@@ -0,0 +1,221 @@
+package core
+
+import (
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/ohohleo/classify/cron"
+	"github.com/ohohleo/classify/database"
+	"github.com/ohohleo/classify/imports"
+)
+
+// Import schedule stored
+type scheduleStruct struct {
+	Schedule string    `json:"schedule"`
+	LastRun  time.Time `json:"lastRun"`
+}
+
+// Runs of the import: the next run is set only if scheduled
+type ImportRuns struct {
+	Schedule string     `json:"schedule,omitempty"`
+	LastRun  *time.Time `json:"lastRun,omitempty"`
+	NextRun  *time.Time `json:"nextRun,omitempty"`
+}
+
+// GetRuns returns the schedule & the run times of the import
+func (i *Import) GetRuns() (runs ImportRuns) {
+
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+
+	runs.Schedule = i.schedule
+
+	if i.lastRun.IsZero() == false {
+		lastRun := i.lastRun
+		runs.LastRun = &lastRun
+	}
+
+	if i.timer != nil && i.nextRun.IsZero() == false {
+		nextRun := i.nextRun
+		runs.NextRun = &nextRun
+	}
+
+	return
+}
+
+// IsRunning returns true if the import data are being received
+func (i *Import) IsRunning() bool {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+	return i.isRunning
+}
+
+// Keep the import running status & the last run date
+func (i *Import) setRunning(isRunning bool) {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+
+	i.isRunning = isRunning
+	if isRunning {
+		i.lastRun = time.Now()
+	}
+}
+
+// Set the schedule without arming it: the previous timer is stopped
+func (i *Import) setSchedule(expr string) (err error) {
+
+	var schedule *cron.Schedule
+	if expr != "" {
+		if schedule, err = cron.Parse(expr); err != nil {
+			return
+		}
+	}
+
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+
+	if i.timer != nil {
+		i.timer.Stop()
+		i.timer = nil
+	}
+
+	i.schedule = expr
+	i.cron = schedule
+	i.nextRun = time.Time{}
+	return
+}
+
+// StoreSchedule2DB keeps the schedule & the last run date of the import
+func (i *Import) StoreSchedule2DB(db *database.Database) error {
+
+	// Check if db is enabled
+	if db == nil {
+		return nil
+	}
+
+	i.mutex.Lock()
+	stored := &scheduleStruct{
+		Schedule: i.schedule,
+		LastRun:  i.lastRun,
+	}
+	i.mutex.Unlock()
+
+	if stored.Schedule == "" && stored.LastRun.IsZero() {
+		return imports.DeleteDBSchedule(db, i.Id)
+	}
+
+	src, err := json.Marshal(stored)
+	if err != nil {
+		return err
+	}
+
+	return imports.StoreDBSchedule(db, i.Id, src)
+}
+
+// RetreiveDBSchedule restore the import schedule if stored
+func (i *Import) RetreiveDBSchedule(db *database.Database) error {
+
+	src, err := imports.RetreiveDBSchedule(db, i.Id)
+	if err != nil || src == nil {
+		return err
+	}
+
+	var stored scheduleStruct
+	if err := json.Unmarshal(src, &stored); err != nil {
+		return err
+	}
+
+	if err := i.setSchedule(stored.Schedule); err != nil {
+		return err
+	}
+
+	i.mutex.Lock()
+	i.lastRun = stored.LastRun
+	i.mutex.Unlock()
+
+	return nil
+}
+
+// SetImportSchedule runs the import with the cron schedule specified, no
+// more scheduled if empty
+func (c *Classify) SetImportSchedule(name string, schedule string) (err error) {
+
+	i, err := c.GetImportByName(name)
+	if err != nil {
+		return
+	}
+
+	if err = i.setSchedule(schedule); err != nil {
+		return
+	}
+
+	if err = i.StoreSchedule2DB(c.database); err != nil {
+		return
+	}
+
+	c.armSchedule(name, i)
+	return
+}
+
+// Start the timers of all the imports scheduled
+func (c *Classify) startSchedules() {
+	for name, i := range c.imports {
+		c.armSchedule(name, i)
+	}
+}
+
+// Wait for the next run of the scheduled import
+func (c *Classify) armSchedule(name string, i *Import) {
+
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+
+	if i.cron == nil {
+		return
+	}
+
+	now := time.Now()
+	i.nextRun = i.cron.Next(now)
+	if i.nextRun.IsZero() {
+		log.Printf("[%s] schedule '%s' never runs\n", name, i.schedule)
+		return
+	}
+
+	schedule := i.cron
+	i.timer = time.AfterFunc(i.nextRun.Sub(now), func() {
+		c.runSchedule(name, i, schedule)
+	})
+}
+
+// Start the scheduled import unless the previous run is not over
+func (c *Classify) runSchedule(name string, i *Import, schedule *cron.Schedule) {
+
+	// Schedule modified meanwhile
+	i.mutex.Lock()
+	isModified := i.cron != schedule
+	i.mutex.Unlock()
+
+	if isModified {
+		return
+	}
+
+	if i.IsRunning() {
+		log.Printf("[%s] scheduled run skipped: previous run not over\n", name)
+	} else if err := c.StartImports(map[string]*Import{name: i}, nil); err != nil {
+		log.Printf("[%s] scheduled run: %s\n", name, err.Error())
+	}
+
+	c.armSchedule(name, i)
+}
+
+// Returns an error if the schedule is invalid
+func CheckSchedule(schedule string) error {
+
+	if schedule == "" {
+		return nil
+	}
+
+	_, err := cron.Parse(schedule)
+	return err
+}
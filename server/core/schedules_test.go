@@ -0,0 +1,123 @@
+package core
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ohohleo/classify/collections"
+	"github.com/ohohleo/classify/imports"
+	"github.com/stretchr/testify/assert"
+)
+
+// Wait for the condition during the delay specified
+func waitFor(delay time.Duration, condition func() bool) bool {
+
+	for end := time.Now().Add(delay); time.Now().Before(end); {
+		if condition() {
+			return true
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	return condition()
+}
+
+func TestScheduleDB(t *testing.T) {
+
+	assert := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "classify")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	source := filepath.Join(dir, "classify.db")
+	path := filepath.Join(dir, "files")
+	assert.Nil(os.Mkdir(path, 0755))
+	assert.Nil(ioutil.WriteFile(filepath.Join(path, "file.txt"), nil, 0644))
+
+	c := newTestClassify(t, source)
+
+	collection, err := c.CreateCollection("test", collections.SIMPLE, nil, nil)
+	if !assert.Nil(err) {
+		return
+	}
+
+	i, _, err := c.CreateImport("files", imports.DIRECTORY,
+		[]byte(`{"path": "`+path+`"}`),
+		map[string]*Collection{"test": collection})
+	if !assert.Nil(err) {
+		return
+	}
+
+	// Not scheduled: no next run
+	runs := i.GetRuns()
+	assert.Equal("", runs.Schedule)
+	assert.Nil(runs.LastRun)
+	assert.Nil(runs.NextRun)
+
+	assert.NotNil(c.SetImportSchedule("files", "@every 1ms"))
+
+	// Scheduled: next run returned
+	start := time.Now()
+	assert.Nil(c.SetImportSchedule("files", "@every 1s"))
+
+	runs = i.GetRuns()
+	assert.Equal("@every 1s", runs.Schedule)
+	assert.Nil(runs.LastRun)
+	if assert.NotNil(runs.NextRun) {
+		assert.True(runs.NextRun.After(start))
+		assert.True(runs.NextRun.Before(start.Add(2 * time.Second)))
+	}
+
+	// Import started by the schedule
+	assert.True(waitFor(3*time.Second, func() bool {
+		return i.GetRuns().LastRun != nil && i.IsRunning() == false
+	}))
+	assert.Len(collection.GetItems(), 1)
+
+	runs = i.GetRuns()
+	if assert.NotNil(runs.LastRun) && assert.NotNil(runs.NextRun) {
+		assert.True(runs.LastRun.After(start))
+		assert.True(runs.NextRun.After(*runs.LastRun))
+	}
+
+	// Scheduled run skipped while the previous one is running
+	assert.Nil(c.SetImportSchedule("files", ""))
+	assert.Nil(i.setSchedule("@every 1s"))
+
+	i.setRunning(true)
+	lastRun := i.GetRuns().LastRun
+
+	c.runSchedule("files", i, i.cron)
+	assert.Equal(lastRun, i.GetRuns().LastRun)
+	assert.NotNil(i.GetRuns().NextRun)
+
+	i.setRunning(false)
+
+	// Last run kept once restarted
+	assert.Nil(i.StoreSchedule2DB(c.database))
+	assert.Nil(c.SetImportSchedule("files", "@every 1h"))
+	c.StopImports(nil, nil)
+
+	c = newTestClassify(t, source)
+
+	i, err = c.GetImportByName("files")
+	if assert.Nil(err) {
+		runs = i.GetRuns()
+		assert.Equal("@every 1h", runs.Schedule)
+		if assert.NotNil(runs.LastRun) {
+			assert.True(runs.LastRun.Equal(*lastRun))
+		}
+		if assert.NotNil(runs.NextRun) {
+			assert.True(runs.NextRun.After(time.Now().Add(59 * time.Minute)))
+		}
+
+		assert.Nil(c.SetImportSchedule("files", ""))
+		assert.Nil(i.GetRuns().NextRun)
+	}
+}
@@ -0,0 +1,51 @@
+package core
+
+import (
+	"errors"
+)
+
+var errSecretsDisabled = errors.New("secrets not configured")
+
+// Returns the configuration with the secrets referenced resolved
+func (c *Classify) resolveConfig(config map[string]string) (res map[string]string, err error) {
+
+	res = make(map[string]string, len(config))
+
+	for key, value := range config {
+		if res[key], err = c.secrets.Resolve(value); err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+// GetSecretNames returns the names of the secrets stored
+func (c *Classify) GetSecretNames() ([]string, error) {
+
+	if c.secrets == nil {
+		return nil, errSecretsDisabled
+	}
+
+	return c.secrets.GetNames(), nil
+}
+
+// SetSecret encrypts & stores the secret value
+func (c *Classify) SetSecret(name string, value string) error {
+
+	if c.secrets == nil {
+		return errSecretsDisabled
+	}
+
+	return c.secrets.Set(name, value)
+}
+
+// DeleteSecret removes the secret
+func (c *Classify) DeleteSecret(name string) error {
+
+	if c.secrets == nil {
+		return errSecretsDisabled
+	}
+
+	return c.secrets.Delete(name)
+}
@@ -39,8 +39,8 @@ func (v *Value) MarshalJSON() (src []byte, err error) {
 func (v *Value) UnmarshalJSON(src []byte) (err error) {
 
 	// Decode value
-	type value *Value
-	if err = json.Unmarshal(src, value(v)); err != nil {
+	type value Value
+	if err = json.Unmarshal(src, (*value)(v)); err != nil {
 
 		// Check is it text only?
 		if src[0] == '"' && src[len(src)-1] == '"' {
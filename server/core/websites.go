@@ -2,6 +2,8 @@ package core
 
 import (
 	"errors"
+	"fmt"
+	"github.com/ohohleo/classify/requests"
 	"github.com/ohohleo/classify/websites"
 	"github.com/ohohleo/classify/websites/IMDB"
 	"github.com/ohohleo/classify/websites/TMDB"
@@ -37,6 +39,12 @@ func (c *Classify) AddWebsite(name string) (website websites.Website, err error)
 		config, ok := c.config.Websites[name]
 		if ok {
 
+			// Secrets referenced resolved
+			if config, err = c.resolveConfig(config); err != nil {
+				err = fmt.Errorf("website '%s' %s", name, err.Error())
+				return
+			}
+
 			// If the configuration does exist : we set it
 			if website.SetConfig(config) == false {
 				err = errors.New("wrong configuration '" + name + "'")
@@ -64,3 +72,26 @@ func (c *Classify) DeleteWebsite(name string) error {
 func (c *Classify) GetWebsites() []string {
 	return websites.REF_IDX2STR
 }
+
+// GetCacheStats returns the websites cache statistics by website
+func (c *Classify) GetCacheStats() (map[string]*requests.CacheStats, error) {
+
+	cache := c.requests.GetCache()
+	if cache == nil {
+		return nil, errors.New("websites cache disabled")
+	}
+
+	return cache.GetStats(), nil
+}
+
+// PurgeCache removes the cached responses of the website specified (all
+// websites if empty), only the expired ones if specified
+func (c *Classify) PurgeCache(website string, expiredOnly bool) (int, error) {
+
+	cache := c.requests.GetCache()
+	if cache == nil {
+		return 0, errors.New("websites cache disabled")
+	}
+
+	return cache.Purge(website, expiredOnly)
+}
@@ -0,0 +1,238 @@
+package cron
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedules shortcuts
+var macros = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// Next dates searched until this number of years
+const YEARS_MAX = 5
+
+type field struct {
+	name  string
+	min   int
+	max   int
+	names map[string]int
+}
+
+var fields = []field{
+	{name: "minute", min: 0, max: 59},
+	{name: "hour", min: 0, max: 23},
+	{name: "day of month", min: 1, max: 31},
+	{name: "month", min: 1, max: 12, names: map[string]int{
+		"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+		"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+	}},
+	// Sunday is 0 or 7
+	{name: "day of week", min: 0, max: 7, names: map[string]int{
+		"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+	}},
+}
+
+// Schedule of the standard cron expressions: "minute hour day-of-month
+// month day-of-week", the macros (ie. '@daily') & '@every DURATION'
+type Schedule struct {
+	minute, hour, dom, month, dow uint64
+
+	// Day of month & day of week restricted: the day matches if one of
+	// them matches
+	isDomStar, isDowStar bool
+
+	every time.Duration
+}
+
+// Parse the cron expression
+func Parse(expr string) (s *Schedule, err error) {
+
+	expr = strings.TrimSpace(expr)
+
+	if strings.HasPrefix(expr, "@every ") {
+
+		var every time.Duration
+		every, err = time.ParseDuration(strings.TrimSpace(strings.TrimPrefix(expr, "@every ")))
+		if err != nil {
+			err = fmt.Errorf("invalid schedule '%s': %s", expr, err.Error())
+			return
+		}
+
+		if every < time.Second {
+			err = fmt.Errorf("invalid schedule '%s': at least every second", expr)
+			return
+		}
+
+		return &Schedule{every: every}, nil
+	}
+
+	if macro, ok := macros[strings.ToLower(expr)]; ok {
+		expr = macro
+	}
+
+	values := strings.Fields(expr)
+	if len(values) != len(fields) {
+		err = fmt.Errorf("invalid schedule '%s': %d fields expected",
+			expr, len(fields))
+		return
+	}
+
+	bits := make([]uint64, len(fields))
+	for idx, value := range values {
+		if bits[idx], err = fields[idx].parse(value); err != nil {
+			err = fmt.Errorf("invalid schedule '%s': %s", expr, err.Error())
+			return
+		}
+	}
+
+	s = &Schedule{
+		minute:    bits[0],
+		hour:      bits[1],
+		dom:       bits[2],
+		month:     bits[3],
+		dow:       bits[4],
+		isDomStar: strings.HasPrefix(values[2], "*"),
+		isDowStar: strings.HasPrefix(values[4], "*"),
+	}
+
+	// Sunday handled as the day 0
+	if s.dow&(1<<7) != 0 {
+		s.dow |= 1
+	}
+
+	return
+}
+
+// Returns the bits of the values of the field: lists of '*', 'a', 'a-b'
+// with an optional step '/n'
+func (f *field) parse(value string) (bits uint64, err error) {
+
+	for _, item := range strings.Split(value, ",") {
+
+		step := 1
+		if idx := strings.IndexByte(item, '/'); idx >= 0 {
+
+			step, err = strconv.Atoi(item[idx+1:])
+			if err != nil || step <= 0 {
+				return 0, fmt.Errorf("invalid %s step '%s'", f.name, item)
+			}
+
+			item = item[:idx]
+		}
+
+		start, end := f.min, f.max
+		switch {
+		case item == "*":
+
+		case strings.IndexByte(item, '-') > 0:
+			idx := strings.IndexByte(item, '-')
+			if start, err = f.parseValue(item[:idx]); err != nil {
+				return
+			}
+			if end, err = f.parseValue(item[idx+1:]); err != nil {
+				return
+			}
+			if start > end {
+				return 0, fmt.Errorf("invalid %s range '%s'", f.name, item)
+			}
+
+		default:
+			if start, err = f.parseValue(item); err != nil {
+				return
+			}
+
+			// Single value unless a step is specified
+			if step == 1 {
+				end = start
+			}
+		}
+
+		for v := start; v <= end; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+
+	return
+}
+
+func (f *field) parseValue(value string) (int, error) {
+
+	if v, ok := f.names[strings.ToLower(value)]; ok {
+		return v, nil
+	}
+
+	v, err := strconv.Atoi(value)
+	if err != nil || v < f.min || v > f.max {
+		return 0, fmt.Errorf("invalid %s '%s'", f.name, value)
+	}
+
+	return v, nil
+}
+
+func has(bits uint64, value int) bool {
+	return bits&(1<<uint(value)) != 0
+}
+
+func (s *Schedule) matchDay(t time.Time) bool {
+
+	dom := has(s.dom, t.Day())
+	dow := has(s.dow, int(t.Weekday()))
+
+	if s.isDomStar || s.isDowStar {
+		return dom && dow
+	}
+
+	return dom || dow
+}
+
+// Next returns the first date scheduled after the date specified, zero
+// if none found
+func (s *Schedule) Next(t time.Time) time.Time {
+
+	if s.every > 0 {
+		return t.Truncate(time.Second).Add(s.every)
+	}
+
+	loc := t.Location()
+
+	// Next minute
+	t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute()+1, 0, 0, loc)
+	yearMax := t.Year() + YEARS_MAX
+
+	for t.Year() <= yearMax {
+
+		if has(s.month, int(t.Month())) == false {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, loc)
+			continue
+		}
+
+		if s.matchDay(t) == false {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, loc)
+			continue
+		}
+
+		if has(s.hour, t.Hour()) == false {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, loc)
+			continue
+		}
+
+		if has(s.minute, t.Minute()) == false {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute()+1, 0, 0, loc)
+			continue
+		}
+
+		return t
+	}
+
+	return time.Time{}
+}
@@ -0,0 +1,80 @@
+package cron
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse(t *testing.T) {
+
+	assert := assert.New(t)
+
+	for _, expr := range []string{
+		"",
+		"* * * *",
+		"* * * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"10-5 * * * *",
+		"*/0 * * * *",
+		"* * * foo *",
+		"@every",
+		"@every 10ms",
+		"@every tomorrow",
+	} {
+		_, err := Parse(expr)
+		assert.NotNil(err, expr)
+	}
+
+	for _, expr := range []string{
+		"* * * * *",
+		"*/15 8-18 * * mon-fri",
+		"0,30 0 1,15 jan-jun/2 7",
+		"@daily",
+		"@HOURLY",
+		"@every 1h30m",
+	} {
+		_, err := Parse(expr)
+		assert.Nil(err, expr)
+	}
+}
+
+func TestNext(t *testing.T) {
+
+	assert := assert.New(t)
+
+	// Wednesday
+	now := time.Date(2019, time.January, 30, 10, 7, 42, 0, time.UTC)
+
+	for expr, next := range map[string]time.Time{
+		"* * * * *":    time.Date(2019, time.January, 30, 10, 8, 0, 0, time.UTC),
+		"*/15 * * * *": time.Date(2019, time.January, 30, 10, 15, 0, 0, time.UTC),
+		"0 9 * * *":    time.Date(2019, time.January, 31, 9, 0, 0, 0, time.UTC),
+		"30 8 * * sun": time.Date(2019, time.February, 3, 8, 30, 0, 0, time.UTC),
+		"0 0 * * 7":    time.Date(2019, time.February, 3, 0, 0, 0, 0, time.UTC),
+		"0 0 31 * *":   time.Date(2019, time.January, 31, 0, 0, 0, 0, time.UTC),
+		"0 0 29 2 *":   time.Date(2020, time.February, 29, 0, 0, 0, 0, time.UTC),
+		"0 0 30 2 *":   {},
+
+		// Day of month or day of week
+		"0 0 15 * fri": time.Date(2019, time.February, 1, 0, 0, 0, 0, time.UTC),
+		"0 0 1 * thu":  time.Date(2019, time.January, 31, 0, 0, 0, 0, time.UTC),
+
+		"@hourly":      time.Date(2019, time.January, 30, 11, 0, 0, 0, time.UTC),
+		"@monthly":     time.Date(2019, time.February, 1, 0, 0, 0, 0, time.UTC),
+		"@yearly":      time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC),
+		"@every 1h30m": time.Date(2019, time.January, 30, 11, 37, 42, 0, time.UTC),
+	} {
+		s, err := Parse(expr)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Equal(next, s.Next(now), expr)
+	}
+}
@@ -0,0 +1,286 @@
+package data
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/bzip2"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Archive formats handled by their extension
+var archiveExtensions = []string{
+	".zip",
+	".tar",
+	".tar.gz",
+	".tgz",
+	".tar.bz2",
+	".tbz2",
+}
+
+// File inside an archive
+type ArchiveMember struct {
+	Name    string    `json:"name"`
+	Path    string    `json:"path"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"modTime"`
+	Archive *File     `json:"archive"`
+
+	// Directory where the member is extracted when validated or exported
+	ExtractPath string `json:"extractPath,omitempty"`
+
+	// Member extracted
+	File *File `json:"file,omitempty"`
+}
+
+func (m *ArchiveMember) GetName() string {
+	return m.Name
+}
+
+func (m *ArchiveMember) GetRef() Ref {
+	return ARCHIVE_MEMBER
+}
+
+// GetContentId identifies the member by its archive & its path inside
+// the archive: members with the same name are distinct
+func (m *ArchiveMember) GetContentId() string {
+
+	if m.Archive == nil {
+		return m.Path
+	}
+
+	archive := m.Archive.ContentId
+	if archive == "" {
+		archive = m.Archive.Path
+	}
+
+	return archive + ":" + m.Path
+}
+
+func (m *ArchiveMember) GetDependencies() []Data {
+
+	if m.Archive == nil {
+		m.Archive = new(File)
+	}
+
+	return []Data{
+		m.Archive,
+	}
+}
+
+func (m *ArchiveMember) GetContents() (contents map[string]string) {
+
+	if m.File == nil {
+		return
+	}
+
+	return m.File.GetContents()
+}
+
+// GetSize returns the member uncompressed size
+func (m *ArchiveMember) GetSize() (int64, bool) {
+	return m.Size, true
+}
+
+// GetDate returns the member modification date
+func (m *ArchiveMember) GetDate() (time.Time, bool) {
+	return m.ModTime, m.ModTime.IsZero() == false
+}
+
+// Extract the member in the extraction directory: returns true if the
+// member is newly extracted
+func (m *ArchiveMember) Extract() (bool, error) {
+
+	if m.ExtractPath == "" || m.Archive == nil {
+		return false, nil
+	}
+
+	// Already extracted
+	if m.File != nil {
+		if _, err := os.Stat(m.File.Path); err == nil {
+			return false, nil
+		}
+	}
+
+	// Members extracted in a directory named after their archive
+	dst, err := getExtractPath(m.ExtractPath, m.Archive.Name, m.Path)
+	if err != nil {
+		return false, err
+	}
+
+	err = ReadArchive(m.Archive.Path, func(member *ArchiveMember, r io.Reader) (bool, error) {
+
+		if member.Path != m.Path {
+			return true, nil
+		}
+
+		return false, writeFile(dst, r)
+	})
+	if err != nil {
+		return false, err
+	}
+
+	if _, err = os.Stat(dst); err != nil {
+		return false, fmt.Errorf("member '%s' not found in '%s'", m.Path, m.Archive.Path)
+	}
+
+	if m.File, err = NewFileFromPath(filepath.Dir(dst), filepath.Base(dst)); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// Returns the destination of the member: the members can't be extracted
+// outside the directory
+func getExtractPath(dir string, archive string, member string) (string, error) {
+
+	name := strings.TrimSuffix(archive, GetArchiveExtension(archive))
+	cleaned := path.Clean("/" + filepath.ToSlash(member))
+
+	if cleaned == "/" {
+		return "", fmt.Errorf("invalid archive member '%s'", member)
+	}
+
+	return filepath.Join(dir, name, filepath.FromSlash(cleaned)), nil
+}
+
+func writeFile(dst string, r io.Reader) error {
+
+	if err := os.MkdirAll(filepath.Dir(dst), os.ModePerm); err != nil {
+		return err
+	}
+
+	f, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+
+	if _, err = io.Copy(f, r); err != nil {
+		f.Close()
+		os.Remove(dst)
+		return err
+	}
+
+	return f.Close()
+}
+
+// GetArchiveExtension returns the archive extension of the file name,
+// empty if not an archive
+func GetArchiveExtension(name string) string {
+
+	lower := strings.ToLower(name)
+	for _, extension := range archiveExtensions {
+		if strings.HasSuffix(lower, extension) {
+			return name[len(name)-len(extension):]
+		}
+	}
+
+	return ""
+}
+
+func IsArchive(name string) bool {
+	return GetArchiveExtension(name) != ""
+}
+
+// ReadArchive calls onMember with each regular file of the archive until
+// it returns false
+func ReadArchive(archive string, onMember func(*ArchiveMember, io.Reader) (bool, error)) error {
+
+	extension := strings.ToLower(GetArchiveExtension(archive))
+	if extension == "" {
+		return fmt.Errorf("'%s' is not an archive", archive)
+	}
+
+	if extension == ".zip" {
+		return readZip(archive, onMember)
+	}
+
+	f, err := os.Open(archive)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var r io.Reader = f
+	switch extension {
+	case ".tar.gz", ".tgz":
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return fmt.Errorf("'%s': %s", archive, err.Error())
+		}
+		defer gz.Close()
+		r = gz
+
+	case ".tar.bz2", ".tbz2":
+		r = bzip2.NewReader(f)
+	}
+
+	tr := tar.NewReader(r)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("'%s': %s", archive, err.Error())
+		}
+
+		if header.Typeflag != tar.TypeReg && header.Typeflag != tar.TypeRegA {
+			continue
+		}
+
+		member := NewArchiveMember(header.Name, header.Size, header.ModTime)
+		if next, err := onMember(member, tr); err != nil || next == false {
+			return err
+		}
+	}
+}
+
+func readZip(archive string, onMember func(*ArchiveMember, io.Reader) (bool, error)) error {
+
+	zr, err := zip.OpenReader(archive)
+	if err != nil {
+		return fmt.Errorf("'%s': %s", archive, err.Error())
+	}
+	defer zr.Close()
+
+	for _, f := range zr.File {
+
+		if f.Mode().IsRegular() == false {
+			continue
+		}
+
+		r, err := f.Open()
+		if err != nil {
+			return fmt.Errorf("'%s': %s", archive, err.Error())
+		}
+
+		member := NewArchiveMember(f.Name, int64(f.UncompressedSize64), f.Modified)
+		next, err := onMember(member, r)
+		r.Close()
+
+		if err != nil || next == false {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// NewArchiveMember returns the member found at the path specified inside
+// its archive
+func NewArchiveMember(name string, size int64, modTime time.Time) *ArchiveMember {
+	return &ArchiveMember{
+		Name:    path.Base(filepath.ToSlash(name)),
+		Path:    name,
+		Size:    size,
+		ModTime: modTime,
+	}
+}
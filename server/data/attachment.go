@@ -2,7 +2,9 @@ package data
 
 import (
 	"bytes"
+	"crypto/sha256"
 	"encoding/base64"
+	"encoding/hex"
 	"fmt"
 	"io/ioutil"
 	"mime/multipart"
@@ -10,12 +12,21 @@ import (
 	"time"
 )
 
+// Size of the content hash prefix in the attachment file names
+const ATTACHMENT_HASH_SIZE = 16
+
 type Attachment struct {
 	Name               string          `json:"name"`
 	Date               time.Time       `json:"date"`
+	ContentType        string          `json:"contentType"`
 	ContentDisposition string          `json:"contentDisposition"`
+	ContentId          string          `json:"contentId,omitempty"`
+	Inline             bool            `json:"inline"`
 	File               *File           `json:"file"`
 	Part               *multipart.Part `json:"-"`
+
+	// Content already decoded
+	Content []byte `json:"-"`
 }
 
 func (s *Attachment) GetName() string {
@@ -26,6 +37,16 @@ func (s *Attachment) GetRef() Ref {
 	return ATTACHMENT
 }
 
+// GetContentId returns the content hash of the attachment file stored
+func (s *Attachment) GetContentId() string {
+
+	if s.File == nil {
+		return ""
+	}
+
+	return s.File.ContentId
+}
+
 func (s *Attachment) GetDependencies() []Data {
 
 	if s.File == nil {
@@ -46,21 +67,44 @@ func (s *Attachment) GetContents() (contents map[string]string) {
 	return s.File.GetContents()
 }
 
+// StoreToFile writes the attachment in the directory specified: the file
+// name holds the content hash, the attachments of different messages
+// sharing the same date & name are not mixed up
 func (s *Attachment) StoreToFile(path string) error {
 
-	if s.Part == nil {
+	if s.Part == nil && s.Content == nil {
 		return fmt.Errorf("No attachment '%s' to store at '%s'",
 			s.Name, path)
 	}
 
+	toWrite, err := s.getContent()
+	if err != nil {
+		return err
+	}
+
+	if len(toWrite) <= 0 {
+		return nil
+	}
+
+	// Content read once
+	s.Content = toWrite
+
+	sum := sha256.Sum256(toWrite)
+	hash := hex.EncodeToString(sum[:])
+
 	// Get filename
-	name := s.Date.Format("20060102_150405") + "_" + s.Name
+	name := s.Date.Format("20060102_150405") + "_" +
+		hash[:ATTACHMENT_HASH_SIZE] + "_" + s.Name
 	fullname := path + "/" + name
 
-	// Check if file already exist
+	// Check if file already exist: same content
 	if _, err := os.Stat(fullname); os.IsNotExist(err) == false {
-		s.File, err = NewFileFromPath(path, name)
-		return err
+		if s.File, err = NewFileFromPath(path, name); err != nil {
+			return err
+		}
+
+		s.setHash(hash)
+		return nil
 	}
 
 	// Check if directory already exist
@@ -72,30 +116,7 @@ func (s *Attachment) StoreToFile(path string) error {
 		}
 	}
 
-	// Read all file
-	slurp, err := ioutil.ReadAll(s.Part)
-	if err != nil {
-		return err
-	}
-
-	if len(slurp) <= 0 {
-		return nil
-	}
-
-	fmt.Printf("Create '%s' with size %d\n", fullname, len(slurp))
-
-	var toWrite []byte
-	switch s.Part.Header.Get("Content-Transfer-Encoding") {
-
-	case "base64":
-		reader := bytes.NewReader(slurp)
-		data := base64.NewDecoder(base64.StdEncoding, reader)
-		buffer := new(bytes.Buffer)
-		buffer.ReadFrom(data)
-		toWrite = buffer.Bytes()
-	default:
-		toWrite = slurp
-	}
+	fmt.Printf("Create '%s' with size %d\n", fullname, len(toWrite))
 
 	// Write data into destination
 	f, err := os.Create(fullname)
@@ -115,5 +136,38 @@ func (s *Attachment) StoreToFile(path string) error {
 		return err
 	}
 
+	s.setHash(hash)
 	return nil
 }
+
+// The content hash identifies the attachment along with its name
+func (s *Attachment) setHash(hash string) {
+	s.File.Hash = hash
+	s.File.ContentId = hash
+}
+
+// Returns the content decoded
+func (s *Attachment) getContent() ([]byte, error) {
+
+	if s.Content != nil {
+		return s.Content, nil
+	}
+
+	// Read all file
+	slurp, err := ioutil.ReadAll(s.Part)
+	if err != nil {
+		return nil, err
+	}
+
+	switch s.Part.Header.Get("Content-Transfer-Encoding") {
+
+	case "base64":
+		reader := bytes.NewReader(slurp)
+		data := base64.NewDecoder(base64.StdEncoding, reader)
+		buffer := new(bytes.Buffer)
+		buffer.ReadFrom(data)
+		return buffer.Bytes(), nil
+	}
+
+	return slurp, nil
+}
@@ -3,8 +3,9 @@ package data
 import (
 	"crypto/md5"
 	"encoding/json"
+	"fmt"
 	"math/big"
-	//"time"
+	"time"
 	//"github.com/pariz/gountries"
 )
 
@@ -14,6 +15,8 @@ const (
 	MOVIE
 	EMAIL
 	ATTACHMENT
+	FEED_ENTRY
+	ARCHIVE_MEMBER
 )
 
 type Ref uint64
@@ -28,14 +31,80 @@ var REF_IDX2STR = []string{
 	"movie",
 	"email",
 	"attachment",
+	"feedEntry",
+	"archiveMember",
 }
 
 var REF_STR2IDX = map[string]Ref{
-	REF_IDX2STR[GENERIC]:    GENERIC,
-	REF_IDX2STR[FILE]:       FILE,
-	REF_IDX2STR[MOVIE]:      MOVIE,
-	REF_IDX2STR[EMAIL]:      EMAIL,
-	REF_IDX2STR[ATTACHMENT]: ATTACHMENT,
+	REF_IDX2STR[GENERIC]:        GENERIC,
+	REF_IDX2STR[FILE]:           FILE,
+	REF_IDX2STR[MOVIE]:          MOVIE,
+	REF_IDX2STR[EMAIL]:          EMAIL,
+	REF_IDX2STR[ATTACHMENT]:     ATTACHMENT,
+	REF_IDX2STR[FEED_ENTRY]:     FEED_ENTRY,
+	REF_IDX2STR[ARCHIVE_MEMBER]: ARCHIVE_MEMBER,
+}
+
+// Create empty data from the ref specified
+func New(ref Ref) (Data, error) {
+	switch ref {
+	case GENERIC:
+		return new(Generic), nil
+	case FILE:
+		return new(File), nil
+	case MOVIE:
+		return new(Movie), nil
+	case EMAIL:
+		return new(Email), nil
+	case ATTACHMENT:
+		return new(Attachment), nil
+	case FEED_ENTRY:
+		return new(FeedEntry), nil
+	case ARCHIVE_MEMBER:
+		return new(ArchiveMember), nil
+	}
+
+	return nil, fmt.Errorf("data ref '%d' not handled", ref)
+}
+
+// Create data from the ref name and its JSON content
+func NewFromJSON(refStr string, src []byte) (d Data, err error) {
+
+	ref, ok := REF_STR2IDX[refStr]
+	if ok == false {
+		err = fmt.Errorf("data ref '%s' not handled", refStr)
+		return
+	}
+
+	if d, err = New(ref); err != nil {
+		return
+	}
+
+	err = json.Unmarshal(src, d)
+	return
+}
+
+// Data stored with its ref to retreive the concrete type
+type Stored struct {
+	Ref  string          `json:"ref"`
+	Data json.RawMessage `json:"data"`
+}
+
+func NewStored(d Data) (*Stored, error) {
+
+	raw, err := json.Marshal(d)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Stored{
+		Ref:  d.GetRef().String(),
+		Data: raw,
+	}, nil
+}
+
+func (s *Stored) Get() (Data, error) {
+	return NewFromJSON(s.Ref, s.Data)
 }
 
 type Data interface {
@@ -45,10 +114,19 @@ type Data interface {
 	GetRef() Ref
 }
 
+// Optional content hash identifying the data along with its name: the
+// data with the same name but different contents are distinct
+type HasContentId interface {
+	GetContentId() string
+}
+
 func GetId(d Data) uint64 {
 	res := big.NewInt(0)
 	hash := md5.New()
 	hash.Write([]byte(d.GetRef().String() + d.GetName()))
+	if h, ok := d.(HasContentId); ok && h.GetContentId() != "" {
+		hash.Write([]byte(":" + h.GetContentId()))
+	}
 	res.SetBytes(hash.Sum(nil))
 	return res.Uint64()
 }
@@ -63,6 +141,22 @@ type HasContents interface {
 	GetContents() map[string]string
 }
 
+// Optional data size in bytes: ok is false if unknown
+type HasSize interface {
+	GetSize() (size int64, ok bool)
+}
+
+// Optional data date: ok is false if unknown
+type HasDate interface {
+	GetDate() (date time.Time, ok bool)
+}
+
+// Optional content extracted when the item is validated or exported:
+// ok is true if newly extracted
+type HasExtraction interface {
+	Extract() (ok bool, err error)
+}
+
 // Add data functionalities
 // - IconsConfig
 // - FileConfig
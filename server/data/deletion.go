@@ -0,0 +1,25 @@
+package data
+
+// Deletion notifies that the data has been removed from its import: it
+// shares the reference, the name & the content id of the data removed, so
+// its id too
+type Deletion struct {
+	Data Data
+}
+
+func (d *Deletion) GetName() string {
+	return d.Data.GetName()
+}
+
+func (d *Deletion) GetRef() Ref {
+	return d.Data.GetRef()
+}
+
+func (d *Deletion) GetContentId() string {
+
+	if h, ok := d.Data.(HasContentId); ok {
+		return h.GetContentId()
+	}
+
+	return ""
+}
@@ -12,6 +12,10 @@ type Email struct {
 	CCI         []string      `json:"cci"`
 	Date        time.Time     `json:"date"`
 	ContentType string        `json:"contentType"`
+	MessageId   string        `json:"messageId"`
+	InReplyTo   string        `json:"inReplyTo"`
+	Text        string        `json:"text"`
+	Html        string        `json:"html"`
 	Attachments []*Attachment `json:"attachments"`
 }
 
@@ -19,6 +23,11 @@ func (e *Email) GetName() string {
 	return e.From + ":" + e.Subject
 }
 
+// GetDate returns the date the email was sent
+func (e *Email) GetDate() (time.Time, bool) {
+	return e.Date, e.Date.IsZero() == false
+}
+
 func (e *Email) GetRef() Ref {
 	return EMAIL
 
@@ -0,0 +1,44 @@
+package data
+
+import (
+	"time"
+)
+
+// Entry of a RSS or Atom feed
+type FeedEntry struct {
+	Guid        string     `json:"guid"`
+	Feed        string     `json:"feed"`
+	Title       string     `json:"title"`
+	Link        string     `json:"link"`
+	Author      string     `json:"author,omitempty"`
+	Description string     `json:"description,omitempty"`
+	Categories  []string   `json:"categories,omitempty"`
+	Published   time.Time  `json:"published"`
+	Enclosure   *Enclosure `json:"enclosure,omitempty"`
+}
+
+// Media attached to the entry (ie. podcast episode)
+type Enclosure struct {
+	Url    string `json:"url"`
+	Type   string `json:"type"`
+	Length int64  `json:"length"`
+}
+
+func (f *FeedEntry) GetName() string {
+	return f.Title
+}
+
+// GetContentId identifies the entry by its guid: entries with the same
+// title are distinct
+func (f *FeedEntry) GetContentId() string {
+	return f.Guid
+}
+
+// GetDate returns the date the entry was published
+func (f *FeedEntry) GetDate() (time.Time, bool) {
+	return f.Published, f.Published.IsZero() == false
+}
+
+func (f *FeedEntry) GetRef() Ref {
+	return FEED_ENTRY
+}
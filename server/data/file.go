@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"time"
 )
 
 type FileConfig struct {
@@ -22,8 +23,14 @@ type File struct {
 	ContentType string            `json:"contentType"`
 	Icons       Icons             `json:"icons"`
 	Infos       map[string]string `json:"infos"`
+	PartialHash string            `json:"partialHash,omitempty"`
+	Hash        string            `json:"hash,omitempty"`
 	FileInfo    os.FileInfo       `json:"-"`
 
+	// Content hash identifying the file along with its name, set by the
+	// imports hashing the contents
+	ContentId string `json:"contentId,omitempty"`
+
 	file *os.File
 }
 
@@ -66,6 +73,10 @@ func (f *File) GetRef() Ref {
 	return FILE
 }
 
+func (f *File) GetContentId() string {
+	return f.ContentId
+}
+
 func (f *File) NewConfig() Config {
 	return new(FileConfig)
 }
@@ -134,3 +145,25 @@ func (f *File) GetFileInfo() (fileInfo os.FileInfo, err error) {
 
 	return
 }
+
+// GetSize returns the file size
+func (f *File) GetSize() (int64, bool) {
+
+	fileInfo, err := f.GetFileInfo()
+	if err != nil {
+		return 0, false
+	}
+
+	return fileInfo.Size(), true
+}
+
+// GetDate returns the file modification date
+func (f *File) GetDate() (time.Time, bool) {
+
+	fileInfo, err := f.GetFileInfo()
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	return fileInfo.ModTime(), true
+}
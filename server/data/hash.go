@@ -0,0 +1,130 @@
+package data
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+)
+
+// Size of the content read at the beginning, the middle & the end of the
+// file for the partial hash
+const HASH_PARTIAL_SIZE = 64 * 1024
+
+// Optional data content hashes: the partial hash is fast to compute but
+// only the full hash identifies the content
+type HasHash interface {
+	GetPartialHash() (string, error)
+	GetHash() (string, error)
+}
+
+// PartialHash returns the SHA-256 of the file size & of the beginning,
+// the middle & the end of its content
+func PartialHash(path string) (string, error) {
+
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return "", err
+	}
+
+	size := info.Size()
+
+	hash := sha256.New()
+	binary.Write(hash, binary.BigEndian, size)
+
+	offsets := []int64{0}
+	if size > 3*HASH_PARTIAL_SIZE {
+		offsets = append(offsets,
+			(size-HASH_PARTIAL_SIZE)/2, size-HASH_PARTIAL_SIZE)
+	}
+
+	buf := make([]byte, HASH_PARTIAL_SIZE)
+	for _, offset := range offsets {
+
+		n, err := f.ReadAt(buf, offset)
+		if err != nil && err != io.EOF {
+			return "", err
+		}
+
+		hash.Write(buf[:n])
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// FullHash returns the SHA-256 of the whole file content
+func FullHash(path string) (string, error) {
+
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, f); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// GetPartialHash computes the partial hash once
+func (f *File) GetPartialHash() (hash string, err error) {
+
+	if f.PartialHash == "" {
+		if f.Path == "" {
+			return "", fmt.Errorf("file '%s' without path", f.Name)
+		}
+
+		if f.PartialHash, err = PartialHash(f.Path); err != nil {
+			return
+		}
+	}
+
+	return f.PartialHash, nil
+}
+
+// GetHash computes the full hash once
+func (f *File) GetHash() (hash string, err error) {
+
+	if f.Hash == "" {
+		if f.Path == "" {
+			return "", fmt.Errorf("file '%s' without path", f.Name)
+		}
+
+		if f.Hash, err = FullHash(f.Path); err != nil {
+			return
+		}
+	}
+
+	return f.Hash, nil
+}
+
+// GetPartialHash returns the partial hash of the attachment file stored
+func (s *Attachment) GetPartialHash() (string, error) {
+
+	if s.File == nil {
+		return "", fmt.Errorf("attachment '%s' not stored", s.Name)
+	}
+
+	return s.File.GetPartialHash()
+}
+
+// GetHash returns the full hash of the attachment file stored
+func (s *Attachment) GetHash() (string, error) {
+
+	if s.File == nil {
+		return "", fmt.Errorf("attachment '%s' not stored", s.Name)
+	}
+
+	return s.File.GetHash()
+}
@@ -5,6 +5,7 @@ import (
 )
 
 type Movie struct {
+	Id          string    `json:"id"`
 	Name        string    `json:"name"`
 	Url         string    `json:"url"`
 	Released    time.Time `json:"released"`
@@ -12,6 +13,7 @@ type Movie struct {
 	Image       string    `json:"image"`
 	Description string    `json:"description"`
 	Directors   []string  `json:"directors"`
+	Writers     []string  `json:"writers"`
 	Cast        []string  `json:"cast"`
 	Genres      []string  `json:"genres"`
 }
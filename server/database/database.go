@@ -43,6 +43,13 @@ var genAttributes = map[string]*Attribute{
 	"params": &Attribute{
 		Type: TEXT,
 	},
+	"uid": &Attribute{
+		Type:      TEXT,
+		IsNotNull: true,
+	},
+	"data": &Attribute{
+		Type: TEXT,
+	},
 }
 
 type Database struct {
@@ -77,6 +84,12 @@ func New(config Config) (d *Database, err error) {
 var reTableName = regexp.MustCompile("([a-z0-9_]+)_id$")
 
 func (d *Database) AddTable(table string, parameters []string) error {
+	return d.AddTableWithUnique(table, parameters, nil)
+}
+
+// AddTableWithUnique add new table with unicity on specified
+// attributes, otherwise unicity is set on all {table_name}_id attributes
+func (d *Database) AddTableWithUnique(table string, parameters []string, unique []string) error {
 
 	// Check if table name already exists
 	if _, ok := d.tables[table]; ok {
@@ -92,7 +105,7 @@ func (d *Database) AddTable(table string, parameters []string) error {
 		d.tables = make(map[string]*Table)
 	}
 
-	var unique []string
+	isUniqueSet := len(unique) > 0
 
 	// Initialize attributes
 	attributes := make(map[string]*Attribute)
@@ -116,7 +129,10 @@ func (d *Database) AddTable(table string, parameters []string) error {
 					tableNameId, table)
 			}
 
-			unique = append(unique, name)
+			if isUniqueSet == false {
+				unique = append(unique, name)
+			}
+
 			attribute = &Attribute{Type: INTEGER}
 
 		} else {
@@ -132,6 +148,14 @@ func (d *Database) AddTable(table string, parameters []string) error {
 		attributes[name] = attribute
 	}
 
+	// Check unique attributes are all referenced
+	for _, name := range unique {
+		if _, ok := attributes[name]; ok == false {
+			return fmt.Errorf("unique attribute '%s' not found on table '%s'",
+				name, table)
+		}
+	}
+
 	// Store table
 	d.tables[table] = &Table{
 		Name:       table,
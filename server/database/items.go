@@ -0,0 +1,60 @@
+package database
+
+type ItemStruct struct {
+	Id            uint64 `db:"id"`
+	CollectionsId uint64 `db:"collections_id"`
+	Uid           string `db:"uid"`
+	Ref           uint64 `db:"ref"`
+	Data          []byte `db:"data"`
+}
+
+// AddItemsTable add table storing items of each collection: an item is
+// unique by its uid inside the same collection
+func (d *Database) AddItemsTable(table string) error {
+	return d.AddTableWithUnique(table,
+		[]string{"id", "collections_id", "uid", "ref", "data"},
+		[]string{"collections_id", "uid"})
+}
+
+// StoreItem insert or replace the item stored in the collection
+func (d *Database) StoreItem(table string, item *ItemStruct) error {
+	_, err := d.Insert(table, item)
+	return err
+}
+
+// DeleteItem remove the item stored in the collection
+func (d *Database) DeleteItem(table string, collectionId uint64, uid string) error {
+	return d.Delete(table, &ItemStruct{
+		CollectionsId: collectionId,
+		Uid:           uid,
+	}, "collections_id = :collections_id AND uid = :uid")
+}
+
+// DeleteItems remove all the items stored in the collection
+func (d *Database) DeleteItems(table string, collectionId uint64) error {
+	return d.Delete(table, &ItemStruct{
+		CollectionsId: collectionId,
+	}, "collections_id = :collections_id")
+}
+
+type OnItem func(uid string, ref uint64, data []byte) error
+
+// RetreiveItems returns all the items stored in the collection
+func (d *Database) RetreiveItems(table string, collectionId uint64, onItem OnItem) (err error) {
+
+	var items []ItemStruct
+	err = d.Select(&items,
+		"SELECT * FROM "+table+" WHERE collections_id = ?", collectionId)
+	if err != nil {
+		return
+	}
+
+	for _, item := range items {
+
+		if err = onItem(item.Uid, item.Ref, item.Data); err != nil {
+			return
+		}
+	}
+
+	return
+}
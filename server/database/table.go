@@ -97,7 +97,7 @@ func (t *Table) Delete(condition string) (result string) {
 
 func (t *Table) Update(keys []string, condition string) (result string, err error) {
 	if len(keys) == 0 {
-		err = fmt.Errorf("db update of '%' has no keys", t.Name)
+		err = fmt.Errorf("db update of '%s' has no keys", t.Name)
 		return
 	}
 
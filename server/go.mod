@@ -4,14 +4,18 @@ go 1.13
 
 require (
 	github.com/ant0ine/go-json-rest v3.3.2+incompatible
-	github.com/emersion/go-imap v1.0.2
+	github.com/emersion/go-imap v1.0.6
+	github.com/emersion/go-imap-idle v0.0.0-20210907174914-db2568431445
 	github.com/foize/go.fifo v0.0.0-20130327144150-3a04cfeec121
+	github.com/fsnotify/fsnotify v1.4.9
 	github.com/hydrogen18/stoppableListener v0.0.0-20161101122645-827d760f0663
 	github.com/jmoiron/sqlx v1.2.0
 	github.com/mattn/go-sqlite3 v2.0.2+incompatible
 	github.com/pariz/gountries v0.0.0-20191029140926-233bc78cf5b5
 	github.com/quirkey/magick v0.0.0-20140324185457-b37664054620
 	github.com/ryanbradynd05/go-tmdb v0.0.0-20190901200645-e8dd22863620
+	github.com/stretchr/testify v1.3.0
 	golang.org/x/net v0.0.0-20191209160850-c0dbc17a3553
+	golang.org/x/text v0.3.2
 	gopkg.in/yaml.v2 v2.2.7 // indirect
 )
@@ -24,6 +24,18 @@ func INIT_DB(db *database.Database) (err error) {
 		return
 	}
 
+	err = db.AddTable("imports_states",
+		[]string{"imports_id", "data"})
+	if err != nil {
+		return
+	}
+
+	err = db.AddTable("imports_schedules",
+		[]string{"imports_id", "data"})
+	if err != nil {
+		return
+	}
+
 	return
 }
 
@@ -63,3 +75,68 @@ func RetreiveDBImports(db *database.Database, onImport OnImport) (err error) {
 	return
 
 }
+
+type stateStruct struct {
+	ImportsId uint64 `db:"imports_id"`
+	Data      []byte `db:"data"`
+}
+
+// StoreDBState insert or replace the state of the import
+func StoreDBState(db *database.Database, id uint64, state []byte) error {
+	_, err := db.Insert("imports_states", &stateStruct{
+		ImportsId: id,
+		Data:      state,
+	})
+	return err
+}
+
+// RetreiveDBState returns nil if no state is stored for the import
+func RetreiveDBState(db *database.Database, id uint64) (state []byte, err error) {
+
+	var states []stateStruct
+	err = db.Select(&states,
+		"SELECT * FROM imports_states WHERE imports_id = ?", id)
+	if err != nil || len(states) == 0 {
+		return
+	}
+
+	state = states[0].Data
+	return
+}
+
+// DeleteDBState remove the state of the import
+func DeleteDBState(db *database.Database, id uint64) error {
+	return db.Delete("imports_states", &stateStruct{
+		ImportsId: id,
+	}, "imports_id = :imports_id")
+}
+
+// StoreDBSchedule insert or replace the schedule of the import
+func StoreDBSchedule(db *database.Database, id uint64, schedule []byte) error {
+	_, err := db.Insert("imports_schedules", &stateStruct{
+		ImportsId: id,
+		Data:      schedule,
+	})
+	return err
+}
+
+// RetreiveDBSchedule returns nil if no schedule is stored for the import
+func RetreiveDBSchedule(db *database.Database, id uint64) (schedule []byte, err error) {
+
+	var schedules []stateStruct
+	err = db.Select(&schedules,
+		"SELECT * FROM imports_schedules WHERE imports_id = ?", id)
+	if err != nil || len(schedules) == 0 {
+		return
+	}
+
+	schedule = schedules[0].Data
+	return
+}
+
+// DeleteDBSchedule remove the schedule of the import
+func DeleteDBSchedule(db *database.Database, id uint64) error {
+	return db.Delete("imports_schedules", &stateStruct{
+		ImportsId: id,
+	}, "imports_id = :imports_id")
+}
@@ -5,20 +5,61 @@ import (
 	"encoding/json"
 	// "errors"
 	"fmt"
+	"github.com/fsnotify/fsnotify"
 	"github.com/ohohleo/classify/data"
 	"github.com/ohohleo/classify/imports"
+	"github.com/ohohleo/classify/metadata"
 	"github.com/ohohleo/classify/params"
+	"io"
 	"io/ioutil"
+	"log"
+	"os"
 	"os/exec"
+	"path/filepath"
 	"strings"
+	"sync"
+	"time"
 )
 
+// Delay without any change after which a file watched is sent: the files
+// still being copied are not sent partially
+var settleDelay = 2 * time.Second
+
+// File watched waiting to be completely written
+type pendingFile struct {
+	size    int64
+	modTime time.Time
+	updated time.Time
+}
+
+// State of a file already imported
+type FileState struct {
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"modTime"`
+	Hash    string    `json:"hash,omitempty"`
+
+	// Path of the archive members sent
+	Members []string `json:"members,omitempty"`
+}
+
 type Directory struct {
 	Path        string `json:"path"`
 	IsRecursive bool   `json:"is_recursive"`
+	Watch       bool   `json:"watch"`
+	Hash        bool   `json:"hash"`
+
+	// Send the files inside the archives (zip, tar, tar.gz, tar.bz2)
+	Archives bool `json:"archives"`
+
+	// Directory where the archive files are extracted when validated or
+	// exported, not extracted if empty
+	ExtractPath string `json:"extractPath"`
+
 	exiftoolCmd string
 	isRunning   bool
-	needToStop  bool
+	stop        chan struct{}
+	state       map[string]*FileState
+	mutex       sync.Mutex
 }
 
 func (r *Directory) GetRef() imports.Ref {
@@ -28,6 +69,7 @@ func (r *Directory) GetRef() imports.Ref {
 func (r *Directory) GetDatasReferences() []data.Data {
 	return []data.Data{
 		new(data.File),
+		new(data.ArchiveMember),
 	}
 }
 
@@ -132,84 +174,577 @@ func (r *Directory) CheckConfig(config json.RawMessage) error {
 	return nil
 }
 
-// Return a channel of files in the directory
+// Return a channel of files in the directory: in watch mode the channel
+// stays open after the scan to send the new files & the deletions
 func (r *Directory) Start() (chan data.Data, error) {
 
 	c := make(chan data.Data)
 
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
 	// Check if the analysis is not already going on
 	if r.isRunning {
 		return c, fmt.Errorf("import 'directory' already started!")
 	}
 
+	path := filepath.Clean(r.Path)
+
+	// Check if exiftool exists
+	if r.exiftoolCmd == "" {
+		if cmd, err := exec.LookPath("exiftool"); err == nil {
+			r.exiftoolCmd = cmd
+		}
+	}
+
+	// Watch the directories before the scan to miss no file
+	var watcher *fsnotify.Watcher
+	dirs := make(map[string]bool)
+	if r.Watch {
+
+		var err error
+		if watcher, err = fsnotify.NewWatcher(); err != nil {
+			return c, err
+		}
+
+		if err = r.addWatch(watcher, dirs, path); err != nil {
+			watcher.Close()
+			return c, err
+		}
+	}
+
 	// Analysis is starting
 	r.isRunning = true
 
 	// Reset stop process
-	r.needToStop = false
+	stop := make(chan struct{})
+	r.stop = stop
 
 	go func() {
 
-		r.readDirectory(c, r.Path, r.IsRecursive)
+		// Only the new & modified files are sent, the files not found
+		// anymore are reported as deleted
+		seen := make(map[string]bool)
+		ok := r.readDirectory(c, stop, path, r.IsRecursive, seen) &&
+			r.sendDeletions(c, stop, seen)
+
+		if watcher != nil {
+			if ok {
+				r.watch(c, stop, watcher, dirs)
+			}
+			watcher.Close()
+		}
+
 		close(c)
 
 		// Analysis is over
+		r.mutex.Lock()
 		r.isRunning = false
+		r.mutex.Unlock()
 	}()
 
 	return c, nil
 }
 
 func (r *Directory) Stop() error {
-	r.needToStop = true
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.stop != nil {
+		close(r.stop)
+		r.stop = nil
+	}
+
 	return nil
 }
 
-func (r *Directory) readDirectory(c chan data.Data, path string, isRecursive bool) {
+// GetState returns the files already imported
+func (r *Directory) GetState() (json.RawMessage, error) {
 
-	// Read directory
-	files, _ := ioutil.ReadDir(path)
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
 
-	for _, f := range files {
+	return json.Marshal(r.state)
+}
+
+// SetState set the files already imported
+func (r *Directory) SetState(src json.RawMessage) error {
+
+	var state map[string]*FileState
+	if err := json.Unmarshal(src, &state); err != nil {
+		return err
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.state = state
+	return nil
+}
 
-		if r.needToStop {
-			break
+// Read the directory: returns false if the import is stopped
+func (r *Directory) readDirectory(c chan data.Data, stop chan struct{}, path string, isRecursive bool, seen map[string]bool) bool {
+
+	// Read directory
+	files, err := ioutil.ReadDir(path)
+	if err != nil {
+		log.Printf("import 'directory' %s\n", err.Error())
+
+		// Files not reported as deleted if not readable
+		if seen != nil {
+			r.mutex.Lock()
+			for filePath := range r.state {
+				if strings.HasPrefix(filePath, path+"/") {
+					seen[filePath] = true
+				}
+			}
+			r.mutex.Unlock()
 		}
 
+		return true
+	}
+
+	for _, f := range files {
+
 		if f.IsDir() {
 
 			// Read recursively
 			if isRecursive {
-				r.readDirectory(c, path+"/"+f.Name(), isRecursive)
+				if r.readDirectory(c, stop, path+"/"+f.Name(), isRecursive, seen) == false {
+					return false
+				}
 			}
 
 			continue
 		}
 
-		// Get new file
-		file, err := data.NewFileFromPath(path, f.Name())
+		if seen != nil {
+			seen[path+"/"+f.Name()] = true
+		}
+
+		if r.sendFile(c, stop, path, f) == false {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Send the file analysed if new or modified: returns false if the import
+// is stopped
+func (r *Directory) sendFile(c chan data.Data, stop chan struct{}, path string, f os.FileInfo) bool {
+
+	state, previous, ok := r.checkFile(path+"/"+f.Name(), f)
+	if ok == false {
+		return true
+	}
+
+	// Modified file: the data previously imported are replaced
+	if previous != nil {
+		if sendFileDeletion(c, stop, path+"/"+f.Name(), previous) == false {
+			return false
+		}
+	}
+
+	// Get new file
+	file, err := data.NewFileFromPath(path, f.Name())
+	if err != nil {
+		fmt.Printf("Issue when analysing file %s", err.Error())
+		return true
+	}
+
+	// Store FileInfo
+	file.FileInfo = f
+
+	// Files with the same name but different contents are distinct
+	if state.Hash != "" {
+		file.Hash = state.Hash
+		file.ContentId = state.Hash
+	}
+
+	// Search for file header infos
+	r.analyse(file)
+
+	if send(c, stop, file) == false {
+		return false
+	}
+
+	if r.Archives && data.IsArchive(file.Name) {
+		if r.sendMembers(c, stop, file, state) == false {
+			return false
+		}
+	}
+
+	r.setFileState(file.Path, state)
+	return true
+}
+
+// Send the files inside the archive & keep their path with the archive
+// state: returns false if the import is stopped
+func (r *Directory) sendMembers(c chan data.Data, stop chan struct{}, archive *data.File, state *FileState) bool {
+
+	stopped := false
+
+	err := data.ReadArchive(archive.Path, func(member *data.ArchiveMember, _ io.Reader) (bool, error) {
+
+		member.Archive = archive
+		member.ExtractPath = r.ExtractPath
+
+		if stopped = send(c, stop, member) == false; stopped == false {
+			state.Members = append(state.Members, member.Path)
+		}
+
+		return stopped == false, nil
+	})
+
+	if err != nil {
+		log.Printf("import 'directory' archive %s\n", err.Error())
+	}
+
+	return stopped == false
+}
+
+// Extract the file metadata: exiftool is used if installed for the
+// formats not handled
+func (r *Directory) analyse(file *data.File) {
+
+	infos, err := metadata.Extract(file.Path)
+	if err == nil {
+		file.Infos = infos
+		return
+	}
+
+	if r.exiftoolCmd != "" {
+		r.Analyse(r.exiftoolCmd, file)
+		return
+	}
+
+	if err != metadata.ErrUnknownFormat {
+		log.Printf("import 'directory' metadata %s: %s\n", file.Path, err.Error())
+	}
+}
+
+// Returns the current file state, the previous one if already imported
+// and true if the file is new or modified since the last import
+func (r *Directory) checkFile(path string, f os.FileInfo) (state *FileState, previous *FileState, ok bool) {
+
+	state = &FileState{
+		Size:    f.Size(),
+		ModTime: f.ModTime(),
+	}
+
+	r.mutex.Lock()
+	previous, exists := r.state[path]
+	r.mutex.Unlock()
+
+	if exists &&
+		previous.Size == state.Size && previous.ModTime.Equal(state.ModTime) {
+		return previous, nil, false
+	}
+
+	if r.Hash {
+
+		var err error
+		if state.Hash, err = data.FullHash(path); err != nil {
+			log.Printf("import 'directory' hash: %s\n", err.Error())
+		}
+
+		// Same content: only the modification time has changed
+		if exists && state.Hash != "" && state.Hash == previous.Hash {
+			state.Members = previous.Members
+			r.setFileState(path, state)
+			return state, nil, false
+		}
+	}
+
+	return state, previous, true
+}
+
+func (r *Directory) setFileState(path string, state *FileState) {
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.state == nil {
+		r.state = make(map[string]*FileState)
+	}
+
+	r.state[path] = state
+}
+
+// Remove the state of the file or of all the files of the directory
+// specified: returns the states removed
+func (r *Directory) removeFileStates(path string) (removed map[string]*FileState) {
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	removed = make(map[string]*FileState)
+	for filePath, state := range r.state {
+		if filePath == path || strings.HasPrefix(filePath, path+"/") {
+			delete(r.state, filePath)
+			removed[filePath] = state
+		}
+	}
+
+	return
+}
+
+// Send the deletion of the files imported but not found during the scan:
+// returns false if the import is stopped
+func (r *Directory) sendDeletions(c chan data.Data, stop chan struct{}, seen map[string]bool) bool {
+
+	r.mutex.Lock()
+	removed := make(map[string]*FileState)
+	for path, state := range r.state {
+		if seen[path] == false {
+			removed[path] = state
+		}
+	}
+	r.mutex.Unlock()
+
+	for path, state := range removed {
+
+		if sendFileDeletion(c, stop, path, state) == false {
+			return false
+		}
+
+		r.removeFileStates(path)
+	}
+
+	return true
+}
+
+// Send the deletion of the file & of its archive members: returns false
+// if the import is stopped
+func sendFileDeletion(c chan data.Data, stop chan struct{}, path string, state *FileState) bool {
+
+	archive := &data.File{
+		Name:      filepath.Base(path),
+		Path:      path,
+		Extension: filepath.Ext(path),
+		ContentId: state.Hash,
+	}
+
+	if send(c, stop, &data.Deletion{Data: archive}) == false {
+		return false
+	}
+
+	for _, memberPath := range state.Members {
+
+		member := data.NewArchiveMember(memberPath, 0, time.Time{})
+		member.Archive = archive
+
+		if send(c, stop, &data.Deletion{Data: member}) == false {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Send data through the channel unless the import is stopped
+func send(c chan data.Data, stop chan struct{}, d data.Data) bool {
+
+	select {
+	case c <- d:
+		return true
+	case <-stop:
+		return false
+	}
+}
+
+// Watch the directory (and its sub-directories if recursive)
+func (r *Directory) addWatch(watcher *fsnotify.Watcher, dirs map[string]bool, path string) error {
+
+	if err := watcher.Add(path); err != nil {
+		return fmt.Errorf("watch '%s': %s", path, err.Error())
+	}
+
+	dirs[path] = true
+
+	if r.IsRecursive == false {
+		return nil
+	}
+
+	files, err := ioutil.ReadDir(path)
+	if err != nil {
+		return err
+	}
+
+	for _, f := range files {
+		if f.IsDir() {
+			if err := r.addWatch(watcher, dirs, filepath.Join(path, f.Name())); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// Send the files changes until the import is stopped
+func (r *Directory) watch(c chan data.Data, stop chan struct{}, watcher *fsnotify.Watcher, dirs map[string]bool) {
+
+	pendings := make(map[string]*pendingFile)
+
+	ticker := time.NewTicker(settleDelay / 4)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+
+		case <-ticker.C:
+			if r.sendPendings(c, stop, pendings) == false {
+				return
+			}
+
+		case err, ok := <-watcher.Errors:
+			if ok == false {
+				return
+			}
+
+			log.Printf("import 'directory' %s: %s\n", r.Path, err.Error())
+
+		case event, ok := <-watcher.Events:
+			if ok == false {
+				return
+			}
+
+			if r.onEvent(c, stop, watcher, dirs, pendings, event) == false {
+				return
+			}
+		}
+	}
+}
+
+// Handle the file event: renamed files are received as the deletion of
+// the old name & the creation of the new one, the files created or
+// modified are sent once no more written
+func (r *Directory) onEvent(c chan data.Data, stop chan struct{}, watcher *fsnotify.Watcher, dirs map[string]bool, pendings map[string]*pendingFile, event fsnotify.Event) bool {
+
+	switch {
+	case event.Op&(fsnotify.Create|fsnotify.Write) != 0:
+
+		f, err := os.Stat(event.Name)
 		if err != nil {
-			fmt.Printf("Issue when analysing file %s", err.Error())
+			// Already removed
+			return true
+		}
+
+		if f.IsDir() {
+
+			// Watch & read the new sub-directory
+			if r.IsRecursive && event.Op&fsnotify.Create != 0 {
+				if err := r.addWatch(watcher, dirs, event.Name); err != nil {
+					log.Printf("import 'directory' %s\n", err.Error())
+				}
+
+				addPendings(pendings, event.Name)
+			}
+
+			return true
+		}
+
+		addPending(pendings, event.Name, f)
+
+	case event.Op&(fsnotify.Remove|fsnotify.Rename) != 0:
+
+		delete(pendings, event.Name)
+
+		// Sub-directory no more watched
+		if dirs[event.Name] {
+			watcher.Remove(event.Name)
+			delete(dirs, event.Name)
+		}
+
+		// Send the deletion of the files imported
+		for path, state := range r.removeFileStates(event.Name) {
+			if sendFileDeletion(c, stop, path, state) == false {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+func addPending(pendings map[string]*pendingFile, path string, f os.FileInfo) {
+	pendings[path] = &pendingFile{
+		size:    f.Size(),
+		modTime: f.ModTime(),
+		updated: time.Now(),
+	}
+}
+
+// Wait for all the files of the new directory to be written
+func addPendings(pendings map[string]*pendingFile, path string) {
+
+	files, err := ioutil.ReadDir(path)
+	if err != nil {
+		log.Printf("import 'directory' %s\n", err.Error())
+		return
+	}
+
+	for _, f := range files {
+
+		if f.IsDir() {
+			addPendings(pendings, filepath.Join(path, f.Name()))
 			continue
 		}
 
-		// Store FileInfo
-		file.FileInfo = f
+		addPending(pendings, filepath.Join(path, f.Name()), f)
+	}
+}
 
-		// Search for file header infos
-		if r.exiftoolCmd != "" {
-			r.Analyse(r.exiftoolCmd, file)
+// Send the files watched unchanged since the settle delay: returns false
+// if the import is stopped
+func (r *Directory) sendPendings(c chan data.Data, stop chan struct{}, pendings map[string]*pendingFile) bool {
+
+	now := time.Now()
+
+	for path, pending := range pendings {
+
+		if now.Sub(pending.updated) < settleDelay {
+			continue
+		}
+
+		f, err := os.Stat(path)
+		if err != nil {
+			// Already removed
+			delete(pendings, path)
+			continue
+		}
+
+		// Still written without any event received
+		if f.Size() != pending.size || f.ModTime().Equal(pending.modTime) == false {
+			pending.size = f.Size()
+			pending.modTime = f.ModTime()
+			pending.updated = now
+			continue
 		}
 
-		// Send file info through channel
-		c <- file
+		delete(pendings, path)
+
+		if r.sendFile(c, stop, filepath.Dir(path), f) == false {
+			return false
+		}
 	}
+
+	return true
 }
 
 func (r *Directory) Eq(new imports.Import) bool {
 	newDirectory, _ := new.(*Directory)
 	return r.Path == newDirectory.Path &&
-		r.IsRecursive == newDirectory.IsRecursive
+		r.IsRecursive == newDirectory.IsRecursive &&
+		r.Watch == newDirectory.Watch &&
+		r.Hash == newDirectory.Hash &&
+		r.Archives == newDirectory.Archives &&
+		r.ExtractPath == newDirectory.ExtractPath
 }
 
 func (r *Directory) Analyse(cmdStr string, file *data.File) {
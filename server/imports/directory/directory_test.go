@@ -1,34 +1,524 @@
 package directory
 
 import (
-	"github.com/ohohleo/classify/imports"
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"github.com/ohohleo/classify/data"
 	"github.com/stretchr/testify/assert"
-	"log"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
 )
 
+func newTestDirectory(t *testing.T, names ...string) string {
+
+	path, err := ioutil.TempDir("", "directory")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range names {
+		if err := ioutil.WriteFile(filepath.Join(path, name), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return path
+}
+
+// Wait for the next data sent
+func receive(t *testing.T, c chan data.Data) data.Data {
+
+	select {
+	case d := <-c:
+		return d
+	case <-time.After(5 * time.Second):
+		t.Fatal("no data received")
+	}
+
+	return nil
+}
+
 func TestReadDirectory(t *testing.T) {
 
 	assert := assert.New(t)
 
+	path := newTestDirectory(t, "a.txt", "b.txt")
+	defer os.RemoveAll(path)
+
 	directory := &Directory{
-		Path:        `/home/lmartin`,
+		Path:        path,
 		IsRecursive: false,
 	}
 
 	c, err := directory.Start()
 	if assert.Nil(err) {
-		for {
-
-			if data, ok := <-c; ok {
-				if f, ok := data.(imports.File); ok {
-					log.Printf("Received %s\n", f.FullPath)
-					continue
 
-				}
+		var names []string
+		for d := range c {
+			if f, ok := d.(*data.File); assert.True(ok) {
+				assert.Equal(filepath.Join(path, f.Name), f.Path)
+				names = append(names, f.Name)
 			}
+		}
+
+		assert.Equal([]string{"a.txt", "b.txt"}, names)
+	}
+}
+
+func TestWatch(t *testing.T) {
+
+	assert := assert.New(t)
+
+	settleDelay = 100 * time.Millisecond
+
+	path := newTestDirectory(t, "a.txt")
+	defer os.RemoveAll(path)
+
+	directory := &Directory{
+		Path:        path,
+		IsRecursive: true,
+		Watch:       true,
+	}
+
+	c, err := directory.Start()
+	if assert.Nil(err) == false {
+		return
+	}
+
+	// Initial scan
+	assert.Equal("a.txt", receive(t, c).GetName())
+
+	// New file
+	assert.Nil(ioutil.WriteFile(filepath.Join(path, "b.txt"), nil, 0644))
+	if f, ok := receive(t, c).(*data.File); assert.True(ok) {
+		assert.Equal("b.txt", f.Name)
+	}
+
+	// New file in a new sub-directory
+	sub := filepath.Join(path, "sub")
+	assert.Nil(os.Mkdir(sub, 0755))
+	time.Sleep(100 * time.Millisecond)
+	assert.Nil(ioutil.WriteFile(filepath.Join(sub, "c.txt"), nil, 0644))
+	if f, ok := receive(t, c).(*data.File); assert.True(ok) {
+		assert.Equal(filepath.Join(sub, "c.txt"), f.Path)
+	}
+
+	// Renamed file
+	assert.Nil(os.Rename(filepath.Join(path, "b.txt"), filepath.Join(path, "d.txt")))
+	if deletion, ok := receive(t, c).(*data.Deletion); assert.True(ok) {
+		assert.Equal("b.txt", deletion.GetName())
+		assert.Equal(data.GetId(&data.File{Name: "b.txt"}), data.GetId(deletion))
+	}
+	assert.Equal("d.txt", receive(t, c).GetName())
+
+	// Removed file
+	assert.Nil(os.Remove(filepath.Join(path, "a.txt")))
+	if deletion, ok := receive(t, c).(*data.Deletion); assert.True(ok) {
+		assert.Equal("a.txt", deletion.GetName())
+	}
+
+	// Already started
+	_, err = directory.Start()
+	assert.NotNil(err)
+
+	// Channel closed once stopped
+	assert.Nil(directory.Stop())
+	for range c {
+	}
+
+	_, err = directory.Start()
+	assert.Nil(err)
+	directory.Stop()
+}
+
+func TestWatchWriting(t *testing.T) {
+
+	assert := assert.New(t)
+
+	settleDelay = 200 * time.Millisecond
+
+	path := newTestDirectory(t)
+	defer os.RemoveAll(path)
 
-			break
+	directory := &Directory{
+		Path:  path,
+		Watch: true,
+	}
+
+	c, err := directory.Start()
+	if assert.Nil(err) == false {
+		return
+	}
+	defer directory.Stop()
+
+	// Initial scan over
+	time.Sleep(100 * time.Millisecond)
+
+	// File still being copied
+	f, err := os.Create(filepath.Join(path, "a.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for idx := 0; idx < 4; idx++ {
+		_, err = f.Write(make([]byte, 1024))
+		assert.Nil(err)
+		time.Sleep(settleDelay / 2)
+	}
+	f.Close()
+
+	// Sent once completely written
+	select {
+	case d := <-c:
+		if file, ok := d.(*data.File); assert.True(ok) {
+			assert.Equal("a.txt", file.Name)
+			assert.Equal(int64(4096), file.FileInfo.Size())
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no data received")
+	}
+
+	// Sent only once
+	select {
+	case d := <-c:
+		t.Fatalf("unexpected data %s", d.GetName())
+	case <-time.After(3 * settleDelay):
+	}
+}
+
+// Returns the names of the files & deletions received until the end
+func receiveAll(c chan data.Data) (files []string, deletions []string) {
+
+	for d := range c {
+		if _, ok := d.(*data.Deletion); ok {
+			deletions = append(deletions, d.GetName())
+		} else {
+			files = append(files, d.GetName())
 		}
 	}
+
+	return
+}
+
+func TestRescan(t *testing.T) {
+
+	assert := assert.New(t)
+
+	path := newTestDirectory(t, "a.txt", "b.txt")
+	defer os.RemoveAll(path)
+
+	directory := &Directory{Path: path + "/"}
+
+	c, err := directory.Start()
+	if assert.Nil(err) {
+		files, deletions := receiveAll(c)
+		assert.Equal([]string{"a.txt", "b.txt"}, files)
+		assert.Len(deletions, 0)
+	}
+
+	// Nothing changed
+	c, err = directory.Start()
+	if assert.Nil(err) {
+		files, deletions := receiveAll(c)
+		assert.Len(files, 0)
+		assert.Len(deletions, 0)
+	}
+
+	// Modified, removed & new files
+	assert.Nil(ioutil.WriteFile(filepath.Join(path, "a.txt"), []byte("a"), 0644))
+	assert.Nil(os.Remove(filepath.Join(path, "b.txt")))
+	assert.Nil(ioutil.WriteFile(filepath.Join(path, "c.txt"), nil, 0644))
+
+	c, err = directory.Start()
+	if assert.Nil(err) {
+		// Modified file replaced: deleted then sent again
+		files, deletions := receiveAll(c)
+		assert.Equal([]string{"a.txt", "c.txt"}, files)
+		assert.Equal([]string{"a.txt", "b.txt"}, deletions)
+	}
+
+	// State restored by another import
+	state, err := directory.GetState()
+	assert.Nil(err)
+
+	restored := &Directory{Path: path}
+	assert.Nil(restored.SetState(state))
+
+	c, err = restored.Start()
+	if assert.Nil(err) {
+		files, _ := receiveAll(c)
+		assert.Len(files, 0)
+	}
+
+	// Only the modification time changed: same content hash
+	hashed := &Directory{Path: path, Hash: true}
+	c, err = hashed.Start()
+	if assert.Nil(err) {
+		files, _ := receiveAll(c)
+		assert.Equal([]string{"a.txt", "c.txt"}, files)
+	}
+
+	date := time.Now().Add(time.Hour)
+	assert.Nil(os.Chtimes(filepath.Join(path, "a.txt"), date, date))
+	c, err = hashed.Start()
+	if assert.Nil(err) {
+		files, _ := receiveAll(c)
+		assert.Len(files, 0)
+	}
+
+	assert.Nil(ioutil.WriteFile(filepath.Join(path, "a.txt"), []byte("b"), 0644))
+	c, err = hashed.Start()
+	if assert.Nil(err) {
+		files, _ := receiveAll(c)
+		assert.Equal([]string{"a.txt"}, files)
+	}
+}
+
+func TestArchives(t *testing.T) {
+
+	assert := assert.New(t)
+
+	path := newTestDirectory(t)
+	defer os.RemoveAll(path)
+
+	extractPath := filepath.Join(path, "extracted")
+
+	// Zip archive with a member trying to escape the extraction directory
+	f, err := os.Create(filepath.Join(path, "movies.zip"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	zw := zip.NewWriter(f)
+	for name, content := range map[string]string{
+		"Looper/Looper.2012.mkv": "looper",
+		"../../Brick.2005.avi":   "brick",
+	} {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(content))
+	}
+	zw.Close()
+	f.Close()
+
+	// Compressed tar archive
+	f, err = os.Create(filepath.Join(path, "series.tar.gz"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gw := gzip.NewWriter(f)
+	tw := tar.NewWriter(gw)
+	tw.WriteHeader(&tar.Header{Name: "The.Loop/", Typeflag: tar.TypeDir, Mode: 0755})
+	tw.WriteHeader(&tar.Header{Name: "The.Loop/S01E01.mkv", Size: 4, Mode: 0644})
+	tw.Write([]byte("loop"))
+	tw.Close()
+	gw.Close()
+	f.Close()
+
+	directory := &Directory{
+		Path:        path,
+		Archives:    true,
+		ExtractPath: extractPath,
+	}
+
+	c, err := directory.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	members := make(map[string]*data.ArchiveMember)
+	var files []string
+	for d := range c {
+		switch d := d.(type) {
+		case *data.File:
+			files = append(files, d.Name)
+		case *data.ArchiveMember:
+			assert.Equal(extractPath, d.ExtractPath)
+			assert.Equal(d.Archive.Name, filepath.Base(d.Archive.Path))
+			members[d.Path] = d
+		}
+	}
+
+	assert.Equal([]string{"movies.zip", "series.tar.gz"}, files)
+	assert.Len(members, 3)
+
+	// Members extracted on demand inside the extraction directory
+	for memberPath, expected := range map[string]string{
+		"Looper/Looper.2012.mkv": filepath.Join(extractPath, "movies", "Looper", "Looper.2012.mkv"),
+		"../../Brick.2005.avi":   filepath.Join(extractPath, "movies", "Brick.2005.avi"),
+		"The.Loop/S01E01.mkv":    filepath.Join(extractPath, "series", "The.Loop", "S01E01.mkv"),
+	} {
+		member := members[memberPath]
+		if assert.NotNil(member, memberPath) == false {
+			continue
+		}
+
+		extracted, err := member.Extract()
+		assert.Nil(err, memberPath)
+		assert.True(extracted, memberPath)
+
+		if assert.NotNil(member.File, memberPath) {
+			assert.Equal(expected, member.File.Path)
+			assert.Equal(map[string]string{member.Name: expected}, member.GetContents())
+		}
+
+		// Already extracted
+		extracted, err = member.Extract()
+		assert.Nil(err, memberPath)
+		assert.False(extracted, memberPath)
+	}
+
+	content, err := ioutil.ReadFile(members["The.Loop/S01E01.mkv"].File.Path)
+	assert.Nil(err)
+	assert.Equal("loop", string(content))
+}
+
+func TestHashId(t *testing.T) {
+
+	assert := assert.New(t)
+
+	path := newTestDirectory(t)
+	defer os.RemoveAll(path)
+
+	for name, content := range map[string]string{
+		"a": "looper",
+		"b": "the loop",
+		"c": "looper",
+	} {
+		assert.Nil(os.Mkdir(filepath.Join(path, name), 0755))
+		assert.Nil(ioutil.WriteFile(filepath.Join(path, name, "Looper.mkv"), []byte(content), 0644))
+	}
+
+	directory := &Directory{
+		Path:        path,
+		IsRecursive: true,
+		Hash:        true,
+	}
+
+	c, err := directory.Start()
+	if assert.Nil(err) == false {
+		return
+	}
+
+	// Files identified by their name & their content
+	ids := make(map[string]uint64)
+	for d := range c {
+		if f, ok := d.(*data.File); assert.True(ok) {
+			ids[filepath.Base(filepath.Dir(f.Path))] = data.GetId(f)
+		}
+	}
+
+	assert.NotEqual(ids["a"], ids["b"])
+	assert.Equal(ids["a"], ids["c"])
+
+	// Deletion with the same id than the file removed
+	assert.Nil(os.RemoveAll(filepath.Join(path, "b")))
+
+	c, err = directory.Start()
+	if assert.Nil(err) == false {
+		return
+	}
+
+	if deletion, ok := receive(t, c).(*data.Deletion); assert.True(ok) {
+		assert.Equal(ids["b"], data.GetId(deletion))
+	}
+
+	for range c {
+	}
+}
+
+func TestEq(t *testing.T) {
+
+	assert := assert.New(t)
+
+	directory := &Directory{Path: "/movies", Archives: true, ExtractPath: "/tmp"}
+	assert.True(directory.Eq(&Directory{Path: "/movies", Archives: true, ExtractPath: "/tmp"}))
+
+	for _, other := range []*Directory{
+		{Path: "/series", Archives: true, ExtractPath: "/tmp"},
+		{Path: "/movies", ExtractPath: "/tmp"},
+		{Path: "/movies", Archives: true, ExtractPath: "/var/tmp"},
+		{Path: "/movies", Archives: true},
+	} {
+		assert.False(directory.Eq(other), "%+v", other)
+	}
+}
+
+func writeZip(t *testing.T, path string, names ...string) {
+
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	zw := zip.NewWriter(f)
+	for _, name := range names {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(name))
+	}
+
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestArchiveMembersId(t *testing.T) {
+
+	assert := assert.New(t)
+
+	path := newTestDirectory(t)
+	defer os.RemoveAll(path)
+
+	writeZip(t, filepath.Join(path, "a.zip"), "CD1/track01.mp3", "a/readme.txt", "b/readme.txt")
+	writeZip(t, filepath.Join(path, "b.zip"), "CD1/track01.mp3")
+
+	directory := &Directory{Path: path, Archives: true}
+
+	c, err := directory.Start()
+	if assert.Nil(err) == false {
+		return
+	}
+
+	// Members with the same name identified by their archive & path
+	ids := make(map[string]uint64)
+	for d := range c {
+		if member, ok := d.(*data.ArchiveMember); ok {
+			ids[member.Archive.Name+":"+member.Path] = data.GetId(member)
+		}
+	}
+
+	assert.Len(ids, 4)
+	assert.NotEqual(ids["a.zip:CD1/track01.mp3"], ids["b.zip:CD1/track01.mp3"])
+	assert.NotEqual(ids["a.zip:a/readme.txt"], ids["a.zip:b/readme.txt"])
+
+	// Archive removed: its members are deleted too
+	assert.Nil(os.Remove(filepath.Join(path, "a.zip")))
+
+	c, err = directory.Start()
+	if assert.Nil(err) == false {
+		return
+	}
+
+	deleted := make(map[uint64]bool)
+	for d := range c {
+		if deletion, ok := d.(*data.Deletion); assert.True(ok) {
+			deleted[data.GetId(deletion)] = true
+		}
+	}
+
+	assert.Len(deleted, 4)
+	for key, id := range ids {
+		assert.Equal(key[:1] == "a", deleted[id], key)
+	}
 }
@@ -0,0 +1,164 @@
+package imap
+
+import (
+	"encoding/json"
+	"log"
+
+	"github.com/emersion/go-imap"
+	"github.com/emersion/go-imap/client"
+)
+
+// Last message imported from a mailbox: the UIDs are only valid with the
+// same UIDVALIDITY
+type Cursor struct {
+	UidValidity uint32 `json:"uidValidity"`
+	LastUid     uint32 `json:"lastUid"`
+}
+
+// GetState returns the last messages imported by mailbox
+func (i *Imap) GetState() (json.RawMessage, error) {
+
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+
+	return json.Marshal(i.cursors)
+}
+
+// SetState set the last messages imported by mailbox
+func (i *Imap) SetState(src json.RawMessage) error {
+
+	var cursors map[string]*Cursor
+	if err := json.Unmarshal(src, &cursors); err != nil {
+		return err
+	}
+
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+
+	i.cursors = cursors
+	return nil
+}
+
+// Returns the mailbox cursor
+func (i *Imap) getCursor() *Cursor {
+
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+
+	if i.cursors == nil {
+		i.cursors = make(map[string]*Cursor)
+	}
+
+	cursor, ok := i.cursors[i.MailBox]
+	if ok == false {
+		cursor = &Cursor{}
+		i.cursors[i.MailBox] = cursor
+	}
+
+	return cursor
+}
+
+func (i *Imap) setLastUid(cursor *Cursor, uid uint32) {
+
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+
+	if uid > cursor.LastUid {
+		cursor.LastUid = uid
+	}
+}
+
+// Select the mailbox: all the messages are imported again when its
+// UIDVALIDITY has changed
+func (i *Imap) selectMailbox(cnx *client.Client) error {
+
+	mailbox, err := cnx.Select(i.MailBox, true)
+	if err != nil {
+		return err
+	}
+
+	cursor := i.getCursor()
+
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+
+	if cursor.UidValidity != mailbox.UidValidity {
+
+		if cursor.UidValidity != 0 {
+			log.Printf("import 'imap' %s: UIDVALIDITY changed, full resync\n",
+				i.MailBox)
+		}
+
+		cursor.UidValidity = mailbox.UidValidity
+		cursor.LastUid = 0
+	}
+
+	return nil
+}
+
+// Fetch the messages received since the last one imported
+func (i *Imap) fetchNew(cnx *client.Client, stop chan struct{}) error {
+
+	cursor := i.getCursor()
+
+	i.mutex.Lock()
+	lastUid := cursor.LastUid
+	i.mutex.Unlock()
+
+	criteria := imap.NewSearchCriteria()
+	if i.Request == SEARCH {
+		criteria = i.getCriteria()
+	}
+
+	if lastUid > 0 {
+		criteria.Uid = new(imap.SeqSet)
+		criteria.Uid.AddRange(lastUid+1, 0)
+	}
+
+	uids, err := cnx.UidSearch(criteria)
+	if err != nil {
+		return err
+	}
+
+	// The last message is always returned with the '*' range
+	seqset := new(imap.SeqSet)
+	for _, uid := range uids {
+		if uid > lastUid {
+			seqset.AddNum(uid)
+		}
+	}
+
+	if seqset.Empty() {
+		return nil
+	}
+
+	done := make(chan error, 1)
+	messages := make(chan *imap.Message, 1)
+	section := &imap.BodySectionName{}
+	items := []imap.FetchItem{imap.FetchUid, section.FetchItem()}
+
+	go func() {
+		done <- cnx.UidFetch(seqset, items, messages)
+	}()
+
+	for msg := range messages {
+
+		if err := i.onMessage(msg, section, stop); err == errStopped {
+
+			// Ignore the messages remaining
+			go func() {
+				for range messages {
+				}
+			}()
+
+			return nil
+
+		} else if err != nil {
+			log.Printf("import 'imap' message %d: %s\n", msg.Uid, err.Error())
+		}
+
+		i.setLastUid(cursor, msg.Uid)
+	}
+
+	return <-done
+}
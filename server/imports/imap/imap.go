@@ -5,18 +5,11 @@ import (
 	"fmt"
 	"github.com/emersion/go-imap"
 	"github.com/emersion/go-imap/client"
-	// "github.com/jhillyerd/go.enmime"
 	"github.com/ohohleo/classify/data"
 	"github.com/ohohleo/classify/imports"
-	"io"
-	"io/ioutil"
+	"github.com/ohohleo/classify/secrets"
 	"log"
-	"mime"
-	"mime/multipart"
-	"mime/quotedprintable"
-	"net/mail"
-	"regexp"
-	"strings"
+	"sync"
 	"time"
 )
 
@@ -27,6 +20,23 @@ const (
 
 type Request int
 
+// Events sent while watching the mailbox
+const (
+	EVENT_CONNECT    = "connect"
+	EVENT_DISCONNECT = "disconnect"
+)
+
+// Interval between the mailbox searches when the server doesn't handle
+// IDLE & between the reconnection attempts
+const DEFAULT_POLL_INTERVAL = "1m"
+
+// Connection established with the IMAP server
+var dial = func(address string) (*client.Client, error) {
+	return client.DialTLS(address, nil)
+}
+
+var errStopped = fmt.Errorf("import 'imap' stopped")
+
 type ImapConfig struct {
 	Store struct {
 		Path string `json:"path"`
@@ -47,16 +57,33 @@ type Imap struct {
 	Host     string `json:"host"`
 	Port     int    `json:"port"`
 	Login    string `json:"login"`
-	Password string `json:"password"`
+	Password string `json:"password" secret:"true"`
 
 	Request      Request `json:"request"`
 	MailBox      string  `json:"mailbox"`
 	OnlyAttached bool    `json:"onlyAttached"`
 	Search       Search  `json:"search"`
 
+	// Keep importing the new messages as they arrive
+	Watch        bool   `json:"watch"`
+	PollInterval string `json:"pollInterval"`
+
 	dataChannel chan data.Data
 	cnx         *client.Client
 	config      *ImapConfig
+	onEvent     func(string, interface{})
+
+	// Last messages imported by mailbox
+	cursors map[string]*Cursor
+
+	stop  chan struct{}
+	mutex sync.Mutex
+}
+
+// Connection status sent while watching the mailbox
+type Event struct {
+	Address string `json:"address"`
+	Error   string `json:"error,omitempty"`
 }
 
 type Search struct {
@@ -126,6 +153,11 @@ func Create(input json.RawMessage, config json.RawMessage,
 	case ALL:
 	}
 
+	if _, err = imap.getPollInterval(); err != nil {
+		err = fmt.Errorf("import 'imap' invalid poll interval: %s", err.Error())
+		return
+	}
+
 	if imap.config == nil {
 		imap.config = DefaultConfig()
 	}
@@ -154,35 +186,56 @@ func (i *Imap) GetParam(string, json.RawMessage) (result interface{}, err error)
 	return
 }
 
+func (i *Imap) SetEventHandler(onEvent func(string, interface{})) {
+	i.onEvent = onEvent
+}
+
 func (i *Imap) Start() (c chan data.Data, err error) {
 
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+
 	// Check if the analysis is not already going on
-	if i.cnx != nil {
+	if i.cnx != nil || i.stop != nil {
 		err = fmt.Errorf("import 'imap' already started")
 		return
 	}
 
 	// Establish connection
-	if err = i.Connect(); err != nil {
+	cnx, err := i.connect()
+	if err != nil {
 		return
 	}
 
-	switch i.Request {
-	case SEARCH:
-		go i.GetSearch()
-	case ALL:
-		go i.GetAllMessages()
-	}
-
 	c = make(chan data.Data)
 
 	i.dataChannel = c
 
+	if i.Watch {
+		i.stop = make(chan struct{})
+		go i.watch(cnx, i.stop)
+		return
+	}
+
+	i.cnx = cnx
+
+	go i.GetNewMessages()
+
 	return
 }
 
 func (i *Imap) Stop() error {
 
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+
+	// The connection is closed by the watching routine
+	if i.stop != nil {
+		close(i.stop)
+		i.stop = nil
+		return nil
+	}
+
 	// No need to close unitialised connection
 	if i.cnx == nil {
 		return fmt.Errorf("import 'imap' already stopped")
@@ -202,27 +255,49 @@ func (i *Imap) Eq(new imports.Import) bool {
 
 func (i *Imap) Connect() error {
 
-	address := fmt.Sprintf("%s:%d", i.Host, i.Port)
+	cnx, err := i.connect()
+	if err != nil {
+		return err
+	}
+
+	// Store connection
+	i.cnx = cnx
+
+	return nil
+}
+
+func (i *Imap) getAddress() string {
+	return fmt.Sprintf("%s:%d", i.Host, i.Port)
+}
+
+func (i *Imap) connect() (*client.Client, error) {
+
+	address := i.getAddress()
 
 	log.Printf("Connecting to '%s'...\n", address)
 
 	// Connect to IMAP server
-	cnx, err := client.DialTLS(address, nil)
+	cnx, err := dial(address)
 	if err != nil {
-		return fmt.Errorf("import 'imap' connection: %s", err.Error())
+		return nil, fmt.Errorf("import 'imap' connection: %s", err.Error())
+	}
+
+	// Password stored in the secrets
+	password, err := secrets.Resolve(i.Password)
+	if err != nil {
+		cnx.Logout()
+		return nil, fmt.Errorf("import 'imap' password: %s", err.Error())
 	}
 
 	// Login
-	if err := cnx.Login(i.Login, i.Password); err != nil {
-		return fmt.Errorf("import 'imap' login: %s", err.Error())
+	if err := cnx.Login(i.Login, password); err != nil {
+		cnx.Logout()
+		return nil, fmt.Errorf("import 'imap' login: %s", err.Error())
 	}
 
 	log.Printf("'%s' Connected!\n", address)
 
-	// Store connection
-	i.cnx = cnx
-
-	return nil
+	return cnx, nil
 }
 
 // Permet la gestion des commandes asynchrones
@@ -253,167 +328,80 @@ func (i *Imap) GetMailBoxes() (mailboxes []string, err error) {
 	return
 }
 
-func (i *Imap) GetAllMessages() error {
+// Import the messages received since the last import
+func (i *Imap) GetNewMessages() error {
 
-	mailbox, err := i.cnx.Select(i.MailBox, false)
-	if err != nil {
-		return err
+	err := i.selectMailbox(i.cnx)
+	if err == nil {
+		err = i.fetchNew(i.cnx, nil)
 	}
 
-	// Get all messages
-	from := uint32(1)
-	to := mailbox.Messages
-
-	seqset := new(imap.SeqSet)
-	seqset.AddRange(from, to)
-
-	return i.Proceed(seqset)
-}
-
-func (i *Imap) GetSearch() error {
-
-	_, err := i.cnx.Select(i.MailBox, false)
 	if err != nil {
-		i.Stop()
-		return err
+		log.Printf("import 'imap' %s: %s\n", i.MailBox, err.Error())
 	}
 
-	criteria := &imap.SearchCriteria{
+	// Connection closed before the end of the import
+	i.Stop()
+	close(i.dataChannel)
+	return err
+}
+
+func (i *Imap) getCriteria() *imap.SearchCriteria {
+	return &imap.SearchCriteria{
 		Since:   i.Search.Since,
 		Before:  i.Search.Before,
 		Larger:  i.Search.Larger,
 		Smaller: i.Search.Smaller,
 		Text:    i.Search.Text,
 	}
-
-	// Launch research
-	seqNums, err := i.cnx.Search(criteria)
-	if err != nil {
-		fmt.Printf("ERROR: %+v\n", err)
-		i.Stop()
-		return err
-	}
-
-	seqset := new(imap.SeqSet)
-	seqset.AddNum(seqNums...)
-
-	return i.Proceed(seqset)
 }
 
-func (i *Imap) Proceed(seqset *imap.SeqSet) error {
-
-	done := make(chan error)
-	messages := make(chan *imap.Message, 1)
-	section := &imap.BodySectionName{}
-	items := []imap.FetchItem{section.FetchItem()}
+// Send the message & its attachments
+func (i *Imap) onMessage(msg *imap.Message, section *imap.BodySectionName, stop chan struct{}) error {
 
-	go func() {
-		done <- i.cnx.Fetch(seqset, items, messages)
-	}()
+	rsp := msg.GetBody(section)
+	if rsp == nil {
+		fmt.Println("Server didn't returned message body")
+		return nil
+	}
 
-	if err := <-done; err != nil {
-		i.Stop()
+	email, err := ReadEmail(rsp)
+	if err != nil {
 		return err
 	}
 
-	for msg := range messages {
+	return SendEmail(email, i.config.Store.Path, func(d data.Data) error {
+		return i.send(d, stop)
+	})
+}
 
-		rsp := msg.GetBody(section)
-		if rsp == nil {
-			fmt.Println("Server didn't returned message body")
-			continue
-		}
+// SendEmail stores the attachments in the directory specified then sends
+// them followed by the email
+func SendEmail(email *data.Email, storePath string, send func(data.Data) error) error {
 
-		m, err := mail.ReadMessage(rsp)
-		if err != nil {
-			i.Stop()
-			return err
-		}
-
-		header := m.Header
+	for _, attachment := range email.Attachments {
 
-		date, err := header.Date()
-		if err != nil {
-			i.Stop()
-			return err
+		if err := attachment.StoreToFile(storePath); err != nil {
+			log.Printf("Issue storing %s to '%s': %s\n", attachment.Name,
+				storePath, err.Error())
 		}
 
-		email := &data.Email{
-			Subject:     header.Get("Subject"),
-			From:        header.Get("From"),
-			Date:        date,
-			ContentType: header.Get("Content-Type"),
-		}
+		// Content kept only in the file stored
+		attachment.Content = nil
 
-		mediaType, params, err := mime.ParseMediaType(
-			m.Header.Get("Content-Type"))
-		if err != nil {
-			i.Stop()
+		if err := send(attachment); err != nil {
 			return err
 		}
-
-		if strings.HasPrefix(mediaType, "multipart/") {
-
-			mr := multipart.NewReader(m.Body, params["boundary"])
-
-			email.Attachments = make([]*data.Attachment, 0)
-
-			idx := 0
-			for {
-				p, err := mr.NextPart()
-				if err == io.EOF {
-					break
-				}
-				if err != nil {
-					i.Stop()
-					return err
-				}
-
-				name := Convert2Utf8(p.FileName())
-				if name == "" {
-					name = fmt.Sprintf("Part%d", idx)
-				}
-
-				attachment := &data.Attachment{
-					Part:               p,
-					Name:               name,
-					ContentDisposition: p.FormName(),
-					Date:               date,
-				}
-
-				if err := attachment.StoreToFile(i.config.Store.Path); err != nil {
-					log.Printf("Issue storing %s to '%s': %s\n", name,
-						i.config.Store.Path, err.Error())
-				}
-
-				email.Attachments = append(email.Attachments, attachment)
-				i.dataChannel <- attachment
-				idx++
-			}
-		}
-
-		i.dataChannel <- email
 	}
 
-	close(i.dataChannel)
-	i.Stop()
-	return nil
+	return send(email)
 }
 
-var REMOVE_ISO = regexp.MustCompile(`=\?([a-zA-Z0-9-_]+)\?[a-zA-Z]\?([^\?]+)\?=`)
-
-func Convert2Utf8(name string) string {
-
-	submatches := REMOVE_ISO.FindAllStringSubmatch(name, -1)
-	if len(submatches) == 1 && len(submatches[0]) == 3 {
-		name = submatches[0][2]
-	}
-
-	converted, err := ioutil.ReadAll(quotedprintable.NewReader(
-		strings.NewReader(name)))
-	if err == nil {
-		name = string(converted)
+func (i *Imap) send(d data.Data, stop chan struct{}) error {
+	select {
+	case i.dataChannel <- d:
+		return nil
+	case <-stop:
+		return errStopped
 	}
-
-	return name
 }
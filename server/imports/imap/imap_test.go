@@ -0,0 +1,417 @@
+package imap
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/emersion/go-imap"
+	"github.com/emersion/go-imap-idle"
+	"github.com/emersion/go-imap/backend"
+	"github.com/emersion/go-imap/backend/memory"
+	"github.com/emersion/go-imap/client"
+	"github.com/emersion/go-imap/server"
+	"github.com/ohohleo/classify/data"
+	"github.com/stretchr/testify/assert"
+)
+
+// In-memory backend notifying the new messages: the mailboxes are
+// shared by several connections so their accesses are serialized
+type testBackend struct {
+	*memory.Backend
+	updates chan backend.Update
+	address string
+	mutex   sync.Mutex
+}
+
+func (b *testBackend) Updates() <-chan backend.Update {
+	return b.updates
+}
+
+func (b *testBackend) Login(connInfo *imap.ConnInfo, username, password string) (backend.User, error) {
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	user, err := b.Backend.Login(connInfo, username, password)
+	if err != nil {
+		return nil, err
+	}
+
+	return &testUser{User: user, mutex: &b.mutex}, nil
+}
+
+type testUser struct {
+	backend.User
+	mutex *sync.Mutex
+}
+
+func (u *testUser) ListMailboxes(subscribed bool) ([]backend.Mailbox, error) {
+
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+
+	mailboxes, err := u.User.ListMailboxes(subscribed)
+	for idx, mailbox := range mailboxes {
+		mailboxes[idx] = &testMailbox{Mailbox: mailbox, mutex: u.mutex}
+	}
+
+	return mailboxes, err
+}
+
+func (u *testUser) GetMailbox(name string) (backend.Mailbox, error) {
+
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+
+	mailbox, err := u.User.GetMailbox(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return &testMailbox{Mailbox: mailbox, mutex: u.mutex}, nil
+}
+
+type testMailbox struct {
+	backend.Mailbox
+	mutex *sync.Mutex
+}
+
+func (m *testMailbox) Info() (*imap.MailboxInfo, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return m.Mailbox.Info()
+}
+
+func (m *testMailbox) Status(items []imap.StatusItem) (*imap.MailboxStatus, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return m.Mailbox.Status(items)
+}
+
+func (m *testMailbox) ListMessages(uid bool, seqset *imap.SeqSet, items []imap.FetchItem, ch chan<- *imap.Message) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return m.Mailbox.ListMessages(uid, seqset, items, ch)
+}
+
+func (m *testMailbox) SearchMessages(uid bool, criteria *imap.SearchCriteria) ([]uint32, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return m.Mailbox.SearchMessages(uid, criteria)
+}
+
+func (m *testMailbox) CreateMessage(flags []string, date time.Time, body imap.Literal) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return m.Mailbox.CreateMessage(flags, date, body)
+}
+
+func (m *testMailbox) UpdateMessagesFlags(uid bool, seqset *imap.SeqSet, operation imap.FlagsOp, flags []string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return m.Mailbox.UpdateMessagesFlags(uid, seqset, operation, flags)
+}
+
+func (m *testMailbox) Expunge() error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return m.Mailbox.Expunge()
+}
+
+// Append the message through another connection: the backend is only
+// accessed by the server
+func (b *testBackend) addMessage(t *testing.T, subject string) {
+
+	c, err := client.Dial(b.address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Logout()
+
+	if err = c.Login("username", "password"); err != nil {
+		t.Fatal(err)
+	}
+
+	body := "From: contact@example.org\r\n" +
+		"Subject: " + subject + "\r\n" +
+		"Date: Fri, 28 Sep 2012 10:00:00 +0000\r\n" +
+		"Content-Type: text/plain\r\n" +
+		"\r\n" +
+		"Looper"
+
+	err = c.Append("INBOX", nil, time.Now(), bytes.NewBufferString(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	status, err := c.Status("INBOX", []imap.StatusItem{imap.StatusMessages})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b.updates <- &backend.MailboxUpdate{
+		Update:        backend.NewUpdate("username", "INBOX"),
+		MailboxStatus: status,
+	}
+}
+
+func newTestServer(t *testing.T, hasIdle bool) (*testBackend, int, func()) {
+
+	b := &testBackend{
+		Backend: memory.New(),
+		updates: make(chan backend.Update, 10),
+	}
+
+	s := server.New(b)
+	s.AllowInsecureAuth = true
+	if hasIdle {
+		s.Enable(idle.NewExtension())
+	}
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b.address = l.Addr().String()
+
+	go s.Serve(l)
+
+	return b, l.Addr().(*net.TCPAddr).Port, func() { s.Close() }
+}
+
+func receive(t *testing.T, c chan data.Data) data.Data {
+	select {
+	case d := <-c:
+		return d
+	case <-time.After(5 * time.Second):
+		t.Fatal("no data received")
+	}
+	return nil
+}
+
+func newTestImap(port int, dir string) *Imap {
+
+	i := &Imap{
+		Host:     "127.0.0.1",
+		Port:     port,
+		Login:    "username",
+		Password: "password",
+		MailBox:  "INBOX",
+		Request:  ALL,
+		config:   DefaultConfig(),
+	}
+
+	i.config.Store.Path = dir
+	return i
+}
+
+// Returns the subjects of the emails imported
+func receiveAll(c chan data.Data) (subjects []string) {
+
+	for d := range c {
+		if email, ok := d.(*data.Email); ok {
+			subjects = append(subjects, email.Subject)
+		}
+	}
+
+	return
+}
+
+func receiveEvent(t *testing.T, events chan string) string {
+	select {
+	case event := <-events:
+		return event
+	case <-time.After(5 * time.Second):
+		t.Fatal("no event received")
+	}
+	return ""
+}
+
+func TestWatch(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "imap")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// Plain connections kept to be dropped
+	conns := make(chan net.Conn, 10)
+	dial = func(address string) (*client.Client, error) {
+		conn, err := net.Dial("tcp", address)
+		if err != nil {
+			return nil, err
+		}
+		conns <- conn
+		return client.New(conn)
+	}
+
+	for _, hasIdle := range []bool{true, false} {
+
+		assert := assert.New(t)
+		name := fmt.Sprintf("idle %t", hasIdle)
+
+		b, port, closeServer := newTestServer(t, hasIdle)
+
+		i := newTestImap(port, dir)
+		i.Watch = true
+		i.PollInterval = "50ms"
+
+		events := make(chan string, 10)
+		i.SetEventHandler(func(status string, _ interface{}) {
+			events <- status
+		})
+
+		c, err := i.Start()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Equal(EVENT_CONNECT, receiveEvent(t, events), name)
+
+		// Message already in the mailbox
+		email, _ := receive(t, c).(*data.Email)
+		if assert.NotNil(email, name) {
+			assert.Equal("A little message, just for you", email.Subject, name)
+		}
+
+		// New message
+		b.addMessage(t, "Looper")
+		email, _ = receive(t, c).(*data.Email)
+		if assert.NotNil(email, name) {
+			assert.Equal("Looper", email.Subject, name)
+		}
+
+		// Connection dropped
+		(<-conns).Close()
+		assert.Equal(EVENT_DISCONNECT, receiveEvent(t, events), name)
+		assert.Equal(EVENT_CONNECT, receiveEvent(t, events), name)
+		<-conns
+
+		// Only the new messages after the reconnection
+		b.addMessage(t, "The Loop")
+		email, _ = receive(t, c).(*data.Email)
+		if assert.NotNil(email, name) {
+			assert.Equal("The Loop", email.Subject, name)
+		}
+
+		assert.Nil(i.Stop(), name)
+
+		_, ok := <-c
+		assert.False(ok, name)
+		assert.NotNil(i.Stop(), name)
+
+		closeServer()
+	}
+}
+
+func TestIncremental(t *testing.T) {
+
+	assert := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "imap")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	dial = client.Dial
+
+	b, port, closeServer := newTestServer(t, false)
+	defer closeServer()
+
+	i := newTestImap(port, dir)
+
+	start := func() []string {
+		c, err := i.Start()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return receiveAll(c)
+	}
+
+	assert.Equal([]string{"A little message, just for you"}, start())
+
+	// Cursor kept with the import state
+	state, err := i.GetState()
+	assert.Nil(err)
+
+	i = newTestImap(port, dir)
+	assert.Nil(i.SetState(state))
+	assert.Len(start(), 0)
+
+	b.addMessage(t, "Looper")
+	assert.Equal([]string{"Looper"}, start())
+
+	// Full resync with another UIDVALIDITY
+	i.cursors["INBOX"].UidValidity = 42
+	assert.Equal([]string{"A little message, just for you", "Looper"}, start())
+	assert.Equal(uint32(1), i.cursors["INBOX"].UidValidity)
+}
+
+func TestSendEmail(t *testing.T) {
+
+	assert := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "imap")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	date := time.Date(2012, 9, 28, 20, 15, 0, 0, time.UTC)
+
+	// Unnamed parts of different messages sent at the same second
+	var attachments []*data.Attachment
+	for _, content := range []string{"looper", "the loop", "looper"} {
+
+		email := &data.Email{
+			Date: date,
+			Attachments: []*data.Attachment{
+				&data.Attachment{
+					Name:    "Part0",
+					Date:    date,
+					Content: []byte(content),
+				},
+			},
+		}
+
+		err := SendEmail(email, dir, func(d data.Data) error {
+			if attachment, ok := d.(*data.Attachment); ok {
+				attachments = append(attachments, attachment)
+			}
+			return nil
+		})
+		assert.Nil(err)
+	}
+
+	if assert.Len(attachments, 3) == false {
+		return
+	}
+
+	for idx, content := range []string{"looper", "the loop", "looper"} {
+		if assert.NotNil(attachments[idx].File) {
+			stored, err := ioutil.ReadFile(attachments[idx].File.Path)
+			assert.Nil(err)
+			assert.Equal(content, string(stored))
+		}
+	}
+
+	// Same content stored once
+	assert.NotEqual(attachments[0].File.Path, attachments[1].File.Path)
+	assert.Equal(attachments[0].File.Path, attachments[2].File.Path)
+
+	assert.NotEqual(data.GetId(attachments[0]), data.GetId(attachments[1]))
+	assert.Equal(data.GetId(attachments[0]), data.GetId(attachments[2]))
+
+	files, err := ioutil.ReadDir(dir)
+	assert.Nil(err)
+	assert.Len(files, 2)
+}
@@ -0,0 +1,287 @@
+package imap
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"path/filepath"
+	"strings"
+
+	"github.com/ohohleo/classify/data"
+	"golang.org/x/text/encoding/htmlindex"
+)
+
+// Maximum depth of the multipart messages
+const MIME_DEPTH_MAX = 16
+
+// Part used when the message has no content type
+const MIME_DEFAULT_TYPE = "text/plain; charset=us-ascii"
+
+var wordDecoder = &mime.WordDecoder{
+	CharsetReader: charsetReader,
+}
+
+var addressParser = &mail.AddressParser{
+	WordDecoder: wordDecoder,
+}
+
+// Returns the reader converting the charset specified to UTF-8
+func charsetReader(charset string, input io.Reader) (io.Reader, error) {
+
+	switch strings.ToLower(charset) {
+	case "", "utf-8", "utf8", "us-ascii", "ascii":
+		return input, nil
+	}
+
+	encoding, err := htmlindex.Get(charset)
+	if err != nil {
+		return nil, fmt.Errorf("unhandled charset '%s'", charset)
+	}
+
+	return encoding.NewDecoder().Reader(input), nil
+}
+
+// ReadEmail decodes the message: the text & HTML bodies are converted to
+// UTF-8, all the other parts are kept as attachments
+func ReadEmail(r io.Reader) (email *data.Email, err error) {
+
+	m, err := mail.ReadMessage(r)
+	if err != nil {
+		return
+	}
+
+	header := m.Header
+
+	email = &data.Email{
+		Subject:     decodeHeader(header.Get("Subject")),
+		From:        decodeAddress(header.Get("From")),
+		To:          decodeAddressList(header.Get("To")),
+		CC:          decodeAddressList(header.Get("Cc")),
+		CCI:         decodeAddressList(header.Get("Bcc")),
+		ContentType: header.Get("Content-Type"),
+		MessageId:   decodeId(header.Get("Message-Id")),
+		InReplyTo:   decodeId(header.Get("In-Reply-To")),
+		Attachments: make([]*data.Attachment, 0),
+	}
+
+	// Messages without date kept
+	if date, err := header.Date(); err == nil {
+		email.Date = date
+	}
+
+	err = readPart(email, textproto.MIMEHeader(header), m.Body, 0)
+	return
+}
+
+// Decode the part & its sub-parts
+func readPart(email *data.Email, header textproto.MIMEHeader, body io.Reader, depth int) error {
+
+	contentType := header.Get("Content-Type")
+	if contentType == "" {
+		contentType = MIME_DEFAULT_TYPE
+	}
+
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		// Invalid content type kept as binary attachment
+		mediaType, params = "application/octet-stream", map[string]string{}
+	}
+
+	if strings.HasPrefix(mediaType, "multipart/") && params["boundary"] != "" {
+
+		if depth >= MIME_DEPTH_MAX {
+			return fmt.Errorf("too many nested parts")
+		}
+
+		mr := multipart.NewReader(body, params["boundary"])
+		for {
+			p, err := mr.NextPart()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+
+			if err := readPart(email, p.Header, p, depth+1); err != nil {
+				return err
+			}
+		}
+	}
+
+	content, err := ioutil.ReadAll(decodeTransfer(header, body))
+	if err != nil {
+		return err
+	}
+
+	disposition, dispositionParams, _ := mime.ParseMediaType(
+		header.Get("Content-Disposition"))
+
+	name := dispositionParams["filename"]
+	if name == "" {
+		name = params["name"]
+	}
+
+	// Text bodies
+	if disposition != "attachment" && name == "" {
+		switch mediaType {
+		case "text/plain":
+			email.Text += decodeCharset(params["charset"], content)
+			return nil
+		case "text/html":
+			email.Html += decodeCharset(params["charset"], content)
+			return nil
+		}
+	}
+
+	contentId := decodeId(header.Get("Content-Id"))
+
+	if name == "" {
+		name = fmt.Sprintf("Part%d", len(email.Attachments))
+	}
+
+	email.Attachments = append(email.Attachments, &data.Attachment{
+		Name:               cleanFileName(decodeHeader(name)),
+		Date:               email.Date,
+		ContentType:        mediaType,
+		ContentDisposition: disposition,
+		ContentId:          contentId,
+		Inline:             disposition == "inline" || (disposition == "" && contentId != ""),
+		Content:            content,
+	})
+
+	return nil
+}
+
+// Returns the reader decoding the content transfer encoding: the quoted
+// printable parts are already decoded by the multipart reader
+func decodeTransfer(header textproto.MIMEHeader, body io.Reader) io.Reader {
+
+	switch strings.ToLower(strings.TrimSpace(header.Get("Content-Transfer-Encoding"))) {
+	case "base64":
+		return base64.NewDecoder(base64.StdEncoding, &base64Cleaner{r: body})
+	case "quoted-printable":
+		return quotedprintable.NewReader(body)
+	}
+
+	return body
+}
+
+// Ignore the characters not part of the base64 alphabet (ie. spaces)
+type base64Cleaner struct {
+	r io.Reader
+}
+
+func (b *base64Cleaner) Read(p []byte) (n int, err error) {
+
+	for n == 0 && err == nil {
+
+		var read int
+		read, err = b.r.Read(p)
+
+		for _, c := range p[:read] {
+			if (c >= 'A' && c <= 'Z') || (c >= 'a' && c <= 'z') ||
+				(c >= '0' && c <= '9') || c == '+' || c == '/' || c == '=' {
+				p[n] = c
+				n++
+			}
+		}
+	}
+
+	return
+}
+
+// Returns the text converted to UTF-8, kept as is if the charset is not
+// handled
+func decodeCharset(charset string, content []byte) string {
+
+	r, err := charsetReader(charset, bytes.NewReader(content))
+	if err != nil {
+		return string(content)
+	}
+
+	decoded, err := ioutil.ReadAll(r)
+	if err != nil {
+		return string(content)
+	}
+
+	return string(decoded)
+}
+
+// Returns the header with the RFC 2047 encoded words decoded
+func decodeHeader(value string) string {
+
+	decoded, err := wordDecoder.DecodeHeader(value)
+	if err != nil {
+		return value
+	}
+
+	return decoded
+}
+
+func formatAddress(address *mail.Address) string {
+
+	if address.Name == "" {
+		return address.Address
+	}
+
+	return address.Name + " <" + address.Address + ">"
+}
+
+func decodeAddress(value string) string {
+
+	address, err := addressParser.Parse(value)
+	if err != nil {
+		return decodeHeader(value)
+	}
+
+	return formatAddress(address)
+}
+
+func decodeAddressList(value string) (res []string) {
+
+	if value == "" {
+		return
+	}
+
+	addresses, err := addressParser.ParseList(value)
+	if err != nil {
+		return []string{decodeHeader(value)}
+	}
+
+	for _, address := range addresses {
+		res = append(res, formatAddress(address))
+	}
+
+	return
+}
+
+// Returns the message or the content id without the angle brackets
+func decodeId(value string) string {
+	return strings.Trim(strings.TrimSpace(value), "<>")
+}
+
+// Keep only the file name
+func cleanFileName(name string) string {
+
+	name = strings.Map(func(r rune) rune {
+		switch r {
+		case '/', '\\', 0:
+			return '_'
+		}
+		return r
+	}, strings.TrimSpace(name))
+
+	if name == "" || name == "." || name == ".." {
+		name = "_"
+	}
+
+	return filepath.Base(name)
+}
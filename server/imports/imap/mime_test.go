@@ -0,0 +1,125 @@
+package imap
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ohohleo/classify/data"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadEmail(t *testing.T) {
+
+	assert := assert.New(t)
+
+	date := func(hour int) time.Time {
+		return time.Date(2012, 9, 28, hour, 0, 0, 0, time.UTC)
+	}
+
+	for name, expected := range map[string]*data.Email{
+		"plain.eml": &data.Email{
+			Subject:   "Café crème",
+			From:      "André <andre@example.org>",
+			To:        []string{"Bob <bob@example.org>", "carol@example.org"},
+			CC:        []string{"Élise <elise@example.org>"},
+			Date:      date(10),
+			MessageId: "plain@example.org",
+			Text:      "Un café crème, s'il vous plaît.\r\n",
+		},
+		"alternative.eml": &data.Email{
+			Subject:   "Re: Café crème",
+			From:      "Bob <bob@example.org>",
+			To:        []string{"andre@example.org"},
+			Date:      date(9),
+			MessageId: "alternative@example.org",
+			InReplyTo: "plain@example.org",
+			Text:      "See you at the cafe",
+			Html:      "<p>See you at the café</p>",
+		},
+		"related.eml": &data.Email{
+			Subject:   "Looper poster",
+			From:      "carol@example.org",
+			To:        []string{"andre@example.org"},
+			Date:      date(12),
+			MessageId: "related@example.org",
+			Text:      "The poster",
+			Html:      `<p>The poster <img src="cid:poster@example.org"></p>`,
+			Attachments: []*data.Attachment{
+				&data.Attachment{
+					Name:        "Part0",
+					ContentType: "image/png",
+					ContentId:   "poster@example.org",
+					Inline:      true,
+					Content:     []byte("\x89PNG\r\n\x1a\nlooper"),
+				},
+				&data.Attachment{
+					Name:               "résumé.pdf",
+					ContentType:        "application/pdf",
+					ContentDisposition: "attachment",
+					Content:            []byte("%PDF-1.4 resume"),
+				},
+				&data.Attachment{
+					Name:               "€ rates.txt",
+					ContentType:        "text/plain",
+					ContentDisposition: "attachment",
+					Content:            []byte("1 EUR"),
+				},
+			},
+		},
+		"charsets.eml": &data.Email{
+			Subject: "Привет",
+			From:    "andre@example.org",
+			To:      []string{"bob@example.org"},
+			Date:    date(13),
+			Text:    "Prix : 5 €raw",
+			Html:    "<b>Привет</b>",
+		},
+		"attachment.eml": &data.Email{
+			Subject: "Single part",
+			From:    "andre@example.org",
+			To:      []string{"bob@example.org"},
+			Attachments: []*data.Attachment{
+				&data.Attachment{
+					Name:               ".._.._etc_passwd",
+					ContentType:        "application/octet-stream",
+					ContentDisposition: "attachment",
+					Content:            []byte("root:x:0:0"),
+				},
+			},
+		},
+	} {
+		f, err := os.Open(filepath.Join("testdata", name))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		email, err := ReadEmail(f)
+		f.Close()
+
+		if assert.Nil(err, name) == false {
+			continue
+		}
+
+		// Compare the dates separately
+		assert.True(expected.Date.Equal(email.Date), name)
+		email.Date = expected.Date
+
+		for _, attachment := range email.Attachments {
+			assert.True(expected.Date.Equal(attachment.Date), name)
+			attachment.Date = expected.Date
+		}
+
+		for _, attachment := range expected.Attachments {
+			attachment.Date = expected.Date
+		}
+
+		if expected.Attachments == nil {
+			expected.Attachments = []*data.Attachment{}
+		}
+
+		email.ContentType = ""
+		assert.Equal(expected, email, name)
+	}
+}
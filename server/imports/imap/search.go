@@ -0,0 +1,60 @@
+package imap
+
+import (
+	"bytes"
+	"strings"
+	"time"
+
+	"github.com/ohohleo/classify/data"
+)
+
+// Match checks the message read locally against the search criteria as
+// the IMAP servers do: the dates are compared by day, the sizes in bytes
+// and the texts are searched in the headers & the bodies ignoring case
+func (s *Search) Match(email *data.Email, raw []byte) bool {
+
+	if s.Since.IsZero() == false && getDay(email.Date).Before(getDay(s.Since)) {
+		return false
+	}
+
+	if s.Before.IsZero() == false && getDay(email.Date).Before(getDay(s.Before)) == false {
+		return false
+	}
+
+	size := uint32(len(raw))
+
+	if s.Larger > 0 && size <= s.Larger {
+		return false
+	}
+
+	if s.Smaller > 0 && size >= s.Smaller {
+		return false
+	}
+
+	if len(s.Text) == 0 {
+		return true
+	}
+
+	// Texts searched in the raw message & in the fields decoded
+	content := bytes.ToLower(raw)
+	decoded := strings.ToLower(strings.Join(append([]string{
+		email.Subject, email.From, email.Text, email.Html,
+	}, append(email.To, email.CC...)...), "\n"))
+
+	for _, text := range s.Text {
+
+		text = strings.ToLower(text)
+		if bytes.Contains(content, []byte(text)) == false &&
+			strings.Contains(decoded, text) == false {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Returns the day of the date in its own location
+func getDay(date time.Time) time.Time {
+	year, month, day := date.Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
@@ -0,0 +1,175 @@
+package imap
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/emersion/go-imap-idle"
+	"github.com/emersion/go-imap/client"
+)
+
+func (i *Imap) getPollInterval() (time.Duration, error) {
+
+	interval := i.PollInterval
+	if interval == "" {
+		interval = DEFAULT_POLL_INTERVAL
+	}
+
+	duration, err := time.ParseDuration(interval)
+	if err == nil && duration <= 0 {
+		err = fmt.Errorf("'%s' should be positive", interval)
+	}
+
+	return duration, err
+}
+
+func (i *Imap) sendEvent(status string, address string, err error) {
+
+	event := &Event{
+		Address: address,
+	}
+
+	if err != nil {
+		event.Error = err.Error()
+	}
+
+	if i.onEvent != nil {
+		i.onEvent(status, event)
+	}
+}
+
+func isStopped(stop chan struct{}) bool {
+	select {
+	case <-stop:
+		return true
+	default:
+		return false
+	}
+}
+
+// Import the messages as they arrive: the connection is established
+// again when dropped until the import is stopped
+func (i *Imap) watch(cnx *client.Client, stop chan struct{}) {
+
+	defer close(i.dataChannel)
+
+	address := i.getAddress()
+	interval, _ := i.getPollInterval()
+
+	for {
+		i.sendEvent(EVENT_CONNECT, address, nil)
+
+		err := i.listen(cnx, stop, interval)
+		cnx.Logout()
+
+		if isStopped(stop) {
+			return
+		}
+
+		log.Printf("'%s' disconnected: %s\n", address, err.Error())
+		i.sendEvent(EVENT_DISCONNECT, address, err)
+
+		// Reconnect after each interval
+		for cnx = nil; cnx == nil; {
+
+			select {
+			case <-stop:
+				return
+			case <-time.After(interval):
+			}
+
+			if cnx, err = i.connect(); err != nil {
+				log.Printf("'%s' reconnection: %s\n", address, err.Error())
+			}
+		}
+	}
+}
+
+// Fetch the new messages each time the server notifies the mailbox
+// changes while idling, or at each interval if IDLE is not handled
+func (i *Imap) listen(cnx *client.Client, stop chan struct{}, interval time.Duration) error {
+
+	// Keep only the mailbox updates
+	updates := make(chan client.Update, 16)
+	updated := make(chan struct{}, 1)
+	cnx.Updates = updates
+
+	go func() {
+		for {
+			select {
+			case update := <-updates:
+				if _, ok := update.(*client.MailboxUpdate); ok {
+					select {
+					case updated <- struct{}{}:
+					default:
+					}
+				}
+			case <-cnx.LoggedOut():
+				return
+			}
+		}
+	}()
+
+	if err := i.selectMailbox(cnx); err != nil {
+		return err
+	}
+
+	idleClient := idle.NewClient(cnx)
+	hasIdle, err := idleClient.SupportIdle()
+	if err != nil {
+		return err
+	}
+
+	for isStopped(stop) == false {
+
+		if err := i.fetchNew(cnx, stop); err != nil {
+			return err
+		}
+
+		if hasIdle {
+			err = waitIdle(idleClient, stop, updated)
+		} else {
+			err = waitPoll(cnx, stop, interval)
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Idle until the mailbox is updated
+func waitIdle(c *idle.Client, stop chan struct{}, updated chan struct{}) error {
+
+	idleStop := make(chan struct{})
+	done := make(chan error, 1)
+
+	go func() {
+		done <- c.Idle(idleStop)
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-updated:
+	case <-stop:
+	}
+
+	close(idleStop)
+	return <-done
+}
+
+func waitPoll(cnx *client.Client, stop chan struct{}, interval time.Duration) error {
+
+	select {
+	case <-time.After(interval):
+	case <-stop:
+	case <-cnx.LoggedOut():
+		return fmt.Errorf("connection closed")
+	}
+
+	return nil
+}
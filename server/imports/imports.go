@@ -8,6 +8,11 @@ import (
 const (
 	IMAP Ref = iota
 	DIRECTORY
+	RSS
+	LIST
+	WEBHOOK
+	MBOX
+	MAILDIR
 )
 
 type Ref uint64
@@ -19,11 +24,21 @@ func (t Ref) String() string {
 var REF_IDX2STR = []string{
 	"imap",
 	"directory",
+	"rss",
+	"list",
+	"webhook",
+	"mbox",
+	"maildir",
 }
 
 var REF_STR2IDX = map[string]Ref{
 	REF_IDX2STR[IMAP]:      IMAP,
 	REF_IDX2STR[DIRECTORY]: DIRECTORY,
+	REF_IDX2STR[RSS]:       RSS,
+	REF_IDX2STR[LIST]:      LIST,
+	REF_IDX2STR[WEBHOOK]:   WEBHOOK,
+	REF_IDX2STR[MBOX]:      MBOX,
+	REF_IDX2STR[MAILDIR]:   MAILDIR,
 }
 
 type Import interface {
@@ -35,6 +50,24 @@ type Import interface {
 	Eq(Import) bool
 }
 
+// Optional state kept between the imports (ie. files already imported)
+type HasState interface {
+	GetState() (json.RawMessage, error)
+	SetState(json.RawMessage) error
+}
+
+// Optional events sent while the import is running (ie. connection
+// established or lost)
+type HasEvents interface {
+	SetEventHandler(func(status string, data interface{}))
+}
+
+// Optional data pushed to the import (ie. webhook): returns the number
+// of data sent
+type HasPush interface {
+	Push(body []byte, signature string) (int, error)
+}
+
 type Build struct {
 	CheckConfig func(json.RawMessage) error
 	ForceCreate func() Import
@@ -0,0 +1,427 @@
+package list
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/ohohleo/classify/data"
+	"github.com/ohohleo/classify/imports"
+	"github.com/ohohleo/classify/params"
+)
+
+// Formats of the lists
+const (
+	CSV    = "csv"
+	JSON   = "json"
+	NDJSON = "ndjson"
+)
+
+// Formats deduced from the file extension
+var extensions = map[string]string{
+	".csv":    CSV,
+	".tsv":    CSV,
+	".json":   JSON,
+	".ndjson": NDJSON,
+	".jsonl":  NDJSON,
+}
+
+type List struct {
+	Path string `json:"path"`
+
+	// Format deduced from the extension if not specified
+	Format string `json:"format"`
+
+	// Data created for each row: 'movie' by default or 'generic'
+	Ref string `json:"ref"`
+
+	// Column of each data field (ie. "name": "Title"), the usual
+	// columns are used for the fields not specified
+	Mapping map[string]string `json:"mapping"`
+
+	// CSV separator, ',' by default (tabulation for the .tsv files)
+	Separator string `json:"separator"`
+
+	isRunning bool
+	stop      chan struct{}
+	mutex     sync.Mutex
+}
+
+func ToBuild() imports.Build {
+	return imports.Build{
+		ForceCreate: ForceCreate,
+		Create:      Create,
+	}
+}
+
+func ForceCreate() (i imports.Import) {
+	return new(List)
+}
+
+func Create(input json.RawMessage, config json.RawMessage,
+	collections []string) (i imports.Import, params interface{}, err error) {
+
+	var list List
+	if err = json.Unmarshal(input, &list); err != nil {
+		return
+	}
+
+	if list.Path == "" {
+		err = fmt.Errorf("import 'list' path required")
+		return
+	}
+
+	if _, err = list.getFormat(); err != nil {
+		return
+	}
+
+	if _, err = list.getSeparator(); err != nil {
+		return
+	}
+
+	// Check the mapping
+	d, err := list.newData()
+	if err != nil {
+		return
+	}
+
+	if _, err = getFields(d, list.Mapping, nil); err != nil {
+		err = fmt.Errorf("import 'list' %s", err.Error())
+		return
+	}
+
+	i = &list
+	return
+}
+
+func (*List) GetParams() []params.Param {
+	return []params.Param{new(params.Path)}
+}
+
+func (l *List) GetRef() imports.Ref {
+	return imports.LIST
+}
+
+func (l *List) GetDatasReferences() []data.Data {
+	return []data.Data{
+		new(data.Movie),
+		new(data.Generic),
+	}
+}
+
+func (l *List) CheckConfig(config json.RawMessage) error {
+	return nil
+}
+
+func (l *List) getFormat() (string, error) {
+
+	format := strings.ToLower(l.Format)
+	if format == "" {
+		format = extensions[strings.ToLower(filepath.Ext(l.Path))]
+	}
+
+	switch format {
+	case CSV, JSON, NDJSON:
+		return format, nil
+	case "":
+		return "", fmt.Errorf("import 'list' format required for '%s'", l.Path)
+	}
+
+	return "", fmt.Errorf("import 'list' format '%s' not handled", l.Format)
+}
+
+func (l *List) getSeparator() (rune, error) {
+
+	switch {
+	case l.Separator != "":
+		if utf8.RuneCountInString(l.Separator) != 1 {
+			return 0, fmt.Errorf("import 'list' invalid separator '%s'", l.Separator)
+		}
+		separator, _ := utf8.DecodeRuneInString(l.Separator)
+		return separator, nil
+
+	case strings.ToLower(filepath.Ext(l.Path)) == ".tsv":
+		return '\t', nil
+	}
+
+	return ',', nil
+}
+
+// Returns an empty data of the type produced
+func (l *List) newData() (data.Data, error) {
+
+	ref := l.Ref
+	if ref == "" {
+		ref = data.MOVIE.String()
+	}
+
+	switch ref {
+	case data.MOVIE.String():
+		return new(data.Movie), nil
+	case data.GENERIC.String():
+		return new(data.Generic), nil
+	}
+
+	return nil, fmt.Errorf("import 'list' data '%s' not handled", l.Ref)
+}
+
+// Return a channel of the data read from each row
+func (l *List) Start() (chan data.Data, error) {
+
+	c := make(chan data.Data)
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	// Check if the analysis is not already going on
+	if l.isRunning {
+		return c, fmt.Errorf("import 'list' already started")
+	}
+
+	format, err := l.getFormat()
+	if err != nil {
+		return c, err
+	}
+
+	file, err := os.Open(l.Path)
+	if err != nil {
+		return c, err
+	}
+
+	l.isRunning = true
+
+	stop := make(chan struct{})
+	l.stop = stop
+
+	go func() {
+
+		var err error
+		if format == CSV {
+			err = l.readCSV(file, c, stop)
+		} else {
+			err = l.readJSON(file, c, stop)
+		}
+
+		if err != nil {
+			log.Printf("import 'list' '%s' %s\n", l.Path, err.Error())
+		}
+
+		file.Close()
+		close(c)
+
+		// Analysis is over
+		l.mutex.Lock()
+		l.isRunning = false
+		l.mutex.Unlock()
+	}()
+
+	return c, nil
+}
+
+func (l *List) Stop() error {
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if l.stop != nil {
+		close(l.stop)
+		l.stop = nil
+	}
+
+	return nil
+}
+
+func (l *List) Eq(new imports.Import) bool {
+	newList, _ := new.(*List)
+	return l.Path == newList.Path && l.Ref == newList.Ref
+}
+
+// Read the CSV rows: the first one holds the columns names
+func (l *List) readCSV(r io.Reader, c chan data.Data, stop chan struct{}) error {
+
+	reader := csv.NewReader(bufio.NewReader(r))
+	reader.Comma, _ = l.getSeparator()
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+
+	columns, err := reader.Read()
+	if err != nil {
+		return err
+	}
+
+	// Remove the UTF-8 byte order mark
+	if len(columns) > 0 {
+		columns[0] = strings.TrimPrefix(columns[0], "\ufeff")
+	}
+
+	for _, column := range l.Mapping {
+		if indexOf(columns, column) < 0 {
+			return fmt.Errorf("column '%s' not found", column)
+		}
+	}
+
+	d, err := l.newData()
+	if err != nil {
+		return err
+	}
+
+	fields, err := getFields(d, l.Mapping, columns)
+	if err != nil {
+		return err
+	}
+
+	for line := 2; ; line++ {
+
+		record, err := reader.Read()
+		if err == io.EOF {
+			return nil
+		}
+
+		if err != nil {
+			log.Printf("import 'list' '%s' line %d: %s\n", l.Path, line, err.Error())
+			continue
+		}
+
+		row := make(map[string]string, len(columns))
+		for idx, column := range columns {
+			if idx < len(record) {
+				row[column] = record[idx]
+			}
+		}
+
+		if l.send(row, fields, c, stop, line) == false {
+			return nil
+		}
+	}
+}
+
+// Read the objects of the JSON array or the NDJSON lines
+func (l *List) readJSON(r io.Reader, c chan data.Data, stop chan struct{}) error {
+
+	reader := bufio.NewReader(r)
+
+	// Check if the objects are in an array
+	isArray := false
+	for {
+		b, err := reader.ReadByte()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if strings.IndexByte(" \t\r\n", b) < 0 {
+			isArray = b == '['
+			reader.UnreadByte()
+			break
+		}
+	}
+
+	decoder := json.NewDecoder(reader)
+	decoder.UseNumber()
+
+	if isArray {
+		if _, err := decoder.Token(); err != nil {
+			return err
+		}
+	}
+
+	for idx := 1; isArray == false || decoder.More(); idx++ {
+
+		var object map[string]interface{}
+		if err := decoder.Decode(&object); err != nil {
+			if err == io.EOF && isArray == false {
+				return nil
+			}
+			return fmt.Errorf("object %d: %s", idx, err.Error())
+		}
+
+		row := make(map[string]string, len(object))
+		columns := make([]string, 0, len(object))
+		for column, value := range object {
+			row[column] = toString(value)
+			columns = append(columns, column)
+		}
+
+		d, err := l.newData()
+		if err != nil {
+			return err
+		}
+
+		fields, err := getFields(d, l.Mapping, columns)
+		if err != nil {
+			return err
+		}
+
+		if l.send(row, fields, c, stop, idx) == false {
+			return nil
+		}
+	}
+
+	return nil
+}
+
+// Returns the JSON value as text: the arrays are separated by commas
+func toString(value interface{}) string {
+
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	case bool:
+		return strconv.FormatBool(v)
+	case []interface{}:
+		values := make([]string, 0, len(v))
+		for _, item := range v {
+			values = append(values, toString(item))
+		}
+		return strings.Join(values, ",")
+	}
+
+	src, _ := json.Marshal(value)
+	return string(src)
+}
+
+// Send the data of the row: returns false if the import is stopped
+func (l *List) send(row map[string]string, fields []field, c chan data.Data, stop chan struct{}, line int) bool {
+
+	d, _ := l.newData()
+	if err := setFields(d, fields, row); err != nil {
+		log.Printf("import 'list' '%s' row %d: %s\n", l.Path, line, err.Error())
+		return true
+	}
+
+	// Rows without name ignored
+	if d.GetName() == "" {
+		return true
+	}
+
+	select {
+	case c <- d:
+		return true
+	case <-stop:
+		return false
+	}
+}
+
+func indexOf(list []string, value string) int {
+	for idx, item := range list {
+		if item == value {
+			return idx
+		}
+	}
+	return -1
+}
@@ -0,0 +1,93 @@
+package list
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ohohleo/classify/data"
+	"github.com/stretchr/testify/assert"
+)
+
+func readList(t *testing.T, input string) (datas []data.Data) {
+
+	i, _, err := Create([]byte(input), nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := i.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for d := range c {
+		datas = append(datas, d)
+	}
+
+	return
+}
+
+func TestList(t *testing.T) {
+
+	assert := assert.New(t)
+
+	// Letterboxd export: default columns
+	assert.Equal([]data.Data{
+		&data.Movie{
+			Name:     "Looper",
+			Url:      "https://boxd.it/2Xgc",
+			Released: time.Date(2012, 1, 1, 0, 0, 0, 0, time.UTC),
+		},
+		&data.Movie{
+			Name:     "Brick, the movie",
+			Url:      "https://boxd.it/1Xao",
+			Released: time.Date(2005, 1, 1, 0, 0, 0, 0, time.UTC),
+		},
+	}, readList(t, `{"path":"`+filepath.Join("testdata", "letterboxd.csv")+`"}`))
+
+	// Generic data
+	assert.Equal([]data.Data{
+		&data.Generic{
+			Name: "Looper",
+			Time: time.Date(2019, 1, 2, 0, 0, 0, 0, time.UTC),
+		},
+		&data.Generic{
+			Name: "Brick, the movie",
+			Time: time.Date(2019, 1, 3, 0, 0, 0, 0, time.UTC),
+		},
+	}, readList(t, `{"path":"`+filepath.Join("testdata", "letterboxd.csv")+`","ref":"generic"}`))
+
+	// JSON & NDJSON with mapping
+	for _, name := range []string{"watchlist.json", "watchlist.ndjson"} {
+		assert.Equal([]data.Data{
+			&data.Movie{
+				Id:       "tt1276104",
+				Name:     "Looper",
+				Duration: 119,
+				Genres:   []string{"Action", "Sci-Fi"},
+			},
+			&data.Movie{
+				Id:       "tt0393109",
+				Name:     "Brick",
+				Duration: 110,
+				Genres:   []string{"Drama"},
+			},
+		}, readList(t, `{
+			"path":"`+filepath.Join("testdata", name)+`",
+			"mapping":{"id":"imdb","duration":"minutes"}
+		}`), name)
+	}
+
+	// Invalid params
+	for _, input := range []string{
+		`{}`,
+		`{"path":"list.txt"}`,
+		`{"path":"list.csv","ref":"email"}`,
+		`{"path":"list.csv","mapping":{"rating":"Your Rating"}}`,
+		`{"path":"list.csv","separator":";;"}`,
+	} {
+		_, _, err := Create([]byte(input), nil, nil)
+		assert.NotNil(err, input)
+	}
+}
@@ -0,0 +1,157 @@
+package list
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ohohleo/classify/data"
+)
+
+// Columns searched by default for each field (case insensitive): handles
+// the Letterboxd & the IMDb exports
+var defaultColumns = map[data.Ref]map[string][]string{
+	data.MOVIE: map[string][]string{
+		"id":          []string{"id", "const", "imdb id", "imdbid", "tmdb id"},
+		"name":        []string{"name", "title", "original title"},
+		"url":         []string{"url", "letterboxd uri"},
+		"released":    []string{"released", "release date", "year"},
+		"duration":    []string{"duration", "runtime (mins)", "runtime"},
+		"image":       []string{"image", "poster"},
+		"description": []string{"description", "overview"},
+		"directors":   []string{"directors", "director"},
+		"writers":     []string{"writers", "writer"},
+		"cast":        []string{"cast", "actors"},
+		"genres":      []string{"genres", "genre"},
+	},
+	data.GENERIC: map[string][]string{
+		"name": []string{"name", "title"},
+		"time": []string{"time", "date"},
+	},
+}
+
+// Date formats found in the lists
+var dateLayouts = []string{
+	"2006-01-02",
+	time.RFC3339,
+	"2006-01-02 15:04:05",
+	"02/01/2006",
+	"2006",
+}
+
+// Field of the data filled with a column
+type field struct {
+	column string
+	index  int
+}
+
+// Returns the columns used for each field of the data: the mapping
+// specified takes precedence over the default columns
+func getFields(d data.Data, mapping map[string]string, columns []string) (fields []field, err error) {
+
+	typ := reflect.TypeOf(d).Elem()
+
+	names := make(map[string]int, typ.NumField())
+	for idx := 0; idx < typ.NumField(); idx++ {
+		name := strings.Split(typ.Field(idx).Tag.Get("json"), ",")[0]
+		if name != "" && name != "-" {
+			names[name] = idx
+		}
+	}
+
+	// Columns found in the list
+	found := make(map[string]string, len(columns))
+	for _, column := range columns {
+		found[strings.ToLower(strings.TrimSpace(column))] = column
+	}
+
+	for name, column := range mapping {
+
+		idx, ok := names[name]
+		if ok == false {
+			err = fmt.Errorf("no field '%s' in '%s'", name, d.GetRef().String())
+			return
+		}
+
+		fields = append(fields, field{column: column, index: idx})
+	}
+
+	for name, candidates := range defaultColumns[d.GetRef()] {
+
+		if _, ok := mapping[name]; ok {
+			continue
+		}
+
+		for _, candidate := range candidates {
+			if column, ok := found[candidate]; ok {
+				fields = append(fields, field{column: column, index: names[name]})
+				break
+			}
+		}
+	}
+
+	return
+}
+
+// Set the fields of the data with the values of the row
+func setFields(d data.Data, fields []field, row map[string]string) error {
+
+	value := reflect.ValueOf(d).Elem()
+
+	for _, f := range fields {
+
+		str := strings.TrimSpace(row[f.column])
+		if str == "" {
+			continue
+		}
+
+		dst := value.Field(f.index)
+
+		switch dst.Interface().(type) {
+		case string:
+			dst.SetString(str)
+
+		case int, int64:
+			number, err := strconv.ParseInt(str, 10, 64)
+			if err != nil {
+				return fmt.Errorf("column '%s' invalid number '%s'", f.column, str)
+			}
+			dst.SetInt(number)
+
+		case time.Time:
+			date, err := parseDate(str)
+			if err != nil {
+				return fmt.Errorf("column '%s' invalid date '%s'", f.column, str)
+			}
+			dst.Set(reflect.ValueOf(date))
+
+		case []string:
+			var values []string
+			for _, item := range strings.Split(str, ",") {
+				if item = strings.TrimSpace(item); item != "" {
+					values = append(values, item)
+				}
+			}
+			dst.Set(reflect.ValueOf(values))
+
+		default:
+			return fmt.Errorf("column '%s' unhandled field type '%s'",
+				f.column, dst.Type().String())
+		}
+	}
+
+	return nil
+}
+
+func parseDate(value string) (date time.Time, err error) {
+
+	for _, layout := range dateLayouts {
+		if date, err = time.Parse(layout, value); err == nil {
+			return
+		}
+	}
+
+	return
+}
@@ -0,0 +1,308 @@
+package maildir
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/ohohleo/classify/data"
+	"github.com/ohohleo/classify/imports"
+	"github.com/ohohleo/classify/imports/imap"
+	"github.com/ohohleo/classify/params"
+)
+
+// Sub-directories of the delivered messages
+var subDirectories = []string{"new", "cur"}
+
+var errStopped = fmt.Errorf("import 'maildir' stopped")
+
+type MaildirConfig struct {
+	Store struct {
+		Path string `json:"path"`
+	} `json:"store"`
+}
+
+func DefaultConfig() *MaildirConfig {
+
+	config := &MaildirConfig{}
+
+	// Default store/maildir config
+	config.Store.Path = "/tmp/classify/imports/maildir"
+
+	return config
+}
+
+type Maildir struct {
+	Path   string      `json:"path"`
+	Search imap.Search `json:"search"`
+
+	config *MaildirConfig
+
+	// Unique names of the messages already imported: the flags added to
+	// the file names are ignored
+	state map[string]bool
+
+	isRunning bool
+	stop      chan struct{}
+	mutex     sync.Mutex
+}
+
+func ToBuild() imports.Build {
+	return imports.Build{
+		ForceCreate: ForceCreate,
+		Create:      Create,
+	}
+}
+
+func ForceCreate() (i imports.Import) {
+	return &Maildir{
+		config: DefaultConfig(),
+	}
+}
+
+func Create(input json.RawMessage, config json.RawMessage,
+	collections []string) (i imports.Import, params interface{}, err error) {
+
+	var maildir Maildir
+	if err = json.Unmarshal(input, &maildir); err != nil {
+		return
+	}
+
+	if maildir.Path == "" {
+		err = fmt.Errorf("import 'maildir' path required")
+		return
+	}
+
+	maildir.config = DefaultConfig()
+
+	i = &maildir
+	return
+}
+
+func (*Maildir) GetParams() []params.Param {
+	return []params.Param{new(params.Path)}
+}
+
+func (m *Maildir) GetRef() imports.Ref {
+	return imports.MAILDIR
+}
+
+func (m *Maildir) GetDatasReferences() []data.Data {
+	return []data.Data{
+		new(data.Email),
+		new(data.Attachment),
+	}
+}
+
+func (m *Maildir) CheckConfig(config json.RawMessage) error {
+	return nil
+}
+
+// Return a channel of the messages delivered since the last import
+func (m *Maildir) Start() (chan data.Data, error) {
+
+	c := make(chan data.Data)
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	// Check if the analysis is not already going on
+	if m.isRunning {
+		return c, fmt.Errorf("import 'maildir' already started")
+	}
+
+	files, err := m.getFiles()
+	if err != nil {
+		return c, err
+	}
+
+	m.isRunning = true
+
+	stop := make(chan struct{})
+	m.stop = stop
+
+	go func() {
+
+		err := m.readMessages(files, c, stop)
+		if err != nil && err != errStopped {
+			log.Printf("import 'maildir' '%s' %s\n", m.Path, err.Error())
+		}
+
+		close(c)
+
+		// Analysis is over
+		m.mutex.Lock()
+		m.isRunning = false
+		m.mutex.Unlock()
+	}()
+
+	return c, nil
+}
+
+func (m *Maildir) Stop() error {
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if m.stop != nil {
+		close(m.stop)
+		m.stop = nil
+	}
+
+	return nil
+}
+
+func (m *Maildir) Eq(new imports.Import) bool {
+	newMaildir, _ := new.(*Maildir)
+	return m.Path == newMaildir.Path
+}
+
+// GetState returns the messages already imported
+func (m *Maildir) GetState() (json.RawMessage, error) {
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	names := make([]string, 0, len(m.state))
+	for name := range m.state {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return json.Marshal(names)
+}
+
+// SetState set the messages already imported
+func (m *Maildir) SetState(src json.RawMessage) error {
+
+	var names []string
+	if err := json.Unmarshal(src, &names); err != nil {
+		return err
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.state = make(map[string]bool, len(names))
+	for _, name := range names {
+		m.state[name] = true
+	}
+
+	return nil
+}
+
+// Returns the unique name of the message without its flags
+func getUniqueName(name string) string {
+	return strings.SplitN(name, ":", 2)[0]
+}
+
+// Returns the messages files sorted by delivery: the names of the messages
+// not found anymore are removed from the state
+func (m *Maildir) getFiles() (files []string, err error) {
+
+	found := make(map[string]bool)
+
+	for _, sub := range subDirectories {
+
+		var infos []os.FileInfo
+		if infos, err = ioutil.ReadDir(filepath.Join(m.Path, sub)); err != nil {
+			err = fmt.Errorf("import 'maildir' invalid maildir: %s", err.Error())
+			return
+		}
+
+		for _, info := range infos {
+			if info.Mode().IsRegular() == false || strings.HasPrefix(info.Name(), ".") {
+				continue
+			}
+
+			files = append(files, filepath.Join(m.Path, sub, info.Name()))
+			found[getUniqueName(info.Name())] = true
+		}
+	}
+
+	// The names start with the delivery time
+	sort.Slice(files, func(i, j int) bool {
+		return filepath.Base(files[i]) < filepath.Base(files[j])
+	})
+
+	for name := range m.state {
+		if found[name] == false {
+			delete(m.state, name)
+		}
+	}
+
+	return
+}
+
+func (m *Maildir) isImported(name string) bool {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return m.state[name]
+}
+
+func (m *Maildir) setImported(name string) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if m.state == nil {
+		m.state = make(map[string]bool)
+	}
+	m.state[name] = true
+}
+
+// Send the messages not imported yet
+func (m *Maildir) readMessages(files []string, c chan data.Data, stop chan struct{}) error {
+
+	for _, path := range files {
+
+		name := getUniqueName(filepath.Base(path))
+		if m.isImported(name) {
+			continue
+		}
+
+		// Message moved or removed since the listing
+		raw, err := ioutil.ReadFile(path)
+		if err != nil {
+			log.Printf("import 'maildir' %s\n", err.Error())
+			continue
+		}
+
+		if err := m.onMessage(raw, c, stop); err != nil {
+			return err
+		}
+
+		m.setImported(name)
+	}
+
+	return nil
+}
+
+// Send the message matching the search & its attachments
+func (m *Maildir) onMessage(raw []byte, c chan data.Data, stop chan struct{}) error {
+
+	email, err := imap.ReadEmail(bytes.NewReader(raw))
+	if err != nil {
+		log.Printf("import 'maildir' '%s' invalid message: %s\n", m.Path, err.Error())
+		return nil
+	}
+
+	if m.Search.Match(email, raw) == false {
+		return nil
+	}
+
+	return imap.SendEmail(email, m.config.Store.Path, func(d data.Data) error {
+		select {
+		case c <- d:
+			return nil
+		case <-stop:
+			return errStopped
+		}
+	})
+}
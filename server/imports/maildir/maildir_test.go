@@ -0,0 +1,115 @@
+package maildir
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func deliver(t *testing.T, dir string, name string, subject string) {
+
+	body := "From: andre@example.org\r\n" +
+		"Subject: " + subject + "\r\n" +
+		"Date: Fri, 28 Sep 2012 10:00:00 +0000\r\n" +
+		"Content-Type: multipart/mixed; boundary=b\r\n" +
+		"\r\n" +
+		"--b\r\n" +
+		"Content-Type: text/plain\r\n" +
+		"\r\n" +
+		"Poster attached\r\n" +
+		"--b\r\n" +
+		"Content-Type: image/png\r\n" +
+		"Content-Disposition: attachment; filename=poster.png\r\n" +
+		"\r\n" +
+		"png\r\n" +
+		"--b--\r\n"
+
+	if err := ioutil.WriteFile(filepath.Join(dir, "new", name), []byte(body), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func readAll(t *testing.T, m *Maildir) (names []string) {
+
+	c, err := m.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for d := range c {
+		names = append(names, d.GetName())
+	}
+
+	return
+}
+
+func TestMaildir(t *testing.T) {
+
+	assert := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "maildir")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	i, _, err := Create([]byte(`{"path":"`+dir+`"}`), nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := i.(*Maildir)
+	m.config.Store.Path = filepath.Join(dir, "store")
+
+	// Not a maildir
+	_, err = m.Start()
+	assert.NotNil(err)
+
+	for _, sub := range []string{"new", "cur", "tmp"} {
+		if err := os.Mkdir(filepath.Join(dir, sub), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	deliver(t, dir, "1348826400.1.host", "Looper")
+	deliver(t, dir, "1348826401.2.host", "Brick")
+
+	assert.Equal([]string{
+		"poster.png", "andre@example.org:Looper",
+		"poster.png", "andre@example.org:Brick",
+	}, readAll(t, m))
+
+	// Attachments stored
+	files, err := ioutil.ReadDir(m.config.Store.Path)
+	assert.Nil(err)
+	assert.Len(files, 1)
+
+	// Message read & flagged by the mail client
+	err = os.Rename(filepath.Join(dir, "new", "1348826400.1.host"),
+		filepath.Join(dir, "cur", "1348826400.1.host:2,S"))
+	assert.Nil(err)
+
+	deliver(t, dir, "1348826402.3.host", "The Loop")
+
+	state, err := m.GetState()
+	assert.Nil(err)
+
+	m = ForceCreate().(*Maildir)
+	m.Path = dir
+	m.config.Store.Path = filepath.Join(dir, "store")
+	assert.Nil(m.SetState(state))
+
+	assert.Equal([]string{"poster.png", "andre@example.org:The Loop"}, readAll(t, m))
+	assert.Len(readAll(t, m), 0)
+
+	// Messages removed forgotten
+	os.Remove(filepath.Join(dir, "new", "1348826401.2.host"))
+	readAll(t, m)
+
+	state, err = m.GetState()
+	assert.Nil(err)
+	assert.JSONEq(`["1348826400.1.host","1348826402.3.host"]`, string(state))
+}
@@ -0,0 +1,323 @@
+package mbox
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sync"
+
+	"github.com/ohohleo/classify/data"
+	"github.com/ohohleo/classify/imports"
+	"github.com/ohohleo/classify/imports/imap"
+	"github.com/ohohleo/classify/params"
+)
+
+var separator = []byte("From ")
+
+var errStopped = fmt.Errorf("import 'mbox' stopped")
+
+type MboxConfig struct {
+	Store struct {
+		Path string `json:"path"`
+	} `json:"store"`
+}
+
+func DefaultConfig() *MboxConfig {
+
+	config := &MboxConfig{}
+
+	// Default store/mbox config
+	config.Store.Path = "/tmp/classify/imports/mbox"
+
+	return config
+}
+
+// Messages already imported: the mbox files are only appended, the file
+// is read again from the beginning if its first message changed
+type State struct {
+	Offset int64  `json:"offset"`
+	Head   string `json:"head"`
+}
+
+type Mbox struct {
+	Path   string      `json:"path"`
+	Search imap.Search `json:"search"`
+
+	config    *MboxConfig
+	state     State
+	isRunning bool
+	stop      chan struct{}
+	mutex     sync.Mutex
+}
+
+func ToBuild() imports.Build {
+	return imports.Build{
+		ForceCreate: ForceCreate,
+		Create:      Create,
+	}
+}
+
+func ForceCreate() (i imports.Import) {
+	return &Mbox{
+		config: DefaultConfig(),
+	}
+}
+
+func Create(input json.RawMessage, config json.RawMessage,
+	collections []string) (i imports.Import, params interface{}, err error) {
+
+	var mbox Mbox
+	if err = json.Unmarshal(input, &mbox); err != nil {
+		return
+	}
+
+	if mbox.Path == "" {
+		err = fmt.Errorf("import 'mbox' path required")
+		return
+	}
+
+	mbox.config = DefaultConfig()
+
+	i = &mbox
+	return
+}
+
+func (*Mbox) GetParams() []params.Param {
+	return []params.Param{new(params.Path)}
+}
+
+func (m *Mbox) GetRef() imports.Ref {
+	return imports.MBOX
+}
+
+func (m *Mbox) GetDatasReferences() []data.Data {
+	return []data.Data{
+		new(data.Email),
+		new(data.Attachment),
+	}
+}
+
+func (m *Mbox) CheckConfig(config json.RawMessage) error {
+	return nil
+}
+
+// Return a channel of the messages appended since the last import
+func (m *Mbox) Start() (chan data.Data, error) {
+
+	c := make(chan data.Data)
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	// Check if the analysis is not already going on
+	if m.isRunning {
+		return c, fmt.Errorf("import 'mbox' already started")
+	}
+
+	f, err := os.Open(m.Path)
+	if err != nil {
+		return c, err
+	}
+
+	// Read the whole file again if replaced
+	head, err := getHead(f)
+	if err != nil {
+		f.Close()
+		return c, err
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return c, err
+	}
+
+	if head != m.state.Head || info.Size() < m.state.Offset {
+		m.state = State{Head: head}
+	}
+
+	if _, err = f.Seek(m.state.Offset, io.SeekStart); err != nil {
+		f.Close()
+		return c, err
+	}
+
+	m.isRunning = true
+
+	stop := make(chan struct{})
+	m.stop = stop
+
+	go func() {
+
+		err := m.readMessages(f, c, stop)
+		if err != nil && err != errStopped {
+			log.Printf("import 'mbox' '%s' %s\n", m.Path, err.Error())
+		}
+
+		f.Close()
+		close(c)
+
+		// Analysis is over
+		m.mutex.Lock()
+		m.isRunning = false
+		m.mutex.Unlock()
+	}()
+
+	return c, nil
+}
+
+func (m *Mbox) Stop() error {
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if m.stop != nil {
+		close(m.stop)
+		m.stop = nil
+	}
+
+	return nil
+}
+
+func (m *Mbox) Eq(new imports.Import) bool {
+	newMbox, _ := new.(*Mbox)
+	return m.Path == newMbox.Path
+}
+
+// GetState returns the position of the messages already imported
+func (m *Mbox) GetState() (json.RawMessage, error) {
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	return json.Marshal(&m.state)
+}
+
+// SetState set the position of the messages already imported
+func (m *Mbox) SetState(src json.RawMessage) error {
+
+	var state State
+	if err := json.Unmarshal(src, &state); err != nil {
+		return err
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.state = state
+	return nil
+}
+
+// Returns the hash of the first line identifying the file
+func getHead(f *os.File) (string, error) {
+
+	line, err := bufio.NewReader(f).ReadBytes('\n')
+	if err != nil && err != io.EOF {
+		return "", err
+	}
+
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+
+	hash := sha1.Sum(line)
+	return hex.EncodeToString(hash[:]), nil
+}
+
+// Read the messages separated by the 'From ' lines: the offset is kept
+// after each message sent
+func (m *Mbox) readMessages(r io.Reader, c chan data.Data, stop chan struct{}) error {
+
+	reader := bufio.NewReader(r)
+
+	m.mutex.Lock()
+	offset := m.state.Offset
+	m.mutex.Unlock()
+
+	var message bytes.Buffer
+	inMessage, isBlank := false, true
+
+	for {
+		line, err := reader.ReadBytes('\n')
+
+		if bytes.HasPrefix(line, separator) && isBlank {
+
+			if inMessage {
+				if err := m.onMessage(message.Bytes(), c, stop); err != nil {
+					return err
+				}
+				m.setOffset(offset)
+			}
+
+			message.Reset()
+			inMessage = true
+		} else if inMessage {
+			message.Write(unescape(line))
+		}
+
+		isBlank = len(bytes.TrimRight(line, "\r\n")) == 0
+		offset += int64(len(line))
+
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	if inMessage {
+		if err := m.onMessage(message.Bytes(), c, stop); err != nil {
+			return err
+		}
+		m.setOffset(offset)
+	}
+
+	return nil
+}
+
+func (m *Mbox) setOffset(offset int64) {
+	m.mutex.Lock()
+	m.state.Offset = offset
+	m.mutex.Unlock()
+}
+
+// Send the message matching the search & its attachments
+func (m *Mbox) onMessage(raw []byte, c chan data.Data, stop chan struct{}) error {
+
+	email, err := imap.ReadEmail(bytes.NewReader(raw))
+	if err != nil {
+		log.Printf("import 'mbox' '%s' invalid message: %s\n", m.Path, err.Error())
+		return nil
+	}
+
+	if m.Search.Match(email, raw) == false {
+		return nil
+	}
+
+	return imap.SendEmail(email, m.config.Store.Path, func(d data.Data) error {
+		select {
+		case c <- d:
+			return nil
+		case <-stop:
+			return errStopped
+		}
+	})
+}
+
+// Remove the '>' escaping the 'From ' lines inside the messages
+func unescape(line []byte) []byte {
+
+	quoted := bytes.TrimLeft(line, ">")
+	if len(quoted) < len(line) && bytes.HasPrefix(quoted, separator) {
+		return line[1:]
+	}
+
+	return line
+}
@@ -0,0 +1,110 @@
+package mbox
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ohohleo/classify/data"
+	"github.com/stretchr/testify/assert"
+)
+
+func message(subject string, date string, body string) string {
+	return "From andre@example.org " + date + "\n" +
+		"From: andre@example.org\n" +
+		"Subject: " + subject + "\n" +
+		"Date: " + date + "\n" +
+		"Content-Type: text/plain\n" +
+		"\n" +
+		body + "\n" +
+		"\n"
+}
+
+func readAll(t *testing.T, m *Mbox) (emails []*data.Email) {
+
+	c, err := m.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for d := range c {
+		if email, ok := d.(*data.Email); ok {
+			emails = append(emails, email)
+		}
+	}
+
+	return
+}
+
+func getSubjects(emails []*data.Email) (subjects []string) {
+	for _, email := range emails {
+		subjects = append(subjects, email.Subject)
+	}
+	return
+}
+
+func TestMbox(t *testing.T) {
+
+	assert := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "mbox")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "archive.mbox")
+	err = ioutil.WriteFile(path, []byte(
+		message("Looper", "Fri, 28 Sep 2012 10:00:00 +0000", "Time travel\n>From now on")+
+			message("Brick", "Fri, 07 Apr 2006 10:00:00 +0000", "High school")), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	i, _, err := Create([]byte(`{"path":"`+path+`"}`), nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := i.(*Mbox)
+	m.config.Store.Path = dir
+
+	emails := readAll(t, m)
+	assert.Equal([]string{"Looper", "Brick"}, getSubjects(emails))
+	if assert.Len(emails, 2) {
+		assert.Equal("Time travel\nFrom now on\n\n", emails[0].Text)
+	}
+
+	// Only the messages appended
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(message("The Loop", "Mon, 01 Apr 2019 10:00:00 +0000", "Series"))
+	f.Close()
+
+	state, err := m.GetState()
+	assert.Nil(err)
+
+	m = ForceCreate().(*Mbox)
+	m.Path = path
+	assert.Nil(m.SetState(state))
+	assert.Equal([]string{"The Loop"}, getSubjects(readAll(t, m)))
+	assert.Len(readAll(t, m), 0)
+
+	// File replaced: read again with the search filters
+	err = ioutil.WriteFile(path, []byte(
+		message("Knives Out", "Fri, 29 Nov 2019 10:00:00 +0000", "Whodunit")+
+			message("Looper", "Fri, 28 Sep 2012 10:00:00 +0000", "Time travel")+
+			message("The Loop", "Mon, 01 Apr 2019 10:00:00 +0000", "Series")), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m.Search.Since = time.Date(2012, 9, 28, 0, 0, 0, 0, time.UTC)
+	m.Search.Before = time.Date(2019, 11, 29, 0, 0, 0, 0, time.UTC)
+	m.Search.Text = []string{"TIME"}
+	assert.Equal([]string{"Looper"}, getSubjects(readAll(t, m)))
+}
@@ -0,0 +1,262 @@
+package rss
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ohohleo/classify/data"
+	"golang.org/x/text/encoding/htmlindex"
+)
+
+// RSS 2.0 document
+type rssDocument struct {
+	Channel struct {
+		Title string    `xml:"title"`
+		Items []rssItem `xml:"item"`
+	} `xml:"channel"`
+}
+
+type rssItem struct {
+	Guid        string   `xml:"guid"`
+	Title       string   `xml:"title"`
+	Link        string   `xml:"link"`
+	Author      string   `xml:"author"`
+	Creator     string   `xml:"http://purl.org/dc/elements/1.1/ creator"`
+	Description string   `xml:"description"`
+	Categories  []string `xml:"category"`
+	PubDate     string   `xml:"pubDate"`
+	Date        string   `xml:"http://purl.org/dc/elements/1.1/ date"`
+	Enclosure   *struct {
+		Url    string `xml:"url,attr"`
+		Type   string `xml:"type,attr"`
+		Length string `xml:"length,attr"`
+	} `xml:"enclosure"`
+}
+
+// Atom document
+type atomFeed struct {
+	Title   string      `xml:"title"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomEntry struct {
+	Id         string     `xml:"id"`
+	Title      string     `xml:"title"`
+	Links      []atomLink `xml:"link"`
+	Authors    []string   `xml:"author>name"`
+	Summary    string     `xml:"summary"`
+	Content    string     `xml:"content"`
+	Published  string     `xml:"published"`
+	Updated    string     `xml:"updated"`
+	Categories []struct {
+		Term string `xml:"term,attr"`
+	} `xml:"category"`
+}
+
+type atomLink struct {
+	Href   string `xml:"href,attr"`
+	Rel    string `xml:"rel,attr"`
+	Type   string `xml:"type,attr"`
+	Length string `xml:"length,attr"`
+}
+
+// Date formats found in the feeds
+var dateLayouts = []string{
+	time.RFC1123Z,
+	time.RFC1123,
+	time.RFC3339,
+	"Mon, 2 Jan 2006 15:04:05 -0700",
+	"Mon, 2 Jan 2006 15:04:05 MST",
+	"Mon, 02 Jan 06 15:04:05 -0700",
+	"Mon, 2 Jan 06 15:04:05 MST",
+	"2 Jan 2006 15:04:05 -0700",
+	"2006-01-02T15:04:05",
+	"2006-01-02",
+}
+
+// ReadFeed decodes the RSS 2.0 or the Atom feed
+func ReadFeed(r io.Reader) (entries []*data.FeedEntry, err error) {
+
+	decoder := xml.NewDecoder(r)
+	decoder.Strict = false
+	decoder.CharsetReader = charsetReader
+
+	// Find the root element
+	var root xml.StartElement
+	for {
+		var token xml.Token
+		if token, err = decoder.Token(); err != nil {
+			err = fmt.Errorf("invalid feed: %s", err.Error())
+			return
+		}
+
+		var ok bool
+		if root, ok = token.(xml.StartElement); ok {
+			break
+		}
+	}
+
+	switch strings.ToLower(root.Name.Local) {
+	case "rss":
+		var document rssDocument
+		if err = decoder.DecodeElement(&document, &root); err != nil {
+			return
+		}
+		entries = document.getEntries()
+
+	case "feed":
+		var feed atomFeed
+		if err = decoder.DecodeElement(&feed, &root); err != nil {
+			return
+		}
+		entries = feed.getEntries()
+
+	default:
+		err = fmt.Errorf("unhandled feed format '%s'", root.Name.Local)
+	}
+
+	return
+}
+
+func (d *rssDocument) getEntries() []*data.FeedEntry {
+
+	entries := make([]*data.FeedEntry, 0, len(d.Channel.Items))
+
+	for _, item := range d.Channel.Items {
+
+		entry := &data.FeedEntry{
+			Guid:        strings.TrimSpace(item.Guid),
+			Feed:        strings.TrimSpace(d.Channel.Title),
+			Title:       strings.TrimSpace(item.Title),
+			Link:        strings.TrimSpace(item.Link),
+			Author:      strings.TrimSpace(item.Author),
+			Description: strings.TrimSpace(item.Description),
+			Categories:  item.Categories,
+			Published:   parseDate(item.PubDate, item.Date),
+		}
+
+		if entry.Author == "" {
+			entry.Author = strings.TrimSpace(item.Creator)
+		}
+
+		if item.Enclosure != nil && item.Enclosure.Url != "" {
+			entry.Enclosure = &data.Enclosure{
+				Url:    strings.TrimSpace(item.Enclosure.Url),
+				Type:   item.Enclosure.Type,
+				Length: parseLength(item.Enclosure.Length),
+			}
+		}
+
+		setGuid(entry)
+		entries = append(entries, entry)
+	}
+
+	return entries
+}
+
+func (f *atomFeed) getEntries() []*data.FeedEntry {
+
+	entries := make([]*data.FeedEntry, 0, len(f.Entries))
+
+	for _, item := range f.Entries {
+
+		entry := &data.FeedEntry{
+			Guid:        strings.TrimSpace(item.Id),
+			Feed:        strings.TrimSpace(f.Title),
+			Title:       strings.TrimSpace(item.Title),
+			Author:      strings.TrimSpace(strings.Join(item.Authors, ", ")),
+			Description: strings.TrimSpace(item.Summary),
+			Published:   parseDate(item.Published, item.Updated),
+		}
+
+		if entry.Description == "" {
+			entry.Description = strings.TrimSpace(item.Content)
+		}
+
+		for _, category := range item.Categories {
+			entry.Categories = append(entry.Categories, category.Term)
+		}
+
+		for _, link := range item.Links {
+			switch link.Rel {
+			case "", "alternate":
+				if entry.Link == "" {
+					entry.Link = strings.TrimSpace(link.Href)
+				}
+			case "enclosure":
+				if entry.Enclosure == nil {
+					entry.Enclosure = &data.Enclosure{
+						Url:    strings.TrimSpace(link.Href),
+						Type:   link.Type,
+						Length: parseLength(link.Length),
+					}
+				}
+			}
+		}
+
+		setGuid(entry)
+		entries = append(entries, entry)
+	}
+
+	return entries
+}
+
+// Entries without identifier identified by their link or their content
+func setGuid(entry *data.FeedEntry) {
+
+	if entry.Guid != "" {
+		return
+	}
+
+	if entry.Link != "" {
+		entry.Guid = entry.Link
+		return
+	}
+
+	hash := sha1.New()
+	hash.Write([]byte(entry.Feed + "\n" + entry.Title + "\n" +
+		entry.Published.String() + "\n" + entry.Description))
+	entry.Guid = hex.EncodeToString(hash.Sum(nil))
+}
+
+// Returns the first date valid
+func parseDate(values ...string) time.Time {
+
+	for _, value := range values {
+
+		value = strings.TrimSpace(value)
+		if value == "" {
+			continue
+		}
+
+		for _, layout := range dateLayouts {
+			if date, err := time.Parse(layout, value); err == nil {
+				return date
+			}
+		}
+	}
+
+	return time.Time{}
+}
+
+func parseLength(value string) int64 {
+	length, _ := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+	return length
+}
+
+// Returns the reader converting the charset specified to UTF-8
+func charsetReader(charset string, input io.Reader) (io.Reader, error) {
+
+	encoding, err := htmlindex.Get(charset)
+	if err != nil {
+		return nil, fmt.Errorf("unhandled charset '%s'", charset)
+	}
+
+	return encoding.NewDecoder().Reader(input), nil
+}
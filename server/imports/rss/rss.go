@@ -0,0 +1,301 @@
+package rss
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/ohohleo/classify/data"
+	"github.com/ohohleo/classify/imports"
+)
+
+// Interval between the feed requests when watching
+const DEFAULT_POLL_INTERVAL = "15m"
+
+// Number of entries identifiers kept to ignore the entries already
+// imported
+const GUIDS_MAX = 1000
+
+var httpClient = &http.Client{
+	Timeout: 30 * time.Second,
+}
+
+// Feed already imported: the validators avoid downloading the feed when
+// not modified
+type State struct {
+	ETag         string   `json:"etag,omitempty"`
+	LastModified string   `json:"lastModified,omitempty"`
+	Guids        []string `json:"guids"`
+}
+
+type Rss struct {
+	Url string `json:"url"`
+
+	// Keep requesting the feed to import the new entries
+	Watch        bool   `json:"watch"`
+	PollInterval string `json:"pollInterval"`
+
+	state     State
+	guids     map[string]bool
+	isRunning bool
+	stop      chan struct{}
+	mutex     sync.Mutex
+}
+
+func ToBuild() imports.Build {
+	return imports.Build{
+		ForceCreate: ForceCreate,
+		Create:      Create,
+	}
+}
+
+func ForceCreate() (i imports.Import) {
+	return new(Rss)
+}
+
+func Create(input json.RawMessage, config json.RawMessage,
+	collections []string) (i imports.Import, params interface{}, err error) {
+
+	var rss Rss
+	if err = json.Unmarshal(input, &rss); err != nil {
+		return
+	}
+
+	feedUrl, err := url.Parse(rss.Url)
+	if err != nil || (feedUrl.Scheme != "http" && feedUrl.Scheme != "https") {
+		err = fmt.Errorf("import 'rss' invalid url '%s'", rss.Url)
+		return
+	}
+
+	if _, err = rss.getPollInterval(); err != nil {
+		err = fmt.Errorf("import 'rss' invalid poll interval: %s", err.Error())
+		return
+	}
+
+	i = &rss
+	return
+}
+
+func (r *Rss) GetRef() imports.Ref {
+	return imports.RSS
+}
+
+func (r *Rss) GetDatasReferences() []data.Data {
+	return []data.Data{
+		new(data.FeedEntry),
+	}
+}
+
+func (r *Rss) CheckConfig(config json.RawMessage) error {
+	return nil
+}
+
+func (r *Rss) getPollInterval() (time.Duration, error) {
+
+	interval := r.PollInterval
+	if interval == "" {
+		interval = DEFAULT_POLL_INTERVAL
+	}
+
+	duration, err := time.ParseDuration(interval)
+	if err == nil && duration <= 0 {
+		err = fmt.Errorf("'%s' should be positive", interval)
+	}
+
+	return duration, err
+}
+
+// Return a channel of the new feed entries: in watch mode the channel
+// stays open to send the entries published later
+func (r *Rss) Start() (chan data.Data, error) {
+
+	c := make(chan data.Data)
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	// Check if the analysis is not already going on
+	if r.isRunning {
+		return c, fmt.Errorf("import 'rss' already started")
+	}
+
+	r.isRunning = true
+
+	stop := make(chan struct{})
+	r.stop = stop
+
+	go func() {
+
+		interval, _ := r.getPollInterval()
+
+		for {
+			if err := r.fetch(c, stop); err != nil {
+				log.Printf("import 'rss' %s\n", err.Error())
+			}
+
+			if r.Watch == false || wait(stop, interval) == false {
+				break
+			}
+		}
+
+		close(c)
+
+		// Analysis is over
+		r.mutex.Lock()
+		r.isRunning = false
+		r.mutex.Unlock()
+	}()
+
+	return c, nil
+}
+
+// Wait for the next request: returns false if the import is stopped
+func wait(stop chan struct{}, interval time.Duration) bool {
+	select {
+	case <-stop:
+		return false
+	case <-time.After(interval):
+		return true
+	}
+}
+
+func (r *Rss) Stop() error {
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.stop != nil {
+		close(r.stop)
+		r.stop = nil
+	}
+
+	return nil
+}
+
+func (r *Rss) Eq(new imports.Import) bool {
+	newRss, _ := new.(*Rss)
+	return r.Url == newRss.Url
+}
+
+// GetState returns the validators & the entries already imported
+func (r *Rss) GetState() (json.RawMessage, error) {
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return json.Marshal(&r.state)
+}
+
+// SetState set the validators & the entries already imported
+func (r *Rss) SetState(src json.RawMessage) error {
+
+	var state State
+	if err := json.Unmarshal(src, &state); err != nil {
+		return err
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.state = state
+	r.guids = nil
+	return nil
+}
+
+// Request the feed & send the entries not imported yet
+func (r *Rss) fetch(c chan data.Data, stop chan struct{}) error {
+
+	request, err := http.NewRequest("GET", r.Url, nil)
+	if err != nil {
+		return err
+	}
+
+	r.mutex.Lock()
+	if r.state.ETag != "" {
+		request.Header.Set("If-None-Match", r.state.ETag)
+	}
+	if r.state.LastModified != "" {
+		request.Header.Set("If-Modified-Since", r.state.LastModified)
+	}
+	r.mutex.Unlock()
+
+	response, err := httpClient.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	switch response.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotModified:
+		return nil
+	default:
+		return fmt.Errorf("'%s' returns %s", r.Url, response.Status)
+	}
+
+	entries, err := ReadFeed(response.Body)
+	if err != nil {
+		return fmt.Errorf("'%s' %s", r.Url, err.Error())
+	}
+
+	// The oldest entries sent first
+	for idx := len(entries) - 1; idx >= 0; idx-- {
+
+		entry := entries[idx]
+		if r.isImported(entry.Guid) {
+			continue
+		}
+
+		select {
+		case c <- entry:
+		case <-stop:
+			return nil
+		}
+
+		r.setImported(entry.Guid)
+	}
+
+	// Validators kept once all the entries are sent
+	r.mutex.Lock()
+	r.state.ETag = response.Header.Get("ETag")
+	r.state.LastModified = response.Header.Get("Last-Modified")
+	r.mutex.Unlock()
+
+	return nil
+}
+
+func (r *Rss) isImported(guid string) bool {
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.guids == nil {
+		r.guids = make(map[string]bool, len(r.state.Guids))
+		for _, guid := range r.state.Guids {
+			r.guids[guid] = true
+		}
+	}
+
+	return r.guids[guid]
+}
+
+// Keep the identifier: only the last ones are kept
+func (r *Rss) setImported(guid string) {
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.guids[guid] = true
+	r.state.Guids = append(r.state.Guids, guid)
+
+	if len(r.state.Guids) > GUIDS_MAX {
+		for _, removed := range r.state.Guids[:len(r.state.Guids)-GUIDS_MAX] {
+			delete(r.guids, removed)
+		}
+		r.state.Guids = r.state.Guids[len(r.state.Guids)-GUIDS_MAX:]
+	}
+}
@@ -0,0 +1,149 @@
+package rss
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ohohleo/classify/data"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadFeed(t *testing.T) {
+
+	assert := assert.New(t)
+
+	for name, expected := range map[string][]*data.FeedEntry{
+		"rss.xml": []*data.FeedEntry{
+			&data.FeedEntry{
+				Guid:        "episode-2",
+				Feed:        "Cinéma",
+				Title:       "The Loop",
+				Link:        "http://example.org/the-loop",
+				Author:      "André",
+				Description: "Second episode",
+				Categories:  []string{"podcast"},
+				Published:   time.Date(2012, 10, 6, 10, 0, 0, 0, time.UTC),
+				Enclosure: &data.Enclosure{
+					Url:    "http://example.org/the-loop.mp3",
+					Type:   "audio/mpeg",
+					Length: 42000,
+				},
+			},
+			&data.FeedEntry{
+				Guid:      "http://example.org/looper",
+				Feed:      "Cinéma",
+				Title:     "Café Looper",
+				Link:      "http://example.org/looper",
+				Published: time.Date(2012, 9, 28, 10, 0, 0, 0, time.UTC),
+			},
+		},
+		"atom.xml": []*data.FeedEntry{
+			&data.FeedEntry{
+				Guid:        "tag:example.org,2012:looper",
+				Feed:        "Releases",
+				Title:       "Looper",
+				Link:        "http://example.org/releases/looper",
+				Author:      "Rian Johnson",
+				Description: "Time travel",
+				Categories:  []string{"movie"},
+				Published:   time.Date(2012, 9, 28, 10, 0, 0, 0, time.UTC),
+				Enclosure: &data.Enclosure{
+					Url:    "http://example.org/looper.mp4",
+					Type:   "video/mp4",
+					Length: 1024,
+				},
+			},
+		},
+	} {
+		f, err := os.Open(filepath.Join("testdata", name))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		entries, err := ReadFeed(f)
+		f.Close()
+
+		if assert.Nil(err, name) == false || assert.Len(entries, len(expected), name) == false {
+			continue
+		}
+
+		// Compare the dates separately
+		for idx, entry := range entries {
+			assert.True(expected[idx].Published.Equal(entry.Published), name)
+			entry.Published = expected[idx].Published
+		}
+
+		assert.Equal(expected, entries, name)
+	}
+}
+
+func TestFetch(t *testing.T) {
+
+	assert := assert.New(t)
+
+	feed, err := ioutil.ReadFile(filepath.Join("testdata", "rss.xml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			requests++
+
+			if r.Header.Get("If-None-Match") == `"v1"` {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+
+			w.Header().Set("ETag", `"v1"`)
+			w.Write(feed)
+		}))
+	defer server.Close()
+
+	i, _, err := Create([]byte(`{"url":"`+server.URL+`"}`), nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := func() (titles []string) {
+		c, err := i.Start()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for d := range c {
+			titles = append(titles, d.GetName())
+		}
+		return
+	}
+
+	// The oldest entries first
+	assert.Equal([]string{"Café Looper", "The Loop"}, start())
+
+	// Feed not modified
+	assert.Len(start(), 0)
+	assert.Equal(2, requests)
+
+	// Entries already imported ignored
+	rss := i.(*Rss)
+	state, err := rss.GetState()
+	assert.Nil(err)
+
+	i = ForceCreate()
+	i.(*Rss).Url = server.URL
+	assert.Nil(i.(*Rss).SetState(state))
+	i.(*Rss).state.ETag = ""
+
+	assert.Len(start(), 0)
+	assert.Equal(3, requests)
+
+	// Invalid urls
+	_, _, err = Create([]byte(`{"url":"ftp://example.org/feed"}`), nil, nil)
+	assert.NotNil(err)
+}
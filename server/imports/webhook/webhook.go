@@ -0,0 +1,241 @@
+package webhook
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/ohohleo/classify/data"
+	"github.com/ohohleo/classify/imports"
+	"github.com/ohohleo/classify/secrets"
+)
+
+// Header holding the HMAC-SHA256 of the body: "sha256=HEX"
+const SIGNATURE_HEADER = "X-Hub-Signature-256"
+
+const SIGNATURE_PREFIX = "sha256="
+
+var ErrInvalidSignature = errors.New("import 'webhook' invalid signature")
+
+type Webhook struct {
+	// Data type of the documents pushed
+	Ref string `json:"ref"`
+
+	// Key of the body signature, no signature checked if empty
+	Secret string `json:"secret" secret:"true"`
+
+	dataChannel chan data.Data
+	stop        chan struct{}
+	pushing     sync.WaitGroup
+	mutex       sync.Mutex
+}
+
+func ToBuild() imports.Build {
+	return imports.Build{
+		ForceCreate: ForceCreate,
+		Create:      Create,
+	}
+}
+
+func ForceCreate() (i imports.Import) {
+	return new(Webhook)
+}
+
+func Create(input json.RawMessage, config json.RawMessage,
+	collections []string) (i imports.Import, params interface{}, err error) {
+
+	var webhook Webhook
+	if err = json.Unmarshal(input, &webhook); err != nil {
+		return
+	}
+
+	if _, ok := data.REF_STR2IDX[webhook.Ref]; ok == false {
+		err = fmt.Errorf("import 'webhook' invalid data ref '%s'", webhook.Ref)
+		return
+	}
+
+	i = &webhook
+	return
+}
+
+func (w *Webhook) GetRef() imports.Ref {
+	return imports.WEBHOOK
+}
+
+func (w *Webhook) GetDatasReferences() []data.Data {
+
+	d, err := data.New(data.REF_STR2IDX[w.Ref])
+	if err != nil {
+		return []data.Data{}
+	}
+
+	return []data.Data{d}
+}
+
+func (w *Webhook) CheckConfig(config json.RawMessage) error {
+	return nil
+}
+
+// Return the channel of the data pushed: it stays open until the import
+// is stopped
+func (w *Webhook) Start() (chan data.Data, error) {
+
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	// Check if the import is not already waiting for data
+	if w.dataChannel != nil {
+		return nil, fmt.Errorf("import 'webhook' already started")
+	}
+
+	w.dataChannel = make(chan data.Data)
+	w.stop = make(chan struct{})
+
+	return w.dataChannel, nil
+}
+
+func (w *Webhook) Stop() error {
+
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if w.dataChannel == nil {
+		return fmt.Errorf("import 'webhook' already stopped")
+	}
+
+	// Stop the data being pushed before closing the channel
+	close(w.stop)
+	w.pushing.Wait()
+
+	close(w.dataChannel)
+	w.dataChannel = nil
+	return nil
+}
+
+// Each webhook has its own push route
+func (w *Webhook) Eq(new imports.Import) bool {
+	return false
+}
+
+// Push the JSON document or the NDJSON batch: returns the number of data
+// sent to the collections
+func (w *Webhook) Push(body []byte, signature string) (count int, err error) {
+
+	if err = w.checkSignature(body, signature); err != nil {
+		return
+	}
+
+	// All the documents are checked before being sent
+	datas, err := w.decode(body)
+	if err != nil {
+		return
+	}
+
+	w.mutex.Lock()
+	c, stop := w.dataChannel, w.stop
+	if c != nil {
+		w.pushing.Add(1)
+	}
+	w.mutex.Unlock()
+
+	if c == nil {
+		err = fmt.Errorf("import 'webhook' not started")
+		return
+	}
+
+	defer w.pushing.Done()
+
+	for _, d := range datas {
+		select {
+		case c <- d:
+			count++
+		case <-stop:
+			err = fmt.Errorf("import 'webhook' stopped")
+			return
+		}
+	}
+
+	return
+}
+
+// Check the HMAC-SHA256 of the body
+func (w *Webhook) checkSignature(body []byte, signature string) error {
+
+	key, err := secrets.Resolve(w.Secret)
+	if err != nil {
+		return fmt.Errorf("import 'webhook' secret: %s", err.Error())
+	}
+
+	if key == "" {
+		return nil
+	}
+
+	received, err := hex.DecodeString(strings.TrimPrefix(signature, SIGNATURE_PREFIX))
+	if err != nil || strings.HasPrefix(signature, SIGNATURE_PREFIX) == false {
+		return ErrInvalidSignature
+	}
+
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write(body)
+
+	if hmac.Equal(received, mac.Sum(nil)) == false {
+		return ErrInvalidSignature
+	}
+
+	return nil
+}
+
+// Returns the data of the JSON document (object or array of objects) or
+// of each NDJSON line
+func (w *Webhook) decode(body []byte) (datas []data.Data, err error) {
+
+	var documents []json.RawMessage
+
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	for {
+		var document json.RawMessage
+		if err = decoder.Decode(&document); err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("import 'webhook' document %d: %s",
+				len(documents)+1, err.Error())
+		}
+
+		// Array of documents
+		if bytes.HasPrefix(document, []byte("[")) {
+			var list []json.RawMessage
+			if err = json.Unmarshal(document, &list); err != nil {
+				return
+			}
+			documents = append(documents, list...)
+			continue
+		}
+
+		documents = append(documents, document)
+	}
+
+	if len(documents) == 0 {
+		return nil, fmt.Errorf("import 'webhook' no document")
+	}
+
+	for idx, document := range documents {
+
+		var d data.Data
+		if d, err = data.NewFromJSON(w.Ref, document); err != nil {
+			return nil, fmt.Errorf("import 'webhook' document %d: %s",
+				idx+1, err.Error())
+		}
+
+		datas = append(datas, d)
+	}
+
+	return
+}
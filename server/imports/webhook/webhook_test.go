@@ -0,0 +1,88 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+
+	"github.com/ohohleo/classify/data"
+	"github.com/stretchr/testify/assert"
+)
+
+func sign(key string, body string) string {
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write([]byte(body))
+	return SIGNATURE_PREFIX + hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestPush(t *testing.T) {
+
+	assert := assert.New(t)
+
+	_, _, err := Create([]byte(`{"ref":"unknown"}`), nil, nil)
+	assert.NotNil(err)
+
+	i, _, err := Create([]byte(`{"ref":"movie","secret":"looper"}`), nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := i.(*Webhook)
+
+	body := `{"name":"Looper"}`
+
+	// Not started
+	_, err = w.Push([]byte(body), sign("looper", body))
+	assert.NotNil(err)
+
+	c, err := w.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	received := make(chan []string)
+	go func() {
+		var names []string
+		for d := range c {
+			names = append(names, d.GetName())
+		}
+		received <- names
+	}()
+
+	// Invalid signatures
+	_, err = w.Push([]byte(body), "")
+	assert.Equal(ErrInvalidSignature, err)
+
+	_, err = w.Push([]byte(body), sign("brick", body))
+	assert.Equal(ErrInvalidSignature, err)
+
+	// Single document
+	count, err := w.Push([]byte(body), sign("looper", body))
+	assert.Nil(err)
+	assert.Equal(1, count)
+
+	// NDJSON batch & array
+	for _, batch := range []string{
+		"{\"name\":\"Brick\"}\n{\"name\":\"The Brothers Bloom\"}\n",
+		`[{"name":"Knives Out"},{"name":"Glass Onion"}]`,
+	} {
+		count, err = w.Push([]byte(batch), sign("looper", batch))
+		assert.Nil(err)
+		assert.Equal(2, count)
+	}
+
+	// Batch rejected if a document is invalid
+	batch := "{\"name\":\"Brick\"}\n{\"name\":42}\n"
+	_, err = w.Push([]byte(batch), sign("looper", batch))
+	assert.NotNil(err)
+
+	assert.Nil(w.Stop())
+	assert.NotNil(w.Stop())
+
+	assert.Equal([]string{
+		"Looper", "Brick", "The Brothers Bloom", "Knives Out", "Glass Onion",
+	}, <-received)
+
+	assert.Equal([]data.Data{new(data.Movie)}, w.GetDatasReferences())
+}
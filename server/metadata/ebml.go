@@ -0,0 +1,229 @@
+package metadata
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+// EBML elements handled
+const (
+	ebmlHeader        = 0x1A45DFA3
+	ebmlDocType       = 0x4282
+	ebmlSegment       = 0x18538067
+	ebmlInfo          = 0x1549A966
+	ebmlTimecodeScale = 0x2AD7B1
+	ebmlDuration      = 0x4489
+	ebmlDateUTC       = 0x4461
+	ebmlTitle         = 0x7BA9
+	ebmlTracks        = 0x1654AE6B
+	ebmlTrackEntry    = 0xAE
+	ebmlVideo         = 0xE0
+	ebmlPixelWidth    = 0xB0
+	ebmlPixelHeight   = 0xBA
+	ebmlCluster       = 0x1F43B675
+)
+
+// Master elements read to find the values handled
+var ebmlMasters = map[uint64]struct{}{
+	ebmlHeader:     struct{}{},
+	ebmlSegment:    struct{}{},
+	ebmlInfo:       struct{}{},
+	ebmlTracks:     struct{}{},
+	ebmlTrackEntry: struct{}{},
+	ebmlVideo:      struct{}{},
+}
+
+// Matroska dates are stored in nanoseconds since 2001
+var ebmlEpoch = time.Date(2001, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// Maximum size of the values read
+const ebmlValueMax = 1 << 16
+
+type ebmlReader struct {
+	r     Reader
+	infos Infos
+
+	// Duration read before or after the timecode scale
+	timecodeScale uint64
+	duration      float64
+
+	// Stop at the first cluster: no more headers after
+	stop bool
+}
+
+// Read the EBML header & the segment informations until the first
+// cluster
+func readEBML(r Reader, infos Infos) error {
+
+	end, err := r.Seek(0, 2)
+	if err != nil {
+		return err
+	}
+
+	e := &ebmlReader{
+		r:             r,
+		infos:         infos,
+		timecodeScale: 1000000,
+	}
+
+	if err = e.readElements(0, end); err != nil {
+		return err
+	}
+
+	infos.setDuration(e.duration * float64(e.timecodeScale) / 1e9)
+	return nil
+}
+
+func (e *ebmlReader) readElements(offset int64, end int64) error {
+
+	for offset < end && e.stop == false {
+
+		header, err := readAt(e.r, offset, 12)
+		if err != nil || len(header) < 2 {
+			return err
+		}
+
+		id, idSize, err := readVint(header, true)
+		if err != nil {
+			return err
+		}
+
+		size, sizeSize, err := readVint(header[idSize:], false)
+		if err != nil {
+			return err
+		}
+
+		content := offset + int64(idSize+sizeSize)
+
+		// Element header running past its parent
+		if content > end {
+			return fmt.Errorf("truncated EBML element at %d", offset)
+		}
+
+		// Unknown size: until the end of the parent
+		elementEnd := end
+		if content < end && size < uint64(end-content) {
+			elementEnd = content + int64(size)
+		}
+
+		if id == ebmlCluster {
+			e.stop = true
+			return nil
+		}
+
+		if _, ok := ebmlMasters[id]; ok {
+			if err := e.readElements(content, elementEnd); err != nil {
+				return err
+			}
+		} else if err := e.readValue(id, content, elementEnd-content); err != nil {
+			return err
+		}
+
+		offset = elementEnd
+	}
+
+	return nil
+}
+
+func (e *ebmlReader) readValue(id uint64, offset int64, size int64) error {
+
+	switch id {
+	case ebmlDocType, ebmlTimecodeScale, ebmlDuration, ebmlDateUTC,
+		ebmlTitle, ebmlPixelWidth, ebmlPixelHeight:
+	default:
+		return nil
+	}
+
+	// Value ignored if too large
+	if size > ebmlValueMax {
+		return nil
+	}
+
+	buf, err := readAt(e.r, offset, size)
+	if err != nil {
+		return err
+	}
+
+	switch id {
+	case ebmlDocType:
+		if docType := cleanText(string(buf)); docType == "webm" {
+			e.infos.set(FORMAT, docType)
+		}
+
+	case ebmlTimecodeScale:
+		if scale := readUint(buf); scale > 0 {
+			e.timecodeScale = scale
+		}
+
+	case ebmlDuration:
+		switch len(buf) {
+		case 4:
+			e.duration = float64(math.Float32frombits(binary.BigEndian.Uint32(buf)))
+		case 8:
+			e.duration = math.Float64frombits(binary.BigEndian.Uint64(buf))
+		}
+
+	case ebmlDateUTC:
+		e.infos.setDate(ebmlEpoch.Add(time.Duration(int64(readUint(buf)))))
+
+	case ebmlTitle:
+		e.infos.set(TITLE, strings.TrimSpace(string(buf)))
+
+	case ebmlPixelWidth:
+		e.infos.setInt(WIDTH, int64(readUint(buf)))
+
+	case ebmlPixelHeight:
+		e.infos.setInt(HEIGHT, int64(readUint(buf)))
+	}
+
+	return nil
+}
+
+// Returns the variable size integer: the marker bit is kept for the
+// element ids, the unknown sizes are set to the maximum value
+func readVint(buf []byte, isId bool) (value uint64, length int, err error) {
+
+	if len(buf) == 0 || buf[0] == 0 {
+		err = fmt.Errorf("invalid EBML variable size integer")
+		return
+	}
+
+	// Length given by the leading zeros
+	length = 1
+	for mask := byte(0x80); buf[0]&mask == 0; mask >>= 1 {
+		length++
+	}
+
+	if length > len(buf) || (isId && length > 4) {
+		err = fmt.Errorf("invalid EBML variable size integer")
+		return
+	}
+
+	value = uint64(buf[0])
+	if isId == false {
+		value &= uint64(0xFF >> uint(length))
+	}
+
+	allOnes := value == uint64(0xFF>>uint(length))
+	for _, b := range buf[1:length] {
+		value = value<<8 | uint64(b)
+		allOnes = allOnes && b == 0xFF
+	}
+
+	if isId == false && allOnes {
+		value = math.MaxUint64
+	}
+
+	return
+}
+
+// Returns the big-endian unsigned integer
+func readUint(buf []byte) (value uint64) {
+	for _, b := range buf {
+		value = value<<8 | uint64(b)
+	}
+	return
+}
@@ -0,0 +1,253 @@
+package metadata
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// EXIF dates have no timezone: they are considered as UTC
+const EXIF_DATE_FORMAT = "2006:01:02 15:04:05"
+
+// TIFF tags handled
+const (
+	tagImageWidth       = 0x0100
+	tagImageLength      = 0x0101
+	tagMake             = 0x010F
+	tagModel            = 0x0110
+	tagOrientation      = 0x0112
+	tagDateTime         = 0x0132
+	tagExifIFD          = 0x8769
+	tagDateTimeOriginal = 0x9003
+	tagPixelXDimension  = 0xA002
+	tagPixelYDimension  = 0xA003
+)
+
+// Size of the TIFF field types
+var tiffTypeSizes = map[uint16]uint32{
+	1:  1, // BYTE
+	2:  1, // ASCII
+	3:  2, // SHORT
+	4:  4, // LONG
+	5:  8, // RATIONAL
+	7:  1, // UNDEFINED
+	9:  4, // SLONG
+	10: 8, // SRATIONAL
+}
+
+// Maximum number of entries read by IFD & size of the values read
+const (
+	tiffEntriesMax = 1024
+	tiffValueMax   = 1 << 16
+)
+
+// Read the JPEG markers until the image data: the EXIF are stored in the
+// APP1 segment, the dimensions in the start of frame
+func readJPEG(r Reader, infos Infos) error {
+
+	offset := int64(2)
+
+	for {
+		header, err := readAt(r, offset, 4)
+		if err != nil || len(header) < 4 {
+			return err
+		}
+
+		if header[0] != 0xFF {
+			return fmt.Errorf("invalid JPEG marker at %d", offset)
+		}
+
+		marker := header[1]
+		length := int64(binary.BigEndian.Uint16(header[2:]))
+		if length < 2 {
+			return fmt.Errorf("invalid JPEG segment length at %d", offset)
+		}
+
+		switch {
+		// Start of scan & end of image
+		case marker == 0xDA || marker == 0xD9:
+			return nil
+
+		// APP1
+		case marker == 0xE1:
+			segment, err := readAt(r, offset+4, length-2)
+			if err != nil {
+				return err
+			}
+
+			if bytes.HasPrefix(segment, []byte("Exif\x00\x00")) {
+				if err := readExif(bytes.NewReader(segment[6:]), infos); err != nil {
+					return err
+				}
+			}
+
+		// Start of frame (except DHT, JPG & DAC markers)
+		case marker >= 0xC0 && marker <= 0xCF &&
+			marker != 0xC4 && marker != 0xC8 && marker != 0xCC:
+			frame, err := readAt(r, offset+4, 5)
+			if err != nil || len(frame) < 5 {
+				return err
+			}
+
+			infos.setInt(HEIGHT, int64(binary.BigEndian.Uint16(frame[1:])))
+			infos.setInt(WIDTH, int64(binary.BigEndian.Uint16(frame[3:])))
+		}
+
+		offset += 2 + length
+	}
+}
+
+func readTIFF(r Reader, infos Infos) error {
+	return readExif(r, infos)
+}
+
+type tiffReader struct {
+	r     io.ReaderAt
+	order binary.ByteOrder
+}
+
+// Read the IFD0 & EXIF IFD of the TIFF structure
+func readExif(r io.ReaderAt, infos Infos) error {
+
+	header, err := readAt(r, 0, 8)
+	if err != nil || len(header) < 8 {
+		return fmt.Errorf("invalid TIFF header")
+	}
+
+	t := &tiffReader{r: r}
+
+	switch string(header[:2]) {
+	case "II":
+		t.order = binary.LittleEndian
+	case "MM":
+		t.order = binary.BigEndian
+	default:
+		return fmt.Errorf("invalid TIFF byte order")
+	}
+
+	values, err := t.readIFD(int64(t.order.Uint32(header[4:])))
+	if err != nil {
+		return err
+	}
+
+	if offset, ok := values[tagExifIFD]; ok {
+
+		exif, err := t.readIFD(int64(t.toUint(offset)))
+		if err != nil {
+			return err
+		}
+
+		for tag, value := range exif {
+			values[tag] = value
+		}
+	}
+
+	infos.set(MAKE, t.toString(values[tagMake]))
+	infos.set(MODEL, t.toString(values[tagModel]))
+	infos.setInt(ORIENTATION, int64(t.toUint(values[tagOrientation])))
+
+	for _, tag := range []uint16{tagDateTimeOriginal, tagDateTime} {
+		date, err := time.Parse(EXIF_DATE_FORMAT, t.toString(values[tag]))
+		if err == nil {
+			infos.setDate(date)
+		}
+	}
+
+	for _, tag := range []uint16{tagPixelXDimension, tagImageWidth} {
+		infos.setInt(WIDTH, int64(t.toUint(values[tag])))
+	}
+
+	for _, tag := range []uint16{tagPixelYDimension, tagImageLength} {
+		infos.setInt(HEIGHT, int64(t.toUint(values[tag])))
+	}
+
+	return nil
+}
+
+type tiffValue struct {
+	typ  uint16
+	data []byte
+}
+
+// Returns the values of the IFD entries
+func (t *tiffReader) readIFD(offset int64) (values map[uint16]*tiffValue, err error) {
+
+	buf, err := readAt(t.r, offset, 2)
+	if err != nil || len(buf) < 2 {
+		return nil, fmt.Errorf("invalid TIFF IFD at %d", offset)
+	}
+
+	count := int64(t.order.Uint16(buf))
+	if count > tiffEntriesMax {
+		return nil, fmt.Errorf("too many TIFF IFD entries at %d", offset)
+	}
+
+	entries, err := readAt(t.r, offset+2, count*12)
+	if err != nil || int64(len(entries)) < count*12 {
+		return nil, fmt.Errorf("invalid TIFF IFD entries at %d", offset)
+	}
+
+	values = make(map[uint16]*tiffValue)
+
+	for idx := int64(0); idx < count; idx++ {
+
+		entry := entries[idx*12 : (idx+1)*12]
+
+		tag := t.order.Uint16(entry)
+		typ := t.order.Uint16(entry[2:])
+
+		size, ok := tiffTypeSizes[typ]
+		if ok == false {
+			continue
+		}
+
+		total := uint64(size) * uint64(t.order.Uint32(entry[4:]))
+		if total > tiffValueMax {
+			continue
+		}
+		size = uint32(total)
+
+		// Value stored in the entry if small enough
+		var data []byte
+		if size <= 4 {
+			data = entry[8 : 8+size]
+		} else if data, err = readAt(t.r, int64(t.order.Uint32(entry[8:])), int64(size)); err != nil {
+			return
+		}
+
+		values[tag] = &tiffValue{
+			typ:  typ,
+			data: data,
+		}
+	}
+
+	return
+}
+
+func (t *tiffReader) toString(value *tiffValue) string {
+
+	if value == nil || value.typ != 2 {
+		return ""
+	}
+
+	return strings.TrimSpace(strings.TrimRight(string(value.data), "\x00"))
+}
+
+func (t *tiffReader) toUint(value *tiffValue) uint32 {
+
+	if value == nil {
+		return 0
+	}
+
+	switch {
+	case value.typ == 3 && len(value.data) >= 2:
+		return uint32(t.order.Uint16(value.data))
+	case value.typ == 4 && len(value.data) >= 4:
+		return t.order.Uint32(value.data)
+	}
+
+	return 0
+}
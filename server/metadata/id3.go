@@ -0,0 +1,257 @@
+package metadata
+
+import (
+	"encoding/binary"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+// ID3 genres referenced by their index
+var ID3_GENRES = []string{
+	"Blues", "Classic Rock", "Country", "Dance", "Disco", "Funk", "Grunge",
+	"Hip-Hop", "Jazz", "Metal", "New Age", "Oldies", "Other", "Pop", "R&B",
+	"Rap", "Reggae", "Rock", "Techno", "Industrial", "Alternative", "Ska",
+	"Death Metal", "Pranks", "Soundtrack", "Euro-Techno", "Ambient",
+	"Trip-Hop", "Vocal", "Jazz+Funk", "Fusion", "Trance", "Classical",
+	"Instrumental", "Acid", "House", "Game", "Sound Clip", "Gospel", "Noise",
+	"AlternRock", "Bass", "Soul", "Punk", "Space", "Meditative",
+	"Instrumental Pop", "Instrumental Rock", "Ethnic", "Gothic", "Darkwave",
+	"Techno-Industrial", "Electronic", "Pop-Folk", "Eurodance", "Dream",
+	"Southern Rock", "Comedy", "Cult", "Gangsta", "Top 40", "Christian Rap",
+	"Pop/Funk", "Jungle", "Native American", "Cabaret", "New Wave",
+	"Psychadelic", "Rave", "Showtunes", "Trailer", "Lo-Fi", "Tribal",
+	"Acid Punk", "Acid Jazz", "Polka", "Retro", "Musical", "Rock & Roll",
+	"Hard Rock",
+}
+
+// ID3v2 text frames handled (v2.2 & v2.3/v2.4 identifiers)
+var id3Frames = map[string]string{
+	"TT2":  TITLE,
+	"TIT2": TITLE,
+	"TP1":  ARTIST,
+	"TPE1": ARTIST,
+	"TAL":  ALBUM,
+	"TALB": ALBUM,
+	"TYE":  YEAR,
+	"TYER": YEAR,
+	"TDRC": YEAR,
+	"TRK":  TRACK,
+	"TRCK": TRACK,
+	"TCO":  GENRE,
+	"TCON": GENRE,
+	"TLE":  DURATION,
+	"TLEN": DURATION,
+}
+
+var reID3Genre = regexp.MustCompile(`^\((\d+)\)(.*)$`)
+
+// Maximum size of the ID3v2 tag read (ie. without the large pictures)
+const id3TagMax = 1 << 20
+
+// Read the ID3v2 tag at the beginning of the file and the ID3v1 tag at
+// the end: the ID3v2 values have priority
+func readMP3(r Reader, infos Infos) error {
+
+	if err := readID3v2(r, infos); err != nil {
+		return err
+	}
+
+	return readID3v1(r, infos)
+}
+
+func readID3v2(r Reader, infos Infos) error {
+
+	header, err := readAt(r, 0, 10)
+	if err != nil || len(header) < 10 || string(header[:3]) != "ID3" {
+		return err
+	}
+
+	version := header[3]
+	flags := header[5]
+	size := int64(syncsafe(header[6:10]))
+	if size > id3TagMax {
+		size = id3TagMax
+	}
+
+	tag, err := readAt(r, 10, size)
+	if err != nil {
+		return err
+	}
+
+	// Skip the extended header
+	if flags&0x40 != 0 && len(tag) >= 4 {
+		switch version {
+		case 3:
+			tag = skip(tag, 4+int(binary.BigEndian.Uint32(tag)))
+		case 4:
+			tag = skip(tag, int(syncsafe(tag[:4])))
+		}
+	}
+
+	idSize, headerSize := 4, 10
+	if version == 2 {
+		idSize, headerSize = 3, 6
+	}
+
+	for len(tag) >= headerSize && tag[0] != 0 {
+
+		id := string(tag[:idSize])
+
+		var frameSize int
+		switch version {
+		case 2:
+			frameSize = int(tag[3])<<16 | int(tag[4])<<8 | int(tag[5])
+		case 3:
+			frameSize = int(binary.BigEndian.Uint32(tag[4:]))
+		default:
+			frameSize = int(syncsafe(tag[4:8]))
+		}
+
+		if frameSize < 0 || headerSize+frameSize > len(tag) {
+			break
+		}
+
+		if key, ok := id3Frames[id]; ok {
+			setID3Value(infos, key, decodeID3Text(tag[headerSize:headerSize+frameSize]))
+		}
+
+		tag = tag[headerSize+frameSize:]
+	}
+
+	return nil
+}
+
+func readID3v1(r Reader, infos Infos) error {
+
+	end, err := r.Seek(0, 2)
+	if err != nil || end < 128 {
+		return err
+	}
+
+	tag, err := readAt(r, end-128, 128)
+	if err != nil || len(tag) < 128 || string(tag[:3]) != "TAG" {
+		return err
+	}
+
+	infos.set(TITLE, decodeLatin1(tag[3:33]))
+	infos.set(ARTIST, decodeLatin1(tag[33:63]))
+	infos.set(ALBUM, decodeLatin1(tag[63:93]))
+	infos.set(YEAR, decodeLatin1(tag[93:97]))
+
+	// ID3v1.1 track number
+	if tag[125] == 0 && tag[126] != 0 {
+		infos.setInt(TRACK, int64(tag[126]))
+	}
+
+	if int(tag[127]) < len(ID3_GENRES) {
+		infos.set(GENRE, ID3_GENRES[tag[127]])
+	}
+
+	return nil
+}
+
+// Set the ID3v2 value normalized
+func setID3Value(infos Infos, key string, value string) {
+
+	switch key {
+	case YEAR:
+		if len(value) > 4 {
+			value = value[:4]
+		}
+
+	case GENRE:
+		// Genre referenced by its index: (17) or 17
+		if res := reID3Genre.FindStringSubmatch(value); len(res) > 2 {
+			value = strings.TrimSpace(res[2])
+			if value == "" {
+				value = res[1]
+			}
+		}
+
+		if idx, err := strconv.Atoi(value); err == nil && idx >= 0 && idx < len(ID3_GENRES) {
+			value = ID3_GENRES[idx]
+		}
+
+	case DURATION:
+		// Length in milliseconds
+		ms, err := strconv.ParseInt(value, 10, 64)
+		if err == nil {
+			infos.setDuration(float64(ms) / 1000)
+		}
+		return
+	}
+
+	infos.set(key, value)
+}
+
+// Returns the 28 bits integer stored in 4 bytes
+func syncsafe(buf []byte) uint32 {
+	return uint32(buf[0]&0x7F)<<21 | uint32(buf[1]&0x7F)<<14 |
+		uint32(buf[2]&0x7F)<<7 | uint32(buf[3]&0x7F)
+}
+
+func skip(buf []byte, size int) []byte {
+	if size < 0 || size > len(buf) {
+		return nil
+	}
+	return buf[size:]
+}
+
+// Decode the text frame with its encoding: ISO-8859-1, UTF-16 with BOM,
+// UTF-16BE or UTF-8
+func decodeID3Text(frame []byte) string {
+
+	if len(frame) < 1 {
+		return ""
+	}
+
+	text := frame[1:]
+
+	switch frame[0] {
+	case 0:
+		return decodeLatin1(text)
+
+	case 1, 2:
+		var order binary.ByteOrder = binary.BigEndian
+		if len(text) >= 2 {
+			switch {
+			case text[0] == 0xFF && text[1] == 0xFE:
+				order, text = binary.LittleEndian, text[2:]
+			case text[0] == 0xFE && text[1] == 0xFF:
+				text = text[2:]
+			}
+		}
+
+		chars := make([]uint16, 0, len(text)/2)
+		for idx := 0; idx+1 < len(text); idx += 2 {
+			chars = append(chars, order.Uint16(text[idx:]))
+		}
+
+		return cleanText(string(utf16.Decode(chars)))
+
+	default:
+		return cleanText(string(text))
+	}
+}
+
+func decodeLatin1(buf []byte) string {
+
+	runes := make([]rune, len(buf))
+	for idx, b := range buf {
+		runes[idx] = rune(b)
+	}
+
+	return cleanText(string(runes))
+}
+
+// Keep only the first string
+func cleanText(text string) string {
+
+	if idx := strings.IndexRune(text, 0); idx >= 0 {
+		text = text[:idx]
+	}
+
+	return strings.TrimSpace(text)
+}
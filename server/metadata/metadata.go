@@ -0,0 +1,148 @@
+package metadata
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"time"
+)
+
+// Normalized keys of the metadata extracted
+const (
+	FORMAT      = "format"
+	WIDTH       = "width"
+	HEIGHT      = "height"
+	DURATION    = "duration" // in seconds
+	CREATED     = "created"  // RFC 3339 date
+	MAKE        = "make"
+	MODEL       = "model"
+	ORIENTATION = "orientation"
+	TITLE       = "title"
+	ARTIST      = "artist"
+	ALBUM       = "album"
+	YEAR        = "year"
+	TRACK       = "track"
+	GENRE       = "genre"
+)
+
+var ErrUnknownFormat = errors.New("unknown metadata format")
+
+// Reader of the file analysed
+type Reader interface {
+	io.Reader
+	io.Seeker
+	io.ReaderAt
+}
+
+type Infos map[string]string
+
+// Set the value if not empty & not already set
+func (i Infos) set(key string, value string) {
+
+	if value == "" {
+		return
+	}
+
+	if _, ok := i[key]; ok == false {
+		i[key] = value
+	}
+}
+
+func (i Infos) setInt(key string, value int64) {
+	if value > 0 {
+		i.set(key, strconv.FormatInt(value, 10))
+	}
+}
+
+func (i Infos) setDuration(seconds float64) {
+	if seconds > 0 {
+		i.set(DURATION, strconv.FormatFloat(
+			math.Round(seconds*1000)/1000, 'f', -1, 64))
+	}
+}
+
+func (i Infos) setDate(date time.Time) {
+	if date.IsZero() == false {
+		i.set(CREATED, date.UTC().Format(time.RFC3339))
+	}
+}
+
+type extractor func(r Reader, infos Infos) error
+
+// Extract returns the metadata of the file: ErrUnknownFormat is returned
+// if the file format is not handled
+func Extract(path string) (Infos, error) {
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return Read(f)
+}
+
+// Read returns the metadata of the content
+func Read(r Reader) (infos Infos, err error) {
+
+	header := make([]byte, 12)
+	n, err := r.ReadAt(header, 0)
+	if err != nil && err != io.EOF {
+		return
+	}
+	header = header[:n]
+
+	var extract extractor
+	var format string
+
+	switch {
+	case bytes.HasPrefix(header, []byte{0xFF, 0xD8, 0xFF}):
+		extract, format = readJPEG, "jpeg"
+
+	case bytes.HasPrefix(header, []byte("II*\x00")),
+		bytes.HasPrefix(header, []byte("MM\x00*")):
+		extract, format = readTIFF, "tiff"
+
+	case bytes.HasPrefix(header, []byte{0x1A, 0x45, 0xDF, 0xA3}):
+		extract, format = readEBML, "matroska"
+
+	case len(header) >= 8 && isAtom(string(header[4:8])):
+		extract, format = readMP4, "mp4"
+
+	case bytes.HasPrefix(header, []byte("ID3")),
+		len(header) >= 2 && header[0] == 0xFF && header[1]&0xE0 == 0xE0:
+		extract, format = readMP3, "mp3"
+
+	default:
+		return nil, ErrUnknownFormat
+	}
+
+	infos = make(Infos)
+
+	if err = extract(r, infos); err != nil {
+		return nil, err
+	}
+
+	infos.set(FORMAT, format)
+	return
+}
+
+// Returns a maximum of size bytes from the offset specified
+func readAt(r io.ReaderAt, offset int64, size int64) ([]byte, error) {
+
+	if size < 0 {
+		return nil, fmt.Errorf("invalid size %d at %d", size, offset)
+	}
+
+	buf := make([]byte, size)
+	n, err := r.ReadAt(buf, offset)
+	if err == io.EOF && n > 0 {
+		err = nil
+	}
+
+	return buf[:n], err
+}
@@ -0,0 +1,372 @@
+package metadata
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type tiffEntry struct {
+	tag   uint16
+	typ   uint16
+	count uint32
+	data  []byte
+}
+
+func tiffString(tag uint16, value string) tiffEntry {
+	return tiffEntry{tag, 2, uint32(len(value) + 1), []byte(value + "\x00")}
+}
+
+func tiffShort(order binary.ByteOrder, tag uint16, value uint16) tiffEntry {
+	data := make([]byte, 2)
+	order.PutUint16(data, value)
+	return tiffEntry{tag, 3, 1, data}
+}
+
+func tiffLong(order binary.ByteOrder, tag uint16, value uint32) tiffEntry {
+	data := make([]byte, 4)
+	order.PutUint32(data, value)
+	return tiffEntry{tag, 4, 1, data}
+}
+
+// Returns the TIFF structure with the IFD0 & the EXIF IFD
+func buildTIFF(order binary.ByteOrder, ifd0 []tiffEntry, exif []tiffEntry) []byte {
+
+	ifdSize := func(entries int) int { return 2 + 12*entries + 4 }
+
+	exifOffset := 8 + ifdSize(len(ifd0)+1)
+	dataOffset := exifOffset + ifdSize(len(exif))
+
+	ifd0 = append(ifd0, tiffLong(order, tagExifIFD, uint32(exifOffset)))
+
+	var ifds, values bytes.Buffer
+
+	for _, entries := range [][]tiffEntry{ifd0, exif} {
+
+		binary.Write(&ifds, order, uint16(len(entries)))
+
+		for _, entry := range entries {
+			binary.Write(&ifds, order, entry.tag)
+			binary.Write(&ifds, order, entry.typ)
+			binary.Write(&ifds, order, entry.count)
+
+			if len(entry.data) > 4 {
+				binary.Write(&ifds, order, uint32(dataOffset+values.Len()))
+				values.Write(entry.data)
+			} else {
+				ifds.Write(append(entry.data, make([]byte, 4-len(entry.data))...))
+			}
+		}
+
+		binary.Write(&ifds, order, uint32(0))
+	}
+
+	header := []byte("II*\x00")
+	if order == binary.BigEndian {
+		header = []byte("MM\x00*")
+	}
+
+	buf := bytes.NewBuffer(header)
+	binary.Write(buf, order, uint32(8))
+	buf.Write(ifds.Bytes())
+	buf.Write(values.Bytes())
+	return buf.Bytes()
+}
+
+func buildJPEG() []byte {
+
+	order := binary.LittleEndian
+
+	exif := append([]byte("Exif\x00\x00"), buildTIFF(order,
+		[]tiffEntry{
+			tiffString(tagMake, "Canon"),
+			tiffString(tagModel, "EOS"),
+			tiffShort(order, tagOrientation, 6),
+			tiffString(tagDateTime, "2013:01:01 10:00:00"),
+		},
+		[]tiffEntry{
+			tiffString(tagDateTimeOriginal, "2012:09:28 20:15:00"),
+			tiffShort(order, tagPixelXDimension, 640),
+			tiffLong(order, tagPixelYDimension, 480),
+		})...)
+
+	buf := bytes.NewBuffer([]byte{0xFF, 0xD8})
+
+	// APP0
+	buf.Write([]byte{0xFF, 0xE0, 0x00, 0x04, 0x00, 0x00})
+
+	// APP1
+	buf.Write([]byte{0xFF, 0xE1})
+	binary.Write(buf, binary.BigEndian, uint16(len(exif)+2))
+	buf.Write(exif)
+
+	// Start of frame & scan
+	buf.Write([]byte{0xFF, 0xC0, 0x00, 0x0B, 0x08, 0x00, 0x32, 0x00, 0x64,
+		0x01, 0x01, 0x11, 0x00})
+	buf.Write([]byte{0xFF, 0xDA, 0x00, 0x02})
+
+	return buf.Bytes()
+}
+
+func id3Frame(id string, text []byte) []byte {
+	buf := bytes.NewBufferString(id)
+	binary.Write(buf, binary.BigEndian, uint32(len(text)))
+	buf.Write([]byte{0, 0})
+	buf.Write(text)
+	return buf.Bytes()
+}
+
+func buildMP3() []byte {
+
+	var frames bytes.Buffer
+
+	// UTF-16 with BOM
+	title := []byte{1, 0xFF, 0xFE}
+	for _, c := range "Looper" {
+		title = append(title, byte(c), 0)
+	}
+
+	frames.Write(id3Frame("TIT2", title))
+	frames.Write(id3Frame("TPE1", []byte("\x00Rian Johnson")))
+	frames.Write(id3Frame("TCON", []byte("\x00(17)")))
+	frames.Write(id3Frame("TLEN", []byte("\x03133000")))
+	frames.Write(id3Frame("TYER", []byte("\x002012")))
+
+	size := frames.Len()
+	buf := bytes.NewBufferString("ID3")
+	buf.Write([]byte{3, 0, 0,
+		byte(size >> 21 & 0x7F), byte(size >> 14 & 0x7F),
+		byte(size >> 7 & 0x7F), byte(size & 0x7F)})
+	buf.Write(frames.Bytes())
+
+	// Audio frames
+	buf.Write([]byte{0xFF, 0xFB, 0x90, 0x00})
+	buf.Write(make([]byte, 100))
+
+	// ID3v1.1
+	v1 := make([]byte, 128)
+	copy(v1, "TAG")
+	copy(v1[3:], "Ignored")
+	copy(v1[63:], "Looper OST")
+	v1[126] = 5
+	v1[127] = 0
+	buf.Write(v1)
+
+	return buf.Bytes()
+}
+
+func atom(typ string, contents ...[]byte) []byte {
+	content := bytes.Join(contents, nil)
+	buf := new(bytes.Buffer)
+	binary.Write(buf, binary.BigEndian, uint32(8+len(content)))
+	buf.WriteString(typ)
+	buf.Write(content)
+	return buf.Bytes()
+}
+
+func buildMP4(brand string) []byte {
+
+	created := time.Date(2012, 9, 28, 0, 0, 0, 0, time.UTC).Sub(mp4Epoch)
+
+	mvhd := make([]byte, 100)
+	binary.BigEndian.PutUint32(mvhd[4:], uint32(created/time.Second))
+	binary.BigEndian.PutUint32(mvhd[12:], 1000)
+	binary.BigEndian.PutUint32(mvhd[16:], 133500)
+
+	video := make([]byte, 84)
+	binary.BigEndian.PutUint32(video[76:], 1920<<16)
+	binary.BigEndian.PutUint32(video[80:], 1080<<16)
+
+	// Version 1 audio track without dimensions
+	audio := make([]byte, 96)
+	audio[0] = 1
+
+	return bytes.Join([][]byte{
+		atom("ftyp", []byte(brand), make([]byte, 4)),
+		atom("moov",
+			atom("mvhd", mvhd),
+			atom("trak", atom("tkhd", audio)),
+			atom("trak", atom("tkhd", video))),
+		atom("mdat", make([]byte, 16)),
+	}, nil)
+}
+
+func ebmlElement(id uint64, contents ...[]byte) []byte {
+
+	content := bytes.Join(contents, nil)
+
+	var buf bytes.Buffer
+	for shift := 24; shift >= 0; shift -= 8 {
+		if b := byte(id >> uint(shift)); b != 0 || buf.Len() > 0 {
+			buf.WriteByte(b)
+		}
+	}
+
+	// 8 bytes size
+	size := make([]byte, 8)
+	binary.BigEndian.PutUint64(size, uint64(len(content)))
+	size[0] = 0x01
+	buf.Write(size)
+
+	buf.Write(content)
+	return buf.Bytes()
+}
+
+func ebmlUint(value uint64) []byte {
+	buf := make([]byte, 8)
+	binary.BigEndian.PutUint64(buf, value)
+	return buf
+}
+
+func buildMKV() []byte {
+
+	duration := make([]byte, 8)
+	binary.BigEndian.PutUint64(duration, math.Float64bits(133500))
+
+	date := time.Date(2012, 9, 28, 0, 0, 0, 0, time.UTC).Sub(ebmlEpoch)
+
+	segment := bytes.Join([][]byte{
+		ebmlElement(ebmlInfo,
+			ebmlElement(ebmlTimecodeScale, ebmlUint(1000000)),
+			ebmlElement(ebmlDuration, duration),
+			ebmlElement(ebmlDateUTC, ebmlUint(uint64(date))),
+			ebmlElement(ebmlTitle, []byte("Looper"))),
+		ebmlElement(ebmlTracks,
+			ebmlElement(ebmlTrackEntry,
+				ebmlElement(ebmlVideo,
+					ebmlElement(ebmlPixelWidth, []byte{0x05, 0x00}),
+					ebmlElement(ebmlPixelHeight, []byte{0x02, 0xD0})))),
+		ebmlElement(ebmlCluster, make([]byte, 32)),
+	}, nil)
+
+	return bytes.Join([][]byte{
+		ebmlElement(ebmlHeader, ebmlElement(ebmlDocType, []byte("matroska"))),
+
+		// Segment with an unknown size
+		append([]byte{0x18, 0x53, 0x80, 0x67, 0x01, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF}, segment...),
+	}, nil)
+}
+
+func TestRead(t *testing.T) {
+
+	assert := assert.New(t)
+
+	for name, test := range map[string]struct {
+		src      []byte
+		expected Infos
+	}{
+		"jpeg": {buildJPEG(), Infos{
+			FORMAT:      "jpeg",
+			MAKE:        "Canon",
+			MODEL:       "EOS",
+			ORIENTATION: "6",
+			CREATED:     "2012-09-28T20:15:00Z",
+			WIDTH:       "640",
+			HEIGHT:      "480",
+		}},
+		"tiff": {buildTIFF(binary.BigEndian,
+			[]tiffEntry{
+				tiffLong(binary.BigEndian, tagImageWidth, 3000),
+				tiffShort(binary.BigEndian, tagImageLength, 2000),
+			}, nil), Infos{
+			FORMAT: "tiff",
+			WIDTH:  "3000",
+			HEIGHT: "2000",
+		}},
+		"mp3": {buildMP3(), Infos{
+			FORMAT:   "mp3",
+			TITLE:    "Looper",
+			ARTIST:   "Rian Johnson",
+			ALBUM:    "Looper OST",
+			YEAR:     "2012",
+			TRACK:    "5",
+			GENRE:    "Rock",
+			DURATION: "133",
+		}},
+		"mp4": {buildMP4("isom"), Infos{
+			FORMAT:   "mp4",
+			CREATED:  "2012-09-28T00:00:00Z",
+			DURATION: "133.5",
+			WIDTH:    "1920",
+			HEIGHT:   "1080",
+		}},
+		"mov": {buildMP4("qt  "), Infos{
+			FORMAT:   "mov",
+			CREATED:  "2012-09-28T00:00:00Z",
+			DURATION: "133.5",
+			WIDTH:    "1920",
+			HEIGHT:   "1080",
+		}},
+		"mkv": {buildMKV(), Infos{
+			FORMAT:   "matroska",
+			CREATED:  "2012-09-28T00:00:00Z",
+			DURATION: "133.5",
+			TITLE:    "Looper",
+			WIDTH:    "1280",
+			HEIGHT:   "720",
+		}},
+	} {
+		infos, err := Read(bytes.NewReader(test.src))
+		if assert.Nil(err, name) {
+			assert.Equal(test.expected, infos, name)
+		}
+	}
+
+	_, err := Read(bytes.NewReader([]byte("plain text")))
+	assert.Equal(ErrUnknownFormat, err)
+
+	// Truncated files
+	for _, src := range [][]byte{buildJPEG()[:40], buildMP4("isom")[:60]} {
+		_, err = Read(bytes.NewReader(src))
+		assert.NotNil(err)
+	}
+}
+
+func TestReadTruncatedMKV(t *testing.T) {
+
+	assert := assert.New(t)
+
+	src := buildMKV()
+
+	// Element header running past its parent
+	truncated := append([]byte{}, src...)
+	truncated[11] = 0x05
+	_, err := Read(bytes.NewReader(truncated))
+	assert.NotNil(err)
+
+	// Files truncated anywhere
+	for size := 4; size < len(src); size++ {
+		assert.NotPanics(func() {
+			Read(bytes.NewReader(src[:size]))
+		}, size)
+	}
+}
+
+func TestExtract(t *testing.T) {
+
+	assert := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "metadata")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "Looper.mkv")
+	assert.Nil(ioutil.WriteFile(path, buildMKV(), 0644))
+
+	infos, err := Extract(path)
+	if assert.Nil(err) {
+		assert.Equal("1280", infos[WIDTH])
+	}
+
+	_, err = Extract(filepath.Join(dir, "unknown"))
+	assert.NotNil(err)
+}
@@ -0,0 +1,183 @@
+package metadata
+
+import (
+	"encoding/binary"
+	"fmt"
+	"time"
+)
+
+// Top level atoms used to detect the MP4/MOV files
+var mp4Atoms = map[string]struct{}{
+	"ftyp": struct{}{},
+	"moov": struct{}{},
+	"mdat": struct{}{},
+	"free": struct{}{},
+	"skip": struct{}{},
+	"wide": struct{}{},
+	"pnot": struct{}{},
+}
+
+// Containers read to find the movie & track headers
+var mp4Containers = map[string]struct{}{
+	"moov": struct{}{},
+	"trak": struct{}{},
+}
+
+// Dates are stored in seconds since 1904
+var mp4Epoch = time.Date(1904, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// Maximum depth of the atoms read
+const mp4DepthMax = 8
+
+func isAtom(typ string) bool {
+	_, ok := mp4Atoms[typ]
+	return ok
+}
+
+func readMP4(r Reader, infos Infos) error {
+
+	end, err := r.Seek(0, 2)
+	if err != nil {
+		return err
+	}
+
+	return readAtoms(r, infos, 0, end, 0)
+}
+
+// Read the atoms between the offsets specified
+func readAtoms(r Reader, infos Infos, offset int64, end int64, depth int) error {
+
+	if depth > mp4DepthMax {
+		return nil
+	}
+
+	for offset+8 <= end {
+
+		header, err := readAt(r, offset, 16)
+		if err != nil || len(header) < 8 {
+			return err
+		}
+
+		size := int64(binary.BigEndian.Uint32(header))
+		typ := string(header[4:8])
+		headerSize := int64(8)
+
+		switch size {
+		// Atom until the end
+		case 0:
+			size = end - offset
+
+		// 64 bits size
+		case 1:
+			if len(header) < 16 {
+				return fmt.Errorf("invalid MP4 atom '%s' size", typ)
+			}
+			size = int64(binary.BigEndian.Uint64(header[8:]))
+			headerSize = 16
+		}
+
+		if size < headerSize || offset+size > end {
+			return fmt.Errorf("invalid MP4 atom '%s' size %d", typ, size)
+		}
+
+		content := offset + headerSize
+
+		switch typ {
+		case "ftyp":
+			brand, err := readAt(r, content, 4)
+			if err != nil {
+				return err
+			}
+
+			if string(brand) == "qt  " {
+				infos.set(FORMAT, "mov")
+			}
+
+		case "mvhd":
+			if err := readMovieHeader(r, infos, content); err != nil {
+				return err
+			}
+
+		case "tkhd":
+			if err := readTrackHeader(r, infos, content); err != nil {
+				return err
+			}
+
+		default:
+			if _, ok := mp4Containers[typ]; ok {
+				if err := readAtoms(r, infos, content, offset+size, depth+1); err != nil {
+					return err
+				}
+			}
+		}
+
+		offset += size
+	}
+
+	return nil
+}
+
+// Read the creation date & the duration of the movie
+func readMovieHeader(r Reader, infos Infos, offset int64) error {
+
+	buf, err := readAt(r, offset, 32)
+	if err != nil || len(buf) < 20 {
+		return fmt.Errorf("invalid MP4 movie header")
+	}
+
+	var created, duration uint64
+	var timescale uint32
+
+	// Version 1 uses 64 bits dates & duration
+	if buf[0] == 1 {
+		if len(buf) < 32 {
+			return fmt.Errorf("invalid MP4 movie header")
+		}
+		created = binary.BigEndian.Uint64(buf[4:])
+		timescale = binary.BigEndian.Uint32(buf[20:])
+		duration = binary.BigEndian.Uint64(buf[24:])
+	} else {
+		created = uint64(binary.BigEndian.Uint32(buf[4:]))
+		timescale = binary.BigEndian.Uint32(buf[12:])
+		duration = uint64(binary.BigEndian.Uint32(buf[16:]))
+	}
+
+	if created > 0 {
+		infos.setDate(mp4Epoch.Add(time.Duration(created) * time.Second))
+	}
+
+	if timescale > 0 {
+		infos.setDuration(float64(duration) / float64(timescale))
+	}
+
+	return nil
+}
+
+// Read the dimensions of the first video track
+func readTrackHeader(r Reader, infos Infos, offset int64) error {
+
+	buf, err := readAt(r, offset, 96)
+	if err != nil || len(buf) < 84 {
+		return fmt.Errorf("invalid MP4 track header")
+	}
+
+	// Version 1 uses 64 bits dates & duration
+	dimensions := 76
+	if buf[0] == 1 {
+		dimensions = 88
+		if len(buf) < 96 {
+			return fmt.Errorf("invalid MP4 track header")
+		}
+	}
+
+	// Fixed-point 16.16 values: none for the audio tracks
+	width := binary.BigEndian.Uint32(buf[dimensions:]) >> 16
+	height := binary.BigEndian.Uint32(buf[dimensions+4:]) >> 16
+
+	if width > 0 && height > 0 {
+		infos.setInt(WIDTH, int64(width))
+		infos.setInt(HEIGHT, int64(height))
+	}
+
+	return nil
+}
@@ -157,6 +157,7 @@ func getRefsByValue(val reflect.Value) []*Ref {
 		case "string":
 		case "bool":
 		case "int":
+		case "float32":
 		case "uint64":
 			// nothing to do
 
@@ -0,0 +1,296 @@
+package requests
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+type CacheConfig struct {
+	Enable  bool   `json:"enable"`
+	Path    string `json:"path"`
+	TTL     string `json:"ttl"`
+	Offline bool   `json:"offline"`
+}
+
+// Default time to live of the cached responses
+const CACHE_DEFAULT_TTL = 24 * time.Hour
+
+// Query parameters ignored to identify the cached responses
+var CACHE_IGNORED_QUERIES = []string{"api_key"}
+
+// Response stored on disk
+type cacheEntry struct {
+	Key     string    `json:"key"`
+	Website string    `json:"website"`
+	Date    time.Time `json:"date"`
+	Status  int       `json:"status"`
+	Body    []byte    `json:"body"`
+
+	size int64
+}
+
+type CacheStats struct {
+	Entries int   `json:"entries"`
+	Expired int   `json:"expired"`
+	Size    int64 `json:"size"`
+	Hits    int   `json:"hits"`
+	Misses  int   `json:"misses"`
+}
+
+// Cache of the website responses: only the entries description is kept
+// in memory, the bodies are read from disk
+type Cache struct {
+	path    string
+	ttl     time.Duration
+	offline bool
+
+	entries map[string]*cacheEntry
+	stats   map[string]*CacheStats
+	mutex   sync.Mutex
+}
+
+// NewCache returns nil if the cache is disabled
+func NewCache(config CacheConfig) (c *Cache, err error) {
+
+	if config.Enable == false {
+		return
+	}
+
+	if config.Path == "" {
+		err = fmt.Errorf("cache path not specified")
+		return
+	}
+
+	ttl := CACHE_DEFAULT_TTL
+	if config.TTL != "" {
+		if ttl, err = time.ParseDuration(config.TTL); err != nil {
+			err = fmt.Errorf("invalid cache ttl '%s': %s", config.TTL, err.Error())
+			return
+		}
+	}
+
+	if err = os.MkdirAll(config.Path, 0755); err != nil {
+		return
+	}
+
+	c = &Cache{
+		path:    config.Path,
+		ttl:     ttl,
+		offline: config.Offline,
+		entries: make(map[string]*cacheEntry),
+		stats:   make(map[string]*CacheStats),
+	}
+
+	err = c.load()
+	return
+}
+
+// Retreive all the entries stored
+func (c *Cache) load() error {
+
+	files, err := ioutil.ReadDir(c.path)
+	if err != nil {
+		return err
+	}
+
+	for _, file := range files {
+
+		if file.IsDir() || filepath.Ext(file.Name()) != ".json" {
+			continue
+		}
+
+		entry, err := c.read(filepath.Join(c.path, file.Name()))
+		if err != nil {
+			log.Printf("cache entry '%s' ignored: %s\n", file.Name(), err.Error())
+			continue
+		}
+
+		entry.Body = nil
+		entry.size = file.Size()
+		c.entries[entry.Key] = entry
+	}
+
+	return nil
+}
+
+func (c *Cache) read(path string) (entry *cacheEntry, err error) {
+
+	src, err := ioutil.ReadFile(path)
+	if err != nil {
+		return
+	}
+
+	entry = new(cacheEntry)
+	err = json.Unmarshal(src, entry)
+	return
+}
+
+func (c *Cache) getPath(key string) string {
+	hash := sha1.Sum([]byte(key))
+	return filepath.Join(c.path, hex.EncodeToString(hash[:])+".json")
+}
+
+func (c *Cache) getStats(website string) *CacheStats {
+
+	stats, ok := c.stats[website]
+	if ok == false {
+		stats = new(CacheStats)
+		c.stats[website] = stats
+	}
+
+	return stats
+}
+
+// GetKey returns the key identifying the request
+func GetKey(website string, method string, rawUrl string, queries map[string]string) string {
+
+	values := url.Values{}
+	for key, value := range queries {
+		values.Add(key, value)
+	}
+
+	for _, key := range CACHE_IGNORED_QUERIES {
+		values.Del(key)
+	}
+
+	return website + " " + method + " " + rawUrl + "?" + values.Encode()
+}
+
+func (c *Cache) IsOffline() bool {
+	return c.offline
+}
+
+func (c *Cache) isExpired(entry *cacheEntry) bool {
+	return time.Since(entry.Date) > c.ttl
+}
+
+// Get returns the response stored if not expired: in offline mode the
+// expired responses are returned too
+func (c *Cache) Get(website string, key string) (status int, body []byte, ok bool) {
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	stats := c.getStats(website)
+
+	entry, ok := c.entries[key]
+	if ok == false || (c.offline == false && c.isExpired(entry)) {
+		stats.Misses++
+		ok = false
+		return
+	}
+
+	stored, err := c.read(c.getPath(key))
+	if err != nil {
+		log.Printf("cache entry '%s': %s\n", key, err.Error())
+		delete(c.entries, key)
+		stats.Misses++
+		ok = false
+		return
+	}
+
+	stats.Hits++
+	return stored.Status, stored.Body, true
+}
+
+// Set stores the response
+func (c *Cache) Set(website string, key string, status int, body []byte) error {
+
+	entry := &cacheEntry{
+		Key:     key,
+		Website: website,
+		Date:    time.Now(),
+		Status:  status,
+		Body:    body,
+	}
+
+	src, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if err = ioutil.WriteFile(c.getPath(key), src, 0644); err != nil {
+		return err
+	}
+
+	entry.Body = nil
+	entry.size = int64(len(src))
+	c.entries[key] = entry
+	return nil
+}
+
+// GetStats returns the cache statistics by website
+func (c *Cache) GetStats() map[string]*CacheStats {
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	result := make(map[string]*CacheStats)
+
+	for website, stats := range c.stats {
+		result[website] = &CacheStats{
+			Hits:   stats.Hits,
+			Misses: stats.Misses,
+		}
+	}
+
+	for _, entry := range c.entries {
+
+		stats, ok := result[entry.Website]
+		if ok == false {
+			stats = new(CacheStats)
+			result[entry.Website] = stats
+		}
+
+		stats.Entries++
+		stats.Size += entry.size
+
+		if c.isExpired(entry) {
+			stats.Expired++
+		}
+	}
+
+	return result
+}
+
+// Purge removes the entries of the website specified (all websites if
+// empty), only the expired ones if specified
+func (c *Cache) Purge(website string, expiredOnly bool) (removed int, err error) {
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	for key, entry := range c.entries {
+
+		if website != "" && strings.EqualFold(entry.Website, website) == false {
+			continue
+		}
+
+		if expiredOnly && c.isExpired(entry) == false {
+			continue
+		}
+
+		if err = os.Remove(c.getPath(key)); err != nil && os.IsNotExist(err) == false {
+			return
+		}
+		err = nil
+
+		delete(c.entries, key)
+		removed++
+	}
+
+	return
+}
@@ -0,0 +1,130 @@
+package requests
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCache(t *testing.T) {
+
+	assert := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "classify")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	received := 0
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			received++
+			w.Write([]byte(`{"page": "` + r.URL.Query().Get("page") + `"}`))
+		}))
+	defer server.Close()
+
+	config := CacheConfig{
+		Enable: true,
+		Path:   dir,
+		TTL:    "1h",
+	}
+
+	pool := New(2, false)
+	assert.Nil(pool.SetCache(config))
+
+	get := func(website string, page string, apiKey string) (string, error) {
+
+		var rsp struct {
+			Page string `json:"page"`
+		}
+
+		channel, err := SendRequest(&Request{
+			Website: website,
+			Method:  "GET",
+			Url:     server.URL + "/search",
+			Queries: map[string]string{
+				"page":    page,
+				"api_key": apiKey,
+			},
+		}, &rsp)
+		if err != nil {
+			return "", err
+		}
+
+		res, ok := <-channel
+		if assert.True(ok) {
+			assert.Equal(http.StatusOK, res.Status)
+		}
+
+		return rsp.Page, nil
+	}
+
+	// Response cached by website, query & page: api key is ignored
+	for _, apiKey := range []string{"key", "other"} {
+		page, err := get("TMDB", "1", apiKey)
+		assert.Nil(err)
+		assert.Equal("1", page)
+	}
+	assert.Equal(1, received)
+
+	page, err := get("TMDB", "2", "key")
+	assert.Nil(err)
+	assert.Equal("2", page)
+
+	_, err = get("IMDB", "1", "key")
+	assert.Nil(err)
+	assert.Equal(3, received)
+
+	stats := pool.GetCache().GetStats()
+	if assert.Contains(stats, "TMDB") {
+		assert.Equal(2, stats["TMDB"].Entries)
+		assert.Equal(1, stats["TMDB"].Hits)
+		assert.Equal(2, stats["TMDB"].Misses)
+		assert.Equal(0, stats["TMDB"].Expired)
+	}
+
+	// Restart with responses expired
+	config.TTL = "1ns"
+	assert.Nil(pool.SetCache(config))
+	time.Sleep(time.Millisecond)
+
+	stats = pool.GetCache().GetStats()
+	assert.Equal(2, stats["TMDB"].Expired)
+
+	_, err = get("TMDB", "1", "key")
+	assert.Nil(err)
+	assert.Equal(4, received)
+
+	// Offline: expired responses are still used
+	config.Offline = true
+	assert.Nil(pool.SetCache(config))
+
+	page, err = get("TMDB", "2", "key")
+	assert.Nil(err)
+	assert.Equal("2", page)
+	assert.Equal(4, received)
+
+	_, err = get("TMDB", "3", "key")
+	assert.NotNil(err)
+
+	// Purge
+	removed, err := pool.GetCache().Purge("imdb", false)
+	assert.Nil(err)
+	assert.Equal(1, removed)
+
+	removed, err = pool.GetCache().Purge("", true)
+	assert.Nil(err)
+	assert.Equal(2, removed)
+
+	assert.Equal(0, pool.GetCache().GetStats()["TMDB"].Entries)
+
+	// Invalid configurations
+	assert.NotNil(pool.SetCache(CacheConfig{Enable: true}))
+	assert.NotNil(pool.SetCache(CacheConfig{Enable: true, Path: dir, TTL: "1 day"}))
+}
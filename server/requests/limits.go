@@ -0,0 +1,186 @@
+package requests
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Default retry behaviour on 429 & 5xx responses
+const (
+	DEFAULT_RETRIES     = 3
+	DEFAULT_TIMEOUT     = 30 * time.Second
+	DEFAULT_BACKOFF     = 500 * time.Millisecond
+	DEFAULT_BACKOFF_MAX = 30 * time.Second
+)
+
+// Limits of the requests sent to the same website or host
+type Limits struct {
+	MaxRequests       int
+	RequestsPerSecond float64
+	Retries           int
+	Timeout           time.Duration
+	Backoff           time.Duration
+}
+
+// NewLimits returns the limits from the website configuration: the other
+// website parameters are ignored
+func NewLimits(config map[string]string, maxRequests int) (limits Limits, err error) {
+
+	limits = Limits{
+		MaxRequests: maxRequests,
+		Retries:     DEFAULT_RETRIES,
+		Timeout:     DEFAULT_TIMEOUT,
+		Backoff:     DEFAULT_BACKOFF,
+	}
+
+	for key, value := range config {
+
+		switch key {
+		case "max_requests":
+			limits.MaxRequests, err = strconv.Atoi(value)
+			if err == nil && limits.MaxRequests <= 0 {
+				err = fmt.Errorf("positive value expected")
+			}
+
+		case "requests_per_second":
+			limits.RequestsPerSecond, err = strconv.ParseFloat(value, 64)
+
+		case "retries":
+			limits.Retries, err = strconv.Atoi(value)
+
+		case "timeout":
+			limits.Timeout, err = time.ParseDuration(value)
+
+		case "backoff":
+			limits.Backoff, err = time.ParseDuration(value)
+
+		default:
+			continue
+		}
+
+		if err != nil {
+			err = fmt.Errorf("invalid '%s' value '%s': %s", key, value, err.Error())
+			return
+		}
+	}
+
+	return
+}
+
+// Handle the concurrency & the rate of the requests sent
+type limiter struct {
+	Limits
+
+	slots chan struct{}
+	next  time.Time
+	mutex sync.Mutex
+}
+
+func newLimiter(limits Limits) *limiter {
+	return &limiter{
+		Limits: limits,
+		slots:  make(chan struct{}, limits.MaxRequests),
+	}
+}
+
+// Wait for a free slot respecting the rate limit
+func (l *limiter) acquire(ctx context.Context) error {
+
+	select {
+	case l.slots <- struct{}{}:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	if l.RequestsPerSecond <= 0 {
+		return nil
+	}
+
+	// Reserve the next time slot
+	l.mutex.Lock()
+	now := time.Now()
+	if l.next.Before(now) {
+		l.next = now
+	}
+	wait := l.next.Sub(now)
+	l.next = l.next.Add(time.Duration(float64(time.Second) / l.RequestsPerSecond))
+	l.mutex.Unlock()
+
+	if err := sleep(ctx, wait); err != nil {
+		l.release()
+		return err
+	}
+
+	return nil
+}
+
+func (l *limiter) release() {
+	<-l.slots
+}
+
+// Returns the delay before the next attempt: the Retry-After header is
+// used if specified, otherwise the delay is doubled at each attempt
+func (l *limiter) backoff(attempt int, rsp *http.Response) time.Duration {
+
+	if rsp != nil {
+		if retryAfter := rsp.Header.Get("Retry-After"); retryAfter != "" {
+
+			if seconds, err := strconv.Atoi(retryAfter); err == nil {
+				return l.maxDelay(time.Duration(seconds) * time.Second)
+			}
+
+			if date, err := http.ParseTime(retryAfter); err == nil {
+				return l.maxDelay(time.Until(date))
+			}
+		}
+	}
+
+	delay := l.Backoff << uint(attempt)
+	if delay > DEFAULT_BACKOFF_MAX || delay <= 0 {
+		delay = DEFAULT_BACKOFF_MAX
+	}
+
+	return delay
+}
+
+// Limit the delay asked by the website: the slot is kept meanwhile so
+// the delay can't exceed the timeout nor the maximum backoff
+func (l *limiter) maxDelay(delay time.Duration) time.Duration {
+
+	max := DEFAULT_BACKOFF_MAX
+	if l.Timeout > 0 && l.Timeout < max {
+		max = l.Timeout
+	}
+
+	if delay > max {
+		return max
+	}
+
+	return delay
+}
+
+// Returns true if the request should be sent again
+func isRetryable(status int) bool {
+	return status == http.StatusTooManyRequests || status >= 500
+}
+
+func sleep(ctx context.Context, duration time.Duration) error {
+
+	if duration <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(duration)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
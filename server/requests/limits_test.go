@@ -0,0 +1,241 @@
+package requests
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func receive(t *testing.T, r *Request) *Response {
+
+	channel, err := SendRequest(r, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return <-channel
+}
+
+func TestNewLimits(t *testing.T) {
+
+	assert := assert.New(t)
+
+	limits, err := NewLimits(map[string]string{
+		"api_key":             "ignored",
+		"max_requests":        "4",
+		"requests_per_second": "0.5",
+		"retries":             "1",
+		"timeout":             "2s",
+		"backoff":             "10ms",
+	}, 2)
+	if assert.Nil(err) {
+		assert.Equal(Limits{
+			MaxRequests:       4,
+			RequestsPerSecond: 0.5,
+			Retries:           1,
+			Timeout:           2 * time.Second,
+			Backoff:           10 * time.Millisecond,
+		}, limits)
+	}
+
+	for _, config := range []map[string]string{
+		{"max_requests": "0"},
+		{"retries": "many"},
+		{"timeout": "2"},
+	} {
+		_, err = NewLimits(config, 2)
+		assert.NotNil(err)
+	}
+}
+
+func TestRetries(t *testing.T) {
+
+	assert := assert.New(t)
+
+	received := 0
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			received++
+
+			switch {
+			case r.URL.Path == "/unavailable" || received == 1:
+				w.WriteHeader(http.StatusServiceUnavailable)
+			case received == 2:
+				w.Header().Set("Retry-After", "1")
+				w.WriteHeader(http.StatusTooManyRequests)
+			default:
+				w.Write([]byte(`{"ok": true}`))
+			}
+		}))
+	defer server.Close()
+
+	pool := New(2, false)
+	pool.SetLimits("test", Limits{
+		MaxRequests: 1,
+		Retries:     2,
+		Timeout:     time.Second,
+		Backoff:     time.Millisecond,
+	})
+
+	// Retry-After respected
+	start := time.Now()
+	res := receive(t, &Request{
+		Website: "test",
+		Method:  "GET",
+		Url:     server.URL + "/",
+	})
+	assert.Nil(res.Err)
+	assert.Equal(http.StatusOK, res.Status)
+	assert.Equal(`{"ok": true}`, string(res.Body))
+	assert.Equal(3, received)
+	assert.True(time.Since(start) >= time.Second)
+
+	// Too many attempts
+	res = receive(t, &Request{
+		Website: "test",
+		Method:  "GET",
+		Url:     server.URL + "/unavailable",
+	})
+	assert.NotNil(res.Err)
+	assert.Equal(http.StatusServiceUnavailable, res.Status)
+	assert.Equal(6, received)
+
+	// Transport error reported
+	url := server.URL
+	server.Close()
+
+	res = receive(t, &Request{
+		Website: "test",
+		Method:  "GET",
+		Url:     url,
+	})
+	assert.NotNil(res.Err)
+}
+
+func TestBackoff(t *testing.T) {
+
+	assert := assert.New(t)
+
+	l := newLimiter(Limits{
+		MaxRequests: 1,
+		Timeout:     time.Second,
+		Backoff:     10 * time.Millisecond,
+	})
+
+	rsp := &http.Response{Header: make(http.Header)}
+
+	// Delay doubled at each attempt
+	assert.Equal(10*time.Millisecond, l.backoff(0, nil))
+	assert.Equal(40*time.Millisecond, l.backoff(2, rsp))
+
+	// Retry-After limited to the timeout
+	rsp.Header.Set("Retry-After", "2")
+	assert.Equal(time.Second, l.backoff(0, rsp))
+
+	rsp.Header.Set("Retry-After", time.Now().Add(time.Hour).UTC().Format(http.TimeFormat))
+	assert.Equal(time.Second, l.backoff(0, rsp))
+
+	// ... or to the maximum backoff
+	l.Timeout = 0
+	rsp.Header.Set("Retry-After", "3600")
+	assert.Equal(DEFAULT_BACKOFF_MAX, l.backoff(0, rsp))
+}
+
+func TestTimeout(t *testing.T) {
+
+	assert := assert.New(t)
+
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			select {
+			case <-time.After(time.Second):
+			case <-r.Context().Done():
+			}
+		}))
+	defer server.Close()
+
+	pool := New(2, false)
+	pool.SetLimits("test", Limits{
+		MaxRequests: 1,
+		Timeout:     10 * time.Millisecond,
+	})
+
+	res := receive(t, &Request{
+		Website: "test",
+		Method:  "GET",
+		Url:     server.URL,
+	})
+	assert.NotNil(res.Err)
+
+	// Request cancelled by the caller
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	res = receive(t, &Request{
+		Method:  "GET",
+		Url:     server.URL,
+		Context: ctx,
+	})
+	assert.NotNil(res.Err)
+}
+
+func TestConcurrency(t *testing.T) {
+
+	assert := assert.New(t)
+
+	var mutex sync.Mutex
+	current, max := 0, 0
+
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+
+			mutex.Lock()
+			current++
+			if current > max {
+				max = current
+			}
+			mutex.Unlock()
+
+			time.Sleep(20 * time.Millisecond)
+
+			mutex.Lock()
+			current--
+			mutex.Unlock()
+		}))
+	defer server.Close()
+
+	pool := New(2, false)
+	pool.SetLimits("test", Limits{
+		MaxRequests:       2,
+		RequestsPerSecond: 50,
+		Timeout:           time.Second,
+	})
+
+	start := time.Now()
+
+	channels := make([]chan *Response, 0)
+	for i := 0; i < 6; i++ {
+		channel, err := SendRequest(&Request{
+			Website: "test",
+			Method:  "GET",
+			Url:     server.URL,
+		}, nil)
+		if assert.Nil(err) {
+			channels = append(channels, channel)
+		}
+	}
+
+	for _, channel := range channels {
+		res := <-channel
+		assert.Nil(res.Err)
+	}
+
+	// 6 requests at 50 requests per second
+	assert.True(time.Since(start) >= 100*time.Millisecond)
+	assert.Equal(2, max)
+}
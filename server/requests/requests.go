@@ -2,13 +2,16 @@ package requests
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"log"
 	"net/http"
 	"net/url"
-	"strings"
+	"sync"
+	"time"
 )
 
 type Callback interface {
@@ -16,18 +19,26 @@ type Callback interface {
 }
 
 type Request struct {
+	Website string
 	Method  string
 	Url     string
 	Headers map[string]string
 	Queries map[string]string
 	Body    interface{}
 
+	// Optional context & timeout of each attempt
+	Context context.Context
+	Timeout time.Duration
+
 	Callback Callback
 }
 
+// Response received: the error is set if no valid response could be
+// received after all the attempts
 type Response struct {
 	Status int
-	Body   interface{}
+	Body   []byte
+	Err    error
 }
 
 type RequestsPool struct {
@@ -35,6 +46,12 @@ type RequestsPool struct {
 	requestsMax int
 	requests    chan Request
 	debug       bool
+	cache       *Cache
+
+	// Limits by website name or host
+	limits   map[string]Limits
+	limiters map[string]*limiter
+	mutex    sync.Mutex
 }
 
 // Uniq instance of the pool of requests
@@ -47,19 +64,72 @@ func New(sizeMax int, debug bool) *RequestsPool {
 		client:      &http.Client{},
 		requestsMax: sizeMax,
 		debug:       debug,
+		limits:      make(map[string]Limits),
+		limiters:    make(map[string]*limiter),
 	}
 
 	return pool
 }
 
-func Send(method string, url string, headers map[string]string, queries map[string]string, body interface{}, rsp interface{}) (chan *Response, error) {
+// SetCache enables the cache of the websites responses
+func (p *RequestsPool) SetCache(config CacheConfig) (err error) {
+	p.cache, err = NewCache(config)
+	return
+}
 
-	if pool == nil {
-		return nil, fmt.Errorf(
-			"%s %s: pool not initialised", method, url)
+// GetCache returns nil if the cache is disabled
+func (p *RequestsPool) GetCache() *Cache {
+	return p.cache
+}
+
+// SetLimits set the limits of the requests sent to the website or the
+// host specified
+func (p *RequestsPool) SetLimits(name string, limits Limits) {
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	p.limits[name] = limits
+	delete(p.limiters, name)
+}
+
+// Returns the limiter of the request website or host
+func (p *RequestsPool) getLimiter(r *Request, host string) *limiter {
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	name := host
+	if _, ok := p.limits[r.Website]; ok && r.Website != "" {
+		name = r.Website
+	}
+
+	l, ok := p.limiters[name]
+	if ok == false {
+
+		limits, ok := p.limits[name]
+		if ok == false {
+			limits = Limits{
+				MaxRequests: p.requestsMax,
+				Retries:     DEFAULT_RETRIES,
+				Timeout:     DEFAULT_TIMEOUT,
+				Backoff:     DEFAULT_BACKOFF,
+			}
+		}
+
+		if limits.MaxRequests <= 0 {
+			limits.MaxRequests = 1
+		}
+
+		l = newLimiter(limits)
+		p.limiters[name] = l
 	}
 
-	return pool.send(&Request{
+	return l
+}
+
+func Send(method string, url string, headers map[string]string, queries map[string]string, body interface{}, rsp interface{}) (chan *Response, error) {
+	return SendRequest(&Request{
 		Method:  method,
 		Url:     url,
 		Body:    body,
@@ -68,6 +138,18 @@ func Send(method string, url string, headers map[string]string, queries map[stri
 	}, rsp)
 }
 
+// SendRequest sends the request: the responses of the website requests
+// are cached if enabled
+func SendRequest(r *Request, rsp interface{}) (chan *Response, error) {
+
+	if pool == nil {
+		return nil, fmt.Errorf(
+			"%s %s: pool not initialised", r.Method, r.Url)
+	}
+
+	return pool.send(r, rsp)
+}
+
 // Send the requests and wait for the answer
 func (p *RequestsPool) send(r *Request, rsp interface{}) (res chan *Response, err error) {
 
@@ -78,6 +160,24 @@ func (p *RequestsPool) send(r *Request, rsp interface{}) (res chan *Response, er
 		return
 	}
 
+	// Check for website response already received
+	var cacheKey string
+	if p.cache != nil && r.Website != "" && r.Method == "GET" {
+
+		cacheKey = GetKey(r.Website, r.Method, r.Url, r.Queries)
+
+		status, buf, ok := p.cache.Get(r.Website, cacheKey)
+		if ok {
+			log.Printf("--> %s %s (cached)", r.Method, r.Url)
+			return p.cached(status, buf, rsp), nil
+		}
+
+		if p.cache.IsOffline() {
+			err = fmt.Errorf("%s %s: not cached in offline mode", r.Method, r.Url)
+			return
+		}
+	}
+
 	// Add queries
 	queries := url.Values{}
 	for key, value := range r.Queries {
@@ -88,68 +188,166 @@ func (p *RequestsPool) send(r *Request, rsp interface{}) (res chan *Response, er
 	baseUrl.RawQuery = queries.Encode()
 	r.Url = baseUrl.String()
 
-	var body io.Reader
+	var body []byte
 	if r.Body != nil {
-
-		var b []byte
-		b, err = json.Marshal(r.Body)
-		if err != nil {
+		if body, err = json.Marshal(r.Body); err != nil {
 			return
 		}
-
-		body = strings.NewReader(string(b))
 	}
 
-	fmt.Printf("REQUEST %+v\n", r)
-
 	var debugStr string
 	if p.debug && body != nil {
 		debugStr = fmt.Sprintf(" body: %s", body)
 	}
 	log.Printf("--> %s %s %s", r.Method, r.Url, debugStr)
 
-	// Create the request
-	req, err := http.NewRequest(r.Method, r.Url, body)
-	if err != nil {
-		return
+	ctx := r.Context
+	if ctx == nil {
+		ctx = context.Background()
 	}
 
-	// Add headers
-	for key, value := range r.Headers {
-		req.Header.Add(key, value)
-	}
+	l := p.getLimiter(r, baseUrl.Host)
 
 	res = make(chan *Response)
 
 	go func() {
 
-		// Receive the answer
-		httpRsp, err := p.client.Do(req)
+		rspReceived := p.do(ctx, l, r, body)
+
+		if rspReceived.Err != nil {
+			log.Printf("--> FAILED %s", rspReceived.Err.Error())
+		} else {
+
+			if p.debug {
+				debugStr = fmt.Sprintf(" body: %s", rspReceived.Body)
+			}
+
+			log.Printf("<-- %d%s", rspReceived.Status, debugStr)
+
+			if rsp != nil && len(rspReceived.Body) > 0 {
+				if err := json.Unmarshal(rspReceived.Body, rsp); err != nil {
+					rspReceived.Err = fmt.Errorf("%s %s: invalid response: %s",
+						r.Method, r.Url, err.Error())
+				}
+			}
+
+			// Store the website response
+			if cacheKey != "" && rspReceived.Err == nil &&
+				rspReceived.Status == http.StatusOK {
+				err := p.cache.Set(r.Website, cacheKey, rspReceived.Status, rspReceived.Body)
+				if err != nil {
+					log.Printf("--> cache %s", err.Error())
+				}
+			}
+		}
+
+		res <- rspReceived
+	}()
+
+	return
+}
+
+// Send the request until a valid response is received or the number of
+// retries is reached
+func (p *RequestsPool) do(ctx context.Context, l *limiter, r *Request, body []byte) (res *Response) {
+
+	res = new(Response)
+
+	if res.Err = l.acquire(ctx); res.Err != nil {
+		return
+	}
+	defer l.release()
+
+	timeout := r.Timeout
+	if timeout <= 0 {
+		timeout = l.Timeout
+	}
+
+	for attempt := 0; ; attempt++ {
+
+		var httpRsp *http.Response
+		httpRsp, res.Err = p.attempt(ctx, timeout, r, body, res)
+
+		if res.Err == nil && isRetryable(res.Status) == false {
+			return
+		}
+
+		// Request cancelled by the caller
+		if ctx.Err() != nil || attempt >= l.Retries {
+
+			if res.Err == nil {
+				res.Err = fmt.Errorf("%s %s: unexpected status %d",
+					r.Method, r.Url, res.Status)
+			}
 
-		if err != nil {
-			log.Printf("--> FAILED %s", err.Error())
-			close(res)
 			return
 		}
 
-		// Get the buffer
-		buf := new(bytes.Buffer)
-		buf.ReadFrom(httpRsp.Body)
+		delay := l.backoff(attempt, httpRsp)
+
+		log.Printf("--> RETRY %s %s in %s", r.Method, r.Url, delay)
 
-		if p.debug {
-			debugStr = fmt.Sprintf(" body: %s", buf.String())
+		if err := sleep(ctx, delay); err != nil {
+			res.Err = err
+			return
 		}
+	}
+}
 
-		log.Printf("<-- %s%s", httpRsp.Status, debugStr)
+// Send the request once with its timeout
+func (p *RequestsPool) attempt(ctx context.Context, timeout time.Duration,
+	r *Request, body []byte, res *Response) (httpRsp *http.Response, err error) {
 
-		if rsp != nil {
-			err = json.Unmarshal(buf.Bytes(), rsp)
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+
+	// Create the request
+	req, err := http.NewRequest(r.Method, r.Url, reader)
+	if err != nil {
+		return
+	}
+
+	// Add headers
+	for key, value := range r.Headers {
+		req.Header.Add(key, value)
+	}
+
+	// Receive the answer
+	if httpRsp, err = p.client.Do(req.WithContext(ctx)); err != nil {
+		return
+	}
+	defer httpRsp.Body.Close()
+
+	res.Status = httpRsp.StatusCode
+	res.Body, err = ioutil.ReadAll(httpRsp.Body)
+	return
+}
+
+// Returns the response stored
+func (p *RequestsPool) cached(status int, buf []byte, rsp interface{}) chan *Response {
+
+	res := make(chan *Response)
+
+	go func() {
+
+		var err error
+		if rsp != nil && len(buf) > 0 {
+			if err = json.Unmarshal(buf, rsp); err != nil {
+				err = fmt.Errorf("invalid cached response: %s", err.Error())
+			}
 		}
 
 		res <- &Response{
-			Status: httpRsp.StatusCode,
+			Status: status,
+			Body:   buf,
+			Err:    err,
 		}
 	}()
 
-	return
+	return res
 }
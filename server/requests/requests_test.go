@@ -1,7 +1,11 @@
 package requests
 
 import (
+	"net/http"
+	"net/http/httptest"
 	"testing"
+
+	"github.com/stretchr/testify/assert"
 )
 
 func TestRequests(t *testing.T) {
@@ -12,3 +16,39 @@ func TestRequests(t *testing.T) {
 		Url:    "https://google.fr",
 	}, nil)
 }
+
+func TestInvalidResponse(t *testing.T) {
+
+	assert := assert.New(t)
+
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/invalid" {
+				w.Write([]byte(`<html>`))
+				return
+			}
+			w.Write([]byte(`{"ok": true}`))
+		}))
+	defer server.Close()
+
+	New(2, false)
+
+	var rsp struct {
+		Ok bool `json:"ok"`
+	}
+
+	channel, err := Send("GET", server.URL+"/", nil, nil, nil, &rsp)
+	if assert.Nil(err) {
+		res := <-channel
+		assert.Nil(res.Err)
+		assert.True(rsp.Ok)
+	}
+
+	// Response not decoded returned to the caller
+	channel, err = Send("GET", server.URL+"/invalid", nil, nil, nil, &rsp)
+	if assert.Nil(err) {
+		res := <-channel
+		assert.NotNil(res.Err)
+		assert.Equal(http.StatusOK, res.Status)
+	}
+}
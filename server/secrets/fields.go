@@ -0,0 +1,111 @@
+package secrets
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+)
+
+// Value returned instead of the secrets
+const REDACTED = "********"
+
+// Tag flagging the string fields holding a secret, ie. `secret:"true"`
+const TAG = "secret"
+
+// Returns the JSON name & the value of the string fields flagged as
+// secret
+func getFields(v interface{}) (names []string, values []reflect.Value) {
+
+	value := reflect.ValueOf(v)
+	for value.Kind() == reflect.Ptr || value.Kind() == reflect.Interface {
+		if value.IsNil() {
+			return
+		}
+		value = value.Elem()
+	}
+
+	if value.Kind() != reflect.Struct {
+		return
+	}
+
+	typ := value.Type()
+	for idx := 0; idx < typ.NumField(); idx++ {
+
+		field := typ.Field(idx)
+		if field.Tag.Get(TAG) != "true" || field.Type.Kind() != reflect.String {
+			continue
+		}
+
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+		if name == "" {
+			name = field.Name
+		}
+
+		names = append(names, name)
+		values = append(values, value.Field(idx))
+	}
+
+	return
+}
+
+// Protect stores the plain secret fields of the structure and replaces
+// them with a reference named 'prefix/jsonName': returns true if a field
+// has been replaced
+func (s *Secrets) Protect(v interface{}, prefix string) (isProtected bool, err error) {
+
+	names, values := getFields(v)
+
+	for idx, value := range values {
+
+		plain := value.String()
+		if _, ok := IsReference(plain); ok || plain == "" {
+			continue
+		}
+
+		name := prefix + "/" + names[idx]
+		if err = s.Set(name, plain); err != nil {
+			return
+		}
+
+		if value.CanSet() {
+			value.SetString(Reference(name))
+			isProtected = true
+		}
+	}
+
+	return
+}
+
+// Redact returns the JSON object of the structure with the plain secret
+// fields redacted, the references are kept
+func Redact(v interface{}) interface{} {
+
+	names, values := getFields(v)
+	if len(names) == 0 {
+		return v
+	}
+
+	src, err := json.Marshal(v)
+	if err != nil {
+		return v
+	}
+
+	var res map[string]json.RawMessage
+	if err := json.Unmarshal(src, &res); err != nil {
+		return v
+	}
+
+	for idx, value := range values {
+
+		plain := value.String()
+		if _, ok := IsReference(plain); ok || plain == "" {
+			continue
+		}
+
+		if _, ok := res[names[idx]]; ok {
+			res[names[idx]], _ = json.Marshal(REDACTED)
+		}
+	}
+
+	return res
+}
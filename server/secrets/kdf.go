@@ -0,0 +1,50 @@
+package secrets
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+)
+
+// Size of the random salt used to derive the passphrase
+const SALT_SIZE = 16
+
+// PBKDF2 iterations
+const KDF_ITERATIONS = 100000
+
+// DeriveKey returns the AES-256 key derived from the passphrase with
+// PBKDF2-HMAC-SHA256 (RFC 8018)
+func DeriveKey(passphrase string, salt []byte) []byte {
+
+	prf := hmac.New(sha256.New, []byte(passphrase))
+
+	key := make([]byte, 0, KEY_SIZE)
+	block := make([]byte, 4)
+
+	for idx := uint32(1); len(key) < KEY_SIZE; idx++ {
+
+		binary.BigEndian.PutUint32(block, idx)
+
+		prf.Reset()
+		prf.Write(salt)
+		prf.Write(block)
+		u := prf.Sum(nil)
+
+		t := make([]byte, len(u))
+		copy(t, u)
+
+		for iteration := 1; iteration < KDF_ITERATIONS; iteration++ {
+			prf.Reset()
+			prf.Write(u)
+			u = prf.Sum(u[:0])
+
+			for i := range t {
+				t[i] ^= u[i]
+			}
+		}
+
+		key = append(key, t...)
+	}
+
+	return key[:KEY_SIZE]
+}
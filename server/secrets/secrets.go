@@ -0,0 +1,326 @@
+package secrets
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Prefix of the configuration values referencing a secret
+const REFERENCE_PREFIX = "secret:"
+
+// Environment variable read when no passphrase is configured
+const PASSPHRASE_ENV = "CLASSIFY_SECRETS_PASSPHRASE"
+
+const DEFAULT_PATH = "secrets.json"
+
+// AES-256 key size
+const KEY_SIZE = 32
+
+// Value encrypted to check the key when the secrets are opened
+const checkName = "check"
+const checkValue = "classify"
+
+type Config struct {
+	// File storing the secrets encrypted
+	Path string `json:"path"`
+
+	// File storing the key, created if not existing
+	KeyFile string `json:"keyFile"`
+
+	// Passphrase used to derive the key if there is no key file
+	Passphrase string `json:"passphrase"`
+}
+
+// Secrets stored encrypted with AES-GCM: each secret name is
+// authenticated with its value
+type Secrets struct {
+	path  string
+	aead  cipher.AEAD
+	file  secretsFile
+	mutex sync.Mutex
+}
+
+type secretsFile struct {
+	Salt   []byte            `json:"salt,omitempty"`
+	Check  []byte            `json:"check"`
+	Values map[string][]byte `json:"values"`
+}
+
+// Open the secrets stored: returns nil if neither a key file nor a
+// passphrase are configured
+func Open(config Config) (s *Secrets, err error) {
+
+	passphrase := config.Passphrase
+	if passphrase == "" {
+		passphrase = os.Getenv(PASSPHRASE_ENV)
+	}
+
+	if config.KeyFile == "" && passphrase == "" {
+		return
+	}
+
+	s = &Secrets{
+		path: config.Path,
+	}
+
+	if s.path == "" {
+		s.path = DEFAULT_PATH
+	}
+
+	// Read the secrets already stored
+	src, err := ioutil.ReadFile(s.path)
+	switch {
+	case err == nil:
+		if err = json.Unmarshal(src, &s.file); err != nil {
+			err = fmt.Errorf("secrets file '%s': %s", s.path, err.Error())
+			return nil, err
+		}
+	case os.IsNotExist(err):
+		err = nil
+	default:
+		return nil, err
+	}
+
+	if s.file.Values == nil {
+		s.file.Values = make(map[string][]byte)
+	}
+
+	var key []byte
+	if config.KeyFile != "" {
+		key, err = readKeyFile(config.KeyFile)
+	} else {
+		if len(s.file.Salt) == 0 {
+			s.file.Salt = make([]byte, SALT_SIZE)
+			if _, err = io.ReadFull(rand.Reader, s.file.Salt); err != nil {
+				return nil, err
+			}
+		}
+
+		key = DeriveKey(passphrase, s.file.Salt)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if s.aead, err = cipher.NewGCM(block); err != nil {
+		return nil, err
+	}
+
+	// Check the key with the value encrypted at the creation
+	if len(s.file.Check) == 0 {
+		if s.file.Check, err = s.seal(checkName, checkValue); err != nil {
+			return nil, err
+		}
+
+		if err = s.save(); err != nil {
+			return nil, err
+		}
+	} else if value, checkErr := s.open(checkName, s.file.Check); checkErr != nil || value != checkValue {
+		return nil, fmt.Errorf("invalid secrets key for '%s'", s.path)
+	}
+
+	return
+}
+
+// Returns the key stored in hexadecimal, a new one is generated if the
+// file doesn't exist
+func readKeyFile(path string) ([]byte, error) {
+
+	src, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+
+		key := make([]byte, KEY_SIZE)
+		if _, err := io.ReadFull(rand.Reader, key); err != nil {
+			return nil, err
+		}
+
+		if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			return nil, err
+		}
+
+		err = ioutil.WriteFile(path, []byte(hex.EncodeToString(key)), 0600)
+		return key, err
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := hex.DecodeString(strings.TrimSpace(string(src)))
+	if err != nil || len(key) != KEY_SIZE {
+		return nil, fmt.Errorf("invalid secrets key file '%s': %d hexadecimal bytes expected",
+			path, KEY_SIZE)
+	}
+
+	return key, nil
+}
+
+// Returns the nonce followed by the value encrypted
+func (s *Secrets) seal(name string, value string) ([]byte, error) {
+
+	nonce := make([]byte, s.aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+
+	return s.aead.Seal(nonce, nonce, []byte(value), []byte(name)), nil
+}
+
+func (s *Secrets) open(name string, encrypted []byte) (string, error) {
+
+	size := s.aead.NonceSize()
+	if len(encrypted) < size {
+		return "", fmt.Errorf("secret '%s' invalid", name)
+	}
+
+	value, err := s.aead.Open(nil, encrypted[:size], encrypted[size:], []byte(name))
+	if err != nil {
+		return "", fmt.Errorf("secret '%s' can't be decrypted", name)
+	}
+
+	return string(value), nil
+}
+
+// Write the secrets file readable only by its owner
+func (s *Secrets) save() error {
+
+	src, err := json.MarshalIndent(&s.file, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp := s.path + ".tmp"
+	if err := ioutil.WriteFile(tmp, src, 0600); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, s.path)
+}
+
+// Set the secret value
+func (s *Secrets) Set(name string, value string) error {
+
+	if name == "" {
+		return fmt.Errorf("secret name required")
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	encrypted, err := s.seal(name, value)
+	if err != nil {
+		return err
+	}
+
+	s.file.Values[name] = encrypted
+	return s.save()
+}
+
+// Get the secret value
+func (s *Secrets) Get(name string) (string, error) {
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	encrypted, ok := s.file.Values[name]
+	if ok == false {
+		return "", fmt.Errorf("secret '%s' not found", name)
+	}
+
+	return s.open(name, encrypted)
+}
+
+// Delete the secret
+func (s *Secrets) Delete(name string) error {
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if _, ok := s.file.Values[name]; ok == false {
+		return fmt.Errorf("secret '%s' not found", name)
+	}
+
+	delete(s.file.Values, name)
+	return s.save()
+}
+
+// GetNames returns the names of the secrets stored
+func (s *Secrets) GetNames() []string {
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	names := make([]string, 0, len(s.file.Values))
+	for name := range s.file.Values {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	return names
+}
+
+// Reference returns the value referencing the secret
+func Reference(name string) string {
+	return REFERENCE_PREFIX + name
+}
+
+// IsReference returns the name of the secret referenced by the value
+func IsReference(value string) (string, bool) {
+
+	if strings.HasPrefix(value, REFERENCE_PREFIX) == false {
+		return "", false
+	}
+
+	return strings.TrimPrefix(value, REFERENCE_PREFIX), true
+}
+
+// Resolve returns the secret value if referenced, the value otherwise
+func (s *Secrets) Resolve(value string) (string, error) {
+
+	name, ok := IsReference(value)
+	if ok == false {
+		return value, nil
+	}
+
+	if s == nil {
+		return "", fmt.Errorf("secret '%s' referenced but secrets not configured", name)
+	}
+
+	return s.Get(name)
+}
+
+// Secrets used by the imports to resolve their references
+var defaultSecrets *Secrets
+var defaultMutex sync.RWMutex
+
+func SetDefault(s *Secrets) {
+	defaultMutex.Lock()
+	defaultSecrets = s
+	defaultMutex.Unlock()
+}
+
+// Resolve the value with the default secrets
+func Resolve(value string) (string, error) {
+	defaultMutex.RLock()
+	s := defaultSecrets
+	defaultMutex.RUnlock()
+
+	return s.Resolve(value)
+}
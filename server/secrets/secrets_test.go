@@ -0,0 +1,168 @@
+package secrets
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testImport struct {
+	Login    string `json:"login"`
+	Password string `json:"password" secret:"true"`
+}
+
+func TestSecrets(t *testing.T) {
+
+	assert := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "secrets")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// Secrets disabled
+	s, err := Open(Config{})
+	assert.Nil(err)
+	assert.Nil(s)
+
+	_, err = s.Resolve("secret:imap")
+	assert.NotNil(err)
+
+	value, err := s.Resolve("plain")
+	assert.Nil(err)
+	assert.Equal("plain", value)
+
+	// Key file generated
+	config := Config{
+		Path:    filepath.Join(dir, "secrets.json"),
+		KeyFile: filepath.Join(dir, "key"),
+	}
+
+	s, err = Open(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := os.Stat(config.KeyFile)
+	if assert.Nil(err) {
+		assert.Equal(os.FileMode(0600), info.Mode().Perm())
+	}
+
+	assert.Nil(s.Set("imap", "password"))
+	assert.Nil(s.Set("tmdb", "api key"))
+
+	// Values encrypted
+	src, err := ioutil.ReadFile(config.Path)
+	assert.Nil(err)
+	assert.False(strings.Contains(string(src), "password"))
+
+	// Reopened with the same key
+	s, err = Open(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal([]string{"imap", "tmdb"}, s.GetNames())
+
+	value, err = s.Resolve("secret:imap")
+	assert.Nil(err)
+	assert.Equal("password", value)
+
+	assert.Nil(s.Delete("tmdb"))
+	assert.NotNil(s.Delete("tmdb"))
+	_, err = s.Get("tmdb")
+	assert.NotNil(err)
+
+	// Another key refused
+	assert.Nil(ioutil.WriteFile(config.KeyFile, []byte(strings.Repeat("00", KEY_SIZE)), 0600))
+	_, err = Open(config)
+	assert.NotNil(err)
+
+	// Passphrase
+	config = Config{
+		Path:       filepath.Join(dir, "passphrase.json"),
+		Passphrase: "looper",
+	}
+
+	s, err = Open(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Nil(s.Set("imap", "password"))
+
+	s, err = Open(config)
+	if assert.Nil(err) {
+		value, err = s.Get("imap")
+		assert.Nil(err)
+		assert.Equal("password", value)
+	}
+
+	config.Passphrase = "the loop"
+	_, err = Open(config)
+	assert.NotNil(err)
+}
+
+func TestProtect(t *testing.T) {
+
+	assert := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "secrets")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	s, err := Open(Config{
+		Path:    filepath.Join(dir, "secrets.json"),
+		KeyFile: filepath.Join(dir, "key"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Plain values redacted
+	i := &testImport{Login: "username", Password: "password"}
+	assert.Equal(map[string]string{
+		"login":    `"username"`,
+		"password": `"` + REDACTED + `"`,
+	}, toStrings(Redact(i)))
+
+	// Plain values replaced by references
+	isProtected, err := s.Protect(i, "imports/mails")
+	assert.Nil(err)
+	assert.True(isProtected)
+	assert.Equal("secret:imports/mails/password", i.Password)
+
+	value, err := s.Resolve(i.Password)
+	assert.Nil(err)
+	assert.Equal("password", value)
+
+	// References kept
+	isProtected, err = s.Protect(i, "imports/mails")
+	assert.Nil(err)
+	assert.False(isProtected)
+	assert.Equal("secret:imports/mails/password", i.Password)
+
+	assert.Equal(map[string]string{
+		"login":    `"username"`,
+		"password": `"secret:imports/mails/password"`,
+	}, toStrings(Redact(i)))
+}
+
+func toStrings(v interface{}) map[string]string {
+
+	res := make(map[string]string)
+	if m, ok := v.(map[string]json.RawMessage); ok {
+		for key, value := range m {
+			res[key] = string(value)
+		}
+	}
+
+	return res
+}
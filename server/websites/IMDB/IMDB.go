@@ -5,7 +5,10 @@ import (
 	"github.com/ohohleo/classify/data"
 	"github.com/ohohleo/classify/requests"
 	"github.com/ohohleo/classify/websites"
+	"regexp"
+	"strconv"
 	"strings"
+	"time"
 )
 
 type IMDB struct {
@@ -21,8 +24,6 @@ func New() *IMDB {
 
 func (i *IMDB) SetConfig(config map[string]string) bool {
 
-	fmt.Printf("SetConfig %+v\n", config)
-
 	// Get API key
 	apiKey, ok := config["api_key"]
 	if ok {
@@ -107,8 +108,8 @@ func (i *IMDB) search(input string) chan *Results {
 			return
 		}
 
-		_, ok := <-channel
-		if ok == false {
+		res, ok := <-channel
+		if ok == false || res.Err != nil {
 			close(c)
 			return
 		}
@@ -139,8 +140,8 @@ func (i *IMDB) getResource(id string) chan *Data {
 		}
 
 		// Wait for the result
-		_, ok := <-channel
-		if ok == false {
+		res, ok := <-channel
+		if ok == false || res.Err != nil {
 			close(c)
 			return
 		}
@@ -186,16 +187,13 @@ func (i *IMDB) Search(input string) chan data.Data {
 			d, ok := <-i.getResource(title.Id)
 			if ok {
 
-				// TODO: duration & released
+				movie := newMovie(title.Id, d)
+				movie.Name = title.Title
+				movie.Url = title.Url
 
-				movie := &data.Movie{
-					Name:        title.Title,
-					Url:         title.Url,
-					Image:       d.Image,
-					Description: d.Description,
-					Directors:   d.Directors,
-					Cast:        d.Cast,
-					Genres:      d.Genres,
+				// Only the release year is known
+				if title.Year > 0 && movie.Released.IsZero() {
+					movie.Released = time.Date(title.Year, 1, 1, 0, 0, 0, 0, time.UTC)
 				}
 
 				c <- movie
@@ -208,6 +206,64 @@ func (i *IMDB) Search(input string) chan data.Data {
 	return c
 }
 
+// Details returns the movie with all its informations
+func (i *IMDB) Details(id string) (data.Data, error) {
+
+	d, ok := <-i.getResource(id)
+	if ok == false {
+		return nil, fmt.Errorf("IMDB resource '%s' not found", id)
+	}
+
+	return newMovie(id, d), nil
+}
+
+// Returns the movie with the resource attributes
+func newMovie(id string, d *Data) *data.Movie {
+
+	movie := &data.Movie{
+		Id:          id,
+		Name:        d.Title,
+		Url:         "http://www.imdb.com/title/" + id + "/",
+		Image:       d.Image,
+		Description: d.Description,
+		Duration:    parseDuration(d.Duration),
+		Directors:   d.Directors,
+		Writers:     d.Writers,
+		Cast:        d.Cast,
+		Genres:      d.Genres,
+	}
+
+	// Release date with optional country: "2 November 2012 (USA)"
+	released := d.Released
+	if idx := strings.Index(released, " ("); idx >= 0 {
+		released = released[:idx]
+	}
+
+	if date, err := time.Parse("2 January 2006", released); err == nil {
+		movie.Released = date
+	} else if d.Year > 0 {
+		movie.Released = time.Date(d.Year, 1, 1, 0, 0, 0, 0, time.UTC)
+	}
+
+	return movie
+}
+
+var durationReg = regexp.MustCompile(`^(?:(\d+)h)?\s*(?:(\d+)\s*min)?$`)
+
+// Returns the duration in minutes: "2h 13min", "133 min"
+func parseDuration(duration string) (minutes int) {
+
+	matches := durationReg.FindStringSubmatch(strings.TrimSpace(duration))
+	if matches == nil {
+		return
+	}
+
+	hours, _ := strconv.Atoi(matches[1])
+	minutes, _ = strconv.Atoi(matches[2])
+
+	return hours*60 + minutes
+}
+
 // Envoie d'une requête
 func (i *IMDB) send(id string, queries map[string]string, rsp interface{}) (chan *requests.Response, error) {
 
@@ -220,5 +276,10 @@ func (i *IMDB) send(id string, queries map[string]string, rsp interface{}) (chan
 		queries["api_key"] = i.apiKey
 	}
 
-	return requests.Send("GET", i.Url+id, nil, queries, nil, &rsp)
+	return requests.SendRequest(&requests.Request{
+		Website: i.GetRef().String(),
+		Method:  "GET",
+		Url:     i.Url + id,
+		Queries: queries,
+	}, &rsp)
 }
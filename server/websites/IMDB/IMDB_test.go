@@ -2,8 +2,14 @@ package IMDB
 
 import (
 	"fmt"
-	"github.com/ohohleo/classify/requests"
+	"net/http"
+	"net/http/httptest"
 	"testing"
+	"time"
+
+	"github.com/ohohleo/classify/data"
+	"github.com/ohohleo/classify/requests"
+	"github.com/stretchr/testify/assert"
 )
 
 func TestSearch(t *testing.T) {
@@ -25,3 +31,64 @@ func TestSearch(t *testing.T) {
 
 	//imdb.getResource("tt0405393")
 }
+
+func TestDetails(t *testing.T) {
+
+	assert := assert.New(t)
+
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+
+			if r.URL.Path != "/tt1276104" {
+				w.Write([]byte(`{"status": "error", "code": 404}`))
+				return
+			}
+
+			w.Write([]byte(`{
+  "status": "success",
+  "code": 200,
+  "data": {
+    "id": "tt1276104",
+    "title": "Looper",
+    "description": "Time travel",
+    "duration": "1h 59min",
+    "released": "28 September 2012 (USA)",
+    "cast": ["Joseph Gordon-Levitt", "Bruce Willis"],
+    "genre": ["Action", "Crime"],
+    "directors": ["Rian Johnson"],
+    "writers": ["Rian Johnson"],
+    "image": "looper.jpg"
+  }
+}`))
+		}))
+	defer server.Close()
+
+	requests.New(2, false)
+
+	imdb := New()
+	imdb.SetConfig(map[string]string{"url": server.URL + "/"})
+
+	d, err := imdb.Details("tt1276104")
+	if assert.Nil(err) {
+		assert.Equal(&data.Movie{
+			Id:          "tt1276104",
+			Name:        "Looper",
+			Url:         "http://www.imdb.com/title/tt1276104/",
+			Released:    time.Date(2012, 9, 28, 0, 0, 0, 0, time.UTC),
+			Duration:    119,
+			Image:       "looper.jpg",
+			Description: "Time travel",
+			Directors:   []string{"Rian Johnson"},
+			Writers:     []string{"Rian Johnson"},
+			Cast:        []string{"Joseph Gordon-Levitt", "Bruce Willis"},
+			Genres:      []string{"Action", "Crime"},
+		}, d)
+	}
+
+	_, err = imdb.Details("tt0000000")
+	assert.NotNil(err)
+
+	assert.Equal(133, parseDuration("133 min"))
+	assert.Equal(120, parseDuration("2h"))
+	assert.Equal(0, parseDuration("unknown"))
+}
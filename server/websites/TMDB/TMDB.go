@@ -3,26 +3,36 @@ package TMDB
 import (
 	"fmt"
 	"github.com/ohohleo/classify/data"
+	"github.com/ohohleo/classify/requests"
 	"github.com/ohohleo/classify/websites"
 	api "github.com/ryanbradynd05/go-tmdb"
+	"log"
+	"net/http"
 	"strconv"
 	"time"
 )
 
+// Maximum number of actors kept by movie
+const CAST_MAX = 10
+
 type TMDB struct {
-	api        *api.TMDb
+	Url        string
 	apiKey     string
 	posterPath string
+	language   string
+	maxPages   int
 }
 
 func New() *TMDB {
-	return &TMDB{}
+	return &TMDB{
+		Url:        "https://api.themoviedb.org/3/",
+		posterPath: "https://image.tmdb.org/t/p/original",
+		maxPages:   3,
+	}
 }
 
 func (t *TMDB) SetConfig(config map[string]string) bool {
 
-	fmt.Printf("SetConfig %+v\n", config)
-
 	// Get API key
 	if apiKey, ok := config["api_key"]; ok {
 		t.apiKey = apiKey
@@ -33,8 +43,24 @@ func (t *TMDB) SetConfig(config map[string]string) bool {
 		t.posterPath = posterPath
 	}
 
-	// TODO : fix api
-	// t.api = api.Init(apiKey)
+	// Get alternative url
+	if url, ok := config["url"]; ok {
+		t.Url = url
+	}
+
+	// Get results language
+	if language, ok := config["language"]; ok {
+		t.language = language
+	}
+
+	// Get maximum number of pages searched
+	if maxPages, ok := config["max_pages"]; ok {
+		pages, err := strconv.Atoi(maxPages)
+		if err != nil || pages <= 0 {
+			return false
+		}
+		t.maxPages = pages
+	}
 
 	return true
 }
@@ -43,78 +69,195 @@ func (t *TMDB) GetRef() websites.Ref {
 	return websites.TMDB
 }
 
-// Launch a search request through the3 IMDB Api
+// Launch a search request through the TMDB Api
+func (t *TMDB) search(input string, page int) (*api.MovieSearchResults, error) {
+
+	var rsp api.MovieSearchResults
+
+	queries := map[string]string{
+		"query": input,
+		"page":  strconv.Itoa(page),
+	}
+
+	if err := t.send("search/movie", queries, &rsp); err != nil {
+		return nil, err
+	}
+
+	return &rsp, nil
+}
+
+// Get the movie informations with the credits
+func (t *TMDB) getMovie(id int) (*api.Movie, error) {
+
+	var rsp api.Movie
+
+	queries := map[string]string{
+		"append_to_response": "credits",
+	}
+
+	if err := t.send("movie/"+strconv.Itoa(id), queries, &rsp); err != nil {
+		return nil, err
+	}
+
+	return &rsp, nil
+}
+
+// Generic method used to search matching movies: the details are not
+// requested for each result
 func (t *TMDB) Search(input string) chan data.Data {
 
 	c := make(chan data.Data)
 
 	go func() {
 
-		currentPage := -1
-		totalPages := 0
-
 		// Get all pages
-		for currentPage <= totalPages {
+		for page, totalPages := 1, 1; page <= totalPages && page <= t.maxPages; page++ {
 
-			options := make(map[string]string)
-
-			if currentPage > 0 {
-				options["page"] = strconv.Itoa(currentPage)
+			results, err := t.search(input, page)
+			if err != nil {
+				log.Printf("TMDB search error: %s\n", err.Error())
+				break
 			}
 
-			results := &api.MovieSearchResults{
-				Page: 0,
-				Results: []api.MovieShort{
-					api.MovieShort{
-						ID:            1,
-						OriginalTitle: "original title",
-						Title:         "title",
-						ReleaseDate:   "2010-01-02",
-						PosterPath:    "path",
-					},
-					api.MovieShort{
-						ID:            2,
-						OriginalTitle: "original title 2",
-						Title:         "title 2",
-						ReleaseDate:   "2011-01-02",
-						PosterPath:    "path2",
-					},
-				},
-				TotalPages:   0,
-				TotalResults: 2,
+			totalPages = results.TotalPages
+
+			// Only the search results: the full record is retreived
+			// once validated
+			for _, d := range results.Results {
+				c <- t.newMovie(d.ID, d.Title, d.OriginalTitle,
+					d.ReleaseDate, d.Overview, d.PosterPath)
 			}
+		}
 
-			// , err := t.api.SearchMovie(input, options)
-			// if err != nil {
-			// 	close(c)
-			// 	return
-			// }
+		close(c)
+	}()
 
-			currentPage = results.Page
-			totalPages = results.TotalPages
+	return c
+}
 
-			for _, d := range results.Results {
+// Details returns the movie with all its informations
+func (t *TMDB) Details(id string) (data.Data, error) {
 
-				fmt.Printf("movie: %+v\n", d)
+	movieId, err := strconv.Atoi(id)
+	if err != nil {
+		return nil, fmt.Errorf("invalid TMDB movie id '%s'", id)
+	}
 
-				release, _ := time.Parse("2006-01-02", d.ReleaseDate)
+	details, err := t.getMovie(movieId)
+	if err != nil {
+		return nil, err
+	}
 
-				movie := &data.Movie{
-					Name:     d.OriginalTitle,
-					Image:    t.posterPath + d.PosterPath,
-					Released: release,
-				}
+	movie := t.newMovie(details.ID, details.Title, details.OriginalTitle,
+		details.ReleaseDate, details.Overview, details.PosterPath)
 
-				// movie.ItemGeneric.Id = t.GetName() + "_" + strconv.Itoa(d.ID)
+	t.setDetails(movie, details)
+	return movie, nil
+}
 
-				c <- movie
-			}
+// Returns the movie with the search result attributes: the title in the
+// language requested is used, the original title otherwise
+func (t *TMDB) newMovie(id int, title string, originalTitle string,
+	releaseDate string, overview string, posterPath string) *data.Movie {
 
-			currentPage++
+	release, _ := time.Parse("2006-01-02", releaseDate)
+
+	if title == "" {
+		title = originalTitle
+	}
+
+	movie := &data.Movie{
+		Id:          strconv.Itoa(id),
+		Name:        title,
+		Url:         "https://www.themoviedb.org/movie/" + strconv.Itoa(id),
+		Released:    release,
+		Description: overview,
+	}
+
+	if posterPath != "" {
+		movie.Image = t.posterPath + posterPath
+	}
+
+	return movie
+}
+
+// Set the movie attributes from the details received
+func (t *TMDB) setDetails(movie *data.Movie, details *api.Movie) {
+
+	movie.Duration = int(details.Runtime)
+
+	if details.Overview != "" {
+		movie.Description = details.Overview
+	}
+
+	if details.PosterPath != "" {
+		movie.Image = t.posterPath + details.PosterPath
+	}
+
+	if release, err := time.Parse("2006-01-02", details.ReleaseDate); err == nil {
+		movie.Released = release
+	}
+
+	movie.Genres = make([]string, 0, len(details.Genres))
+	for _, genre := range details.Genres {
+		movie.Genres = append(movie.Genres, genre.Name)
+	}
+
+	if details.Credits == nil {
+		return
+	}
+
+	movie.Directors = make([]string, 0)
+	movie.Writers = make([]string, 0)
+	for _, crew := range details.Credits.Crew {
+		switch {
+		case crew.Job == "Director":
+			movie.Directors = append(movie.Directors, crew.Name)
+		case crew.Department == "Writing":
+			movie.Writers = append(movie.Writers, crew.Name)
 		}
+	}
 
-		close(c)
-	}()
+	movie.Cast = make([]string, 0, CAST_MAX)
+	for _, cast := range details.Credits.Cast {
+		if len(movie.Cast) >= CAST_MAX {
+			break
+		}
+		movie.Cast = append(movie.Cast, cast.Name)
+	}
+}
 
-	return c
+// Send the request and wait for the answer
+func (t *TMDB) send(path string, queries map[string]string, rsp interface{}) error {
+
+	queries["api_key"] = t.apiKey
+
+	if t.language != "" {
+		queries["language"] = t.language
+	}
+
+	channel, err := requests.SendRequest(&requests.Request{
+		Website: t.GetRef().String(),
+		Method:  "GET",
+		Url:     t.Url + path,
+		Queries: queries,
+	}, rsp)
+	if err != nil {
+		return err
+	}
+
+	res, ok := <-channel
+	if ok == false {
+		return fmt.Errorf("GET %s: request failed", path)
+	}
+
+	if res.Err != nil {
+		return res.Err
+	}
+
+	if res.Status != http.StatusOK {
+		return fmt.Errorf("GET %s: unexpected status %d", path, res.Status)
+	}
+
+	return nil
 }
@@ -1,30 +1,196 @@
 package TMDB
 
 import (
-	"fmt"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
 	"testing"
+	"time"
+
+	"github.com/ohohleo/classify/data"
+	"github.com/ohohleo/classify/requests"
+	"github.com/stretchr/testify/assert"
 )
 
+// Stand-in of the TMDB v3 API
+func newTestServer(t *testing.T, details *int) *httptest.Server {
+
+	pages := []string{
+		`{
+  "page": 1,
+  "total_pages": 2,
+  "total_results": 2,
+  "results": [{
+    "id": 1,
+    "original_title": "Médecin de campagne",
+    "title": "Irreplaceable",
+    "release_date": "2016-03-23",
+    "poster_path": "/1.jpg"
+  }]
+}`,
+		`{
+  "page": 2,
+  "total_pages": 2,
+  "total_results": 2,
+  "results": [{
+    "id": 2,
+    "original_title": "Médecin de nuit",
+    "release_date": "",
+    "overview": "Short overview"
+  }]
+}`,
+	}
+
+	movie := `{
+  "id": 1,
+  "original_title": "Médecin de campagne",
+  "title": "Irreplaceable",
+  "release_date": "2016-03-23",
+  "runtime": 102,
+  "overview": "Full overview",
+  "poster_path": "/poster.jpg",
+  "genres": [{"id": 18, "name": "Drama"}, {"id": 35, "name": "Comedy"}],
+  "credits": {
+    "cast": [{"name": "François Cluzet"}, {"name": "Marianne Denicourt"}],
+    "crew": [
+      {"name": "Thomas Lilti", "job": "Director", "department": "Directing"},
+      {"name": "Baya Kasmi", "job": "Screenplay", "department": "Writing"},
+      {"name": "Alexandre Lier", "job": "Original Music Composer"}
+    ]
+  }
+}`
+
+	return httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+
+			if r.URL.Query().Get("api_key") != "key" {
+				w.WriteHeader(http.StatusUnauthorized)
+				w.Write([]byte(`{"status_code": 7}`))
+				return
+			}
+
+			switch r.URL.Path {
+			case "/search/movie":
+				if r.URL.Query().Get("query") != "Medecin" {
+					t.Errorf("unexpected query %s", r.URL.RawQuery)
+				}
+
+				switch r.URL.Query().Get("page") {
+				case "1":
+					w.Write([]byte(pages[0]))
+				case "2":
+					w.Write([]byte(pages[1]))
+				default:
+					t.Errorf("unexpected page %s", r.URL.RawQuery)
+				}
+
+			case "/movie/1":
+				*details++
+				if r.URL.Query().Get("append_to_response") != "credits" {
+					t.Errorf("credits expected %s", r.URL.RawQuery)
+				}
+				w.Write([]byte(movie))
+
+			default:
+				w.WriteHeader(http.StatusNotFound)
+				json.NewEncoder(w).Encode(map[string]int{"status_code": 34})
+			}
+		}))
+}
+
+func search(tmdb *TMDB, input string) (movies []*data.Movie) {
+
+	for d := range tmdb.Search(input) {
+		movies = append(movies, d.(*data.Movie))
+	}
+
+	return
+}
+
 func TestSearch(t *testing.T) {
 
-	tmdb := New()
+	assert := assert.New(t)
 
-	tmdb.SetConfig(map[string]string{
-		"api_key":     "80e1027731e519ce1f4faacf21ecc13a",
+	var details int
+	server := newTestServer(t, &details)
+	defer server.Close()
+
+	requests.New(2, false)
+
+	tmdb := New()
+	assert.True(tmdb.SetConfig(map[string]string{
+		"api_key":     "key",
+		"url":         server.URL + "/",
 		"poster_path": "http://image.tmdb.org/t/p/original",
-	})
+	}))
 
-	c := tmdb.Search("Medecin+de+Campagne")
+	movies := search(tmdb, "Medecin")
+	if assert.Len(movies, 2) {
 
-	for {
-		movie, ok := <-c
-		if ok {
-			fmt.Printf("movie: %+v\n", movie)
-			continue
-		}
+		assert.Equal(&data.Movie{
+			Id:       "1",
+			Name:     "Irreplaceable",
+			Url:      "https://www.themoviedb.org/movie/1",
+			Released: time.Date(2016, 3, 23, 0, 0, 0, 0, time.UTC),
+			Image:    "http://image.tmdb.org/t/p/original/1.jpg",
+		}, movies[0])
 
-		break
+		// Original title used without any translation
+		assert.Equal(&data.Movie{
+			Id:          "2",
+			Name:        "Médecin de nuit",
+			Url:         "https://www.themoviedb.org/movie/2",
+			Description: "Short overview",
+		}, movies[1])
 	}
 
-	//imdb.getResource("tt0405393")
+	// Details only requested once validated
+	assert.Equal(0, details)
+
+	// Pages limited
+	assert.True(tmdb.SetConfig(map[string]string{"max_pages": "1"}))
+	assert.Len(search(tmdb, "Medecin"), 1)
+
+	assert.False(tmdb.SetConfig(map[string]string{"max_pages": "none"}))
+
+	// Invalid api key
+	tmdb.SetConfig(map[string]string{"api_key": "invalid"})
+	assert.Len(search(tmdb, "Medecin"), 0)
+}
+
+func TestDetails(t *testing.T) {
+
+	assert := assert.New(t)
+
+	var details int
+	server := newTestServer(t, &details)
+	defer server.Close()
+
+	requests.New(2, false)
+
+	tmdb := New()
+	tmdb.SetConfig(map[string]string{
+		"api_key": "key",
+		"url":     server.URL + "/",
+	})
+
+	d, err := tmdb.Details("1")
+	if assert.Nil(err) {
+		movie := d.(*data.Movie)
+		assert.Equal("Irreplaceable", movie.Name)
+		assert.Equal(102, movie.Duration)
+		assert.Equal("https://image.tmdb.org/t/p/original/poster.jpg", movie.Image)
+		assert.Equal([]string{"Thomas Lilti"}, movie.Directors)
+		assert.Equal([]string{"Baya Kasmi"}, movie.Writers)
+		assert.Equal([]string{"François Cluzet", "Marianne Denicourt"}, movie.Cast)
+		assert.Equal([]string{"Drama", "Comedy"}, movie.Genres)
+		assert.Equal("Full overview", movie.Description)
+	}
+	assert.Equal(1, details)
+
+	_, err = tmdb.Details("2")
+	assert.NotNil(err)
+
+	_, err = tmdb.Details("invalid")
+	assert.NotNil(err)
 }
@@ -24,4 +24,7 @@ type Website interface {
 	GetRef() Ref
 	SetConfig(map[string]string) bool
 	Search(string) chan data.Data
+
+	// Details returns the full record of the website data id
+	Details(id string) (data.Data, error)
 }